@@ -0,0 +1,52 @@
+package app
+
+import (
+	"xuan-ke-tong/backup"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/mail"
+	"xuan-ke-tong/oauth"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/utils"
+
+	"gorm.io/gorm"
+)
+
+// Application 汇总运行时依赖，由 main 构造后传给各路由注册函数
+type Application struct {
+	Config  *config.Config
+	DB      *gorm.DB
+	Repos   *repository.Repositories
+	Tokens  *utils.TokenManager
+	Backups *backup.Manager
+	Mailer  mail.Mailer
+	Authz   *rbac.Authorizer
+	// Bootstrap 尚无管理员时由 serve 签发的一次性引导令牌
+	Bootstrap *rbac.Bootstrap
+	// OAuthStates 进行中的 OAuth2 授权
+	OAuthStates oauth.StateStore
+}
+
+// New 基于配置和数据库连接构造应用依赖，签名密钥或邮件配置无效时返回错误
+func New(cfg *config.Config, db *gorm.DB) (*Application, error) {
+	tokens, err := utils.NewTokenManager(cfg.JWT)
+	if err != nil {
+		return nil, err
+	}
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		return nil, err
+	}
+	repos := repository.New(db)
+	return &Application{
+		Config:      cfg,
+		DB:          db,
+		Repos:       repos,
+		Tokens:      tokens,
+		Backups:     backup.NewManager(db, cfg.Backup),
+		Mailer:      mailer,
+		Authz:       rbac.NewAuthorizer(repos.Roles),
+		Bootstrap:   &rbac.Bootstrap{},
+		OAuthStates: oauth.NewStateStore(cfg.OAuth2, db),
+	}, nil
+}
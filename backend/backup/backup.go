@@ -0,0 +1,288 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"xuan-ke-tong/config"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 快照文件名形如 xkt-20240915T080000.123Z.db 或 xkt-20240915T080000.123Z-pre-restore.db，
+// 时间精确到毫秒，同一秒内的多个快照不会重名。旧版本生成的快照只精确到秒，仍能识别
+const (
+	filePrefix        = "xkt-"
+	fileSuffix        = ".db"
+	stampLayout       = "20060102T150405.000Z"
+	legacyStampLayout = "20060102T150405Z"
+)
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+
+// ErrNotFound 指定的快照不存在
+var ErrNotFound = errors.New("快照不存在")
+
+// Snapshot 一个快照文件
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Manager 管理 SQLite 数据库的在线快照
+type Manager struct {
+	db   *gorm.DB
+	dir  string
+	keep int
+
+	mu sync.Mutex // 同一时间只做一个快照
+}
+
+func NewManager(db *gorm.DB, cfg config.BackupConfig) *Manager {
+	return &Manager{db: db, dir: cfg.Dir, keep: cfg.Keep}
+}
+
+// Supported 当前数据库是否支持快照
+func (m *Manager) Supported() bool {
+	return m.db != nil && m.db.Dialector.Name() == config.DriverSQLite
+}
+
+// Create 用 VACUUM INTO 生成一致的在线快照，校验通过后才出现在快照目录中。
+// label 为可选的说明，如 manual、scheduled、pre-restore
+func (m *Manager) Create(label string) (*Snapshot, error) {
+	if !m.Supported() {
+		return nil, errors.New("仅 SQLite 数据库支持快照")
+	}
+	if label != "" && !labelPattern.MatchString(label) {
+		return nil, fmt.Errorf("快照标签只能包含小写字母、数字和连字符: %s", label)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建快照目录失败: %v", err)
+	}
+
+	now := time.Now().UTC()
+	name := filePrefix + now.Format(stampLayout)
+	if label != "" {
+		name += "-" + label
+	}
+	name += fileSuffix
+	path := filepath.Join(m.dir, name)
+	tmp := path + ".tmp"
+
+	// VACUUM INTO 要求目标文件不存在
+	os.Remove(tmp)
+	if err := m.db.Exec("VACUUM INTO ?", tmp).Error; err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("生成快照失败: %v", err)
+	}
+	if err := Verify(tmp); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	// 用硬链接代替重命名，目标已存在时失败而不是覆盖已有的快照
+	err := os.Link(tmp, path)
+	os.Remove(tmp)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("快照 %s 已存在", name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Name: name, Size: info.Size(), CreatedAt: now}, nil
+}
+
+// List 按时间从新到旧列出快照
+func (m *Manager) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for _, e := range entries {
+		createdAt, ok := parseName(e.Name())
+		if e.IsDir() || !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{Name: e.Name(), Size: info.Size(), CreatedAt: createdAt})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name > snapshots[j].Name
+	})
+	return snapshots, nil
+}
+
+// Path 返回快照文件的完整路径，拒绝目录穿越和非快照文件
+func (m *Manager) Path(name string) (string, error) {
+	if filepath.Base(name) != name {
+		return "", ErrNotFound
+	}
+	if _, ok := parseName(name); !ok {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// Prune 只保留最近的 keep 个快照，返回被删除的文件名
+func (m *Manager) Prune() ([]string, error) {
+	snapshots, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for i := m.keep; i < len(snapshots); i++ {
+		if err := os.Remove(filepath.Join(m.dir, snapshots[i].Name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, snapshots[i].Name)
+	}
+	return removed, nil
+}
+
+// RunSchedule 每隔 interval 生成一次快照并清理过期快照，直到 ctx 结束
+func (m *Manager) RunSchedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		snapshot, err := m.Create("scheduled")
+		if err != nil {
+			log.Printf("定时快照失败: %v", err)
+			continue
+		}
+		log.Printf("已生成定时快照 %s", snapshot.Name)
+		if removed, err := m.Prune(); err != nil {
+			log.Printf("清理过期快照失败: %v", err)
+		} else if len(removed) > 0 {
+			log.Printf("已清理过期快照 %s", strings.Join(removed, ", "))
+		}
+	}
+}
+
+// Verify 打开快照文件执行 PRAGMA integrity_check，并确认包含迁移记录
+func Verify(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("无法读取快照: %v", err)
+	}
+
+	db, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("无法打开快照: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var results []string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&results).Error; err != nil {
+		return fmt.Errorf("快照完整性检查失败: %v", err)
+	}
+	if len(results) != 1 || results[0] != "ok" {
+		return fmt.Errorf("快照已损坏: %s", strings.Join(results, "; "))
+	}
+
+	if !db.Migrator().HasTable("schema_migrations") {
+		return errors.New("快照中缺少 schema_migrations 表，不是本系统的数据库")
+	}
+	return nil
+}
+
+// Restore 校验快照后用它替换 dbPath 处的数据库文件。
+// 调用前必须关闭所有指向该数据库的连接
+func Restore(src, dbPath string) error {
+	if err := Verify(src); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// 先复制到同目录的临时文件再改名，避免中途失败留下半个数据库
+	tmp := dbPath + ".restore"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// 旧库的 WAL 文件属于被替换的数据，必须一并移除
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, dbPath)
+}
+
+func parseName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)
+	for _, layout := range []string{stampLayout, legacyStampLayout} {
+		if len(stamp) < len(layout) || (len(stamp) > len(layout) && stamp[len(layout)] != '-') {
+			continue
+		}
+		if t, err := time.Parse(layout, stamp[:len(layout)]); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,176 @@
+package backup_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"xuan-ke-tong/backup"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/migrations"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/testdb"
+
+	"gorm.io/gorm"
+)
+
+// newManager 返回已执行全部迁移的 SQLite 库和使用临时快照目录的 Manager
+func newManager(t *testing.T, keep int) (*backup.Manager, *gorm.DB) {
+	t.Helper()
+	db := testdb.SQLite(t)
+	if _, err := migrations.Up(db, 0); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	return backup.NewManager(db, config.BackupConfig{Dir: t.TempDir(), Keep: keep}), db
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	m, db := newManager(t, 5)
+	if err := db.Create(&models.Course{Name: "线性代数"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := m.Create("manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := m.Path(snapshot.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.Verify(path); err != nil {
+		t.Fatalf("新快照校验失败: %v", err)
+	}
+
+	// 快照之后的改动不应出现在恢复的库中
+	if err := db.Create(&models.Course{Name: "概率统计"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "restored.db")
+	for _, name := range []string{dbPath, dbPath + "-wal"} {
+		if err := os.WriteFile(name, []byte("旧数据"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := backup.Restore(path, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dbPath + "-wal"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("恢复后旧库的 WAL 文件仍在: %v", err)
+	}
+
+	restored, err := config.ConnectDatabase(config.DatabaseConfig{Driver: config.DriverSQLite, DSN: dbPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := restored.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	var names []string
+	if err := restored.Model(&models.Course{}).Pluck("name", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "线性代数" {
+		t.Fatalf("恢复后的课程为 %v，期望只有快照时的 [线性代数]", names)
+	}
+}
+
+func TestPruneKeepsNewest(t *testing.T) {
+	m, _ := newManager(t, 2)
+	var created []string
+	for i := 0; i < 4; i++ {
+		snapshot, err := m.Create("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, snapshot.Name)
+		time.Sleep(2 * time.Millisecond) // 快照名精确到毫秒
+	}
+
+	removed, err := m.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[0] != created[1] || removed[1] != created[0] {
+		t.Fatalf("删除了 %v，期望最旧的 %v", removed, created[:2])
+	}
+	snapshots, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Name != created[3] || snapshots[1].Name != created[2] {
+		t.Fatalf("剩余快照为 %v，期望最新的 2 个", snapshots)
+	}
+}
+
+func TestPathRejectsTraversal(t *testing.T) {
+	m, _ := newManager(t, 5)
+	snapshot, err := m.Create("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Path(snapshot.Name); err != nil {
+		t.Fatalf("已有快照 %s 返回 %v", snapshot.Name, err)
+	}
+
+	for _, name := range []string{
+		"../" + snapshot.Name,
+		"sub/" + snapshot.Name,
+		"/etc/passwd",
+		"..",
+		"xkt-../../database.db",
+		"database.db",
+		"xkt-20240915T080000.123Z.db", // 格式正确但不存在
+	} {
+		if _, err := m.Path(name); !errors.Is(err, backup.ErrNotFound) {
+			t.Errorf("Path(%q) 返回 %v，期望 ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestVerifyRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	corrupt := filepath.Join(dir, "corrupt.db")
+	if err := os.WriteFile(corrupt, []byte("这不是 SQLite 数据库"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.Verify(corrupt); err == nil {
+		t.Error("损坏的文件通过了校验")
+	}
+
+	// 完好的 SQLite 库，但没有迁移记录
+	foreign := filepath.Join(dir, "foreign.db")
+	db, err := config.ConnectDatabase(config.DatabaseConfig{Driver: config.DriverSQLite, DSN: foreign})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec("CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)").Error
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.Verify(foreign); err == nil {
+		t.Error("非本系统的数据库通过了校验")
+	}
+
+	if err := backup.Verify(filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("不存在的文件通过了校验")
+	}
+
+	// 校验失败的快照不能用于恢复，目标库保持原样
+	dbPath := filepath.Join(dir, "current.db")
+	if err := os.WriteFile(dbPath, []byte("当前数据"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.Restore(foreign, dbPath); err == nil {
+		t.Error("用非本系统的数据库恢复没有报错")
+	}
+	if data, _ := os.ReadFile(dbPath); string(data) != "当前数据" {
+		t.Errorf("恢复失败后目标库被改动: %q", data)
+	}
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/backup"
+)
+
+// runBackup 处理 backup [create|list|verify|prune] 子命令，默认为 create
+func runBackup(a *app.Application, args []string) error {
+	action := "create"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "create":
+		fs := flag.NewFlagSet("backup create", flag.ContinueOnError)
+		label := fs.String("label", "manual", "快照标签")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		snapshot, err := a.Backups.Create(*label)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已生成快照 %s (%d 字节)\n", snapshot.Name, snapshot.Size)
+		return prune(a.Backups)
+	case "list":
+		snapshots, err := a.Backups.List()
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%-48s %12d  %s\n", s.Name, s.Size, s.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	case "verify":
+		if len(args) != 1 {
+			return errors.New("用法: backup verify <快照名或文件路径>")
+		}
+		path, err := snapshotPath(a.Backups, args[0])
+		if err != nil {
+			return err
+		}
+		if err := backup.Verify(path); err != nil {
+			return err
+		}
+		fmt.Printf("快照 %s 完整性检查通过\n", path)
+		return nil
+	case "prune":
+		return prune(a.Backups)
+	default:
+		return errors.New("用法: backup [create [--label 标签] | list | verify <快照> | prune]")
+	}
+}
+
+// runRestore 用快照替换当前数据库，替换前会先为当前数据库生成 pre-restore 快照
+func runRestore(a *app.Application, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "确认覆盖当前数据库")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("用法: restore --yes <快照名或文件路径>")
+	}
+
+	dbPath, err := a.Config.Database.SQLitePath()
+	if err != nil {
+		return err
+	}
+	src, err := snapshotPath(a.Backups, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := backup.Verify(src); err != nil {
+		return err
+	}
+	if !*yes {
+		return fmt.Errorf("快照 %s 校验通过。恢复会覆盖 %s，请停止服务后加 --yes 执行", src, dbPath)
+	}
+
+	current, err := a.Backups.Create("pre-restore")
+	if err != nil {
+		return fmt.Errorf("为当前数据库生成快照失败，已取消恢复: %v", err)
+	}
+	fmt.Printf("已为当前数据库生成快照 %s\n", current.Name)
+
+	closeDatabase(a.DB)
+	if err := backup.Restore(src, dbPath); err != nil {
+		return err
+	}
+	fmt.Printf("已用 %s 恢复数据库 %s\n", src, dbPath)
+	return nil
+}
+
+// snapshotPath 接受快照目录中的文件名或任意文件路径
+func snapshotPath(backups *backup.Manager, arg string) (string, error) {
+	if path, err := backups.Path(arg); err == nil {
+		return path, nil
+	}
+	if _, err := os.Stat(arg); err != nil {
+		return "", fmt.Errorf("找不到快照 %s", arg)
+	}
+	return arg, nil
+}
+
+func prune(backups *backup.Manager) error {
+	removed, err := backups.Prune()
+	for _, name := range removed {
+		fmt.Printf("已清理过期快照 %s\n", name)
+	}
+	return err
+}
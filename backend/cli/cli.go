@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/migrations"
+
+	"gorm.io/gorm"
+)
+
+// command 一个子命令。schema 为 true 时执行前要求数据库结构为最新版本
+type command struct {
+	name    string
+	usage   string
+	summary string
+	schema  bool
+	run     func(a *app.Application, args []string) error
+}
+
+var commands = []command{
+	{"serve", "serve", "启动 HTTP 服务（默认命令）", true, runServe},
+	{"migrate", "migrate up [版本号] | down [步数] | status", "管理数据库迁移", false, runMigrate},
+	{"seed", "seed [--env demo|test|load | --fixtures 文件] [--dry-run] [--reset]", "按环境导入种子数据", true, runSeed},
+	{"create-admin", "create-admin --username 用户名 --email 邮箱 [--password 密码 | --password-stdin] [--promote]", "创建管理员账号", true, runCreateAdmin},
+	{"reset-password", "reset-password [--password 密码 | --password-stdin] <用户名或邮箱>", "重置用户密码并吊销其全部会话", true, runResetPassword},
+	{"reset-2fa", "reset-2fa <用户名或邮箱>", "关闭用户的两步验证并吊销其全部会话", true, runResetTwoFactor},
+	{"login-unlock", "login-unlock <用户名或 IP>", "解除账号或 IP 的登录锁定", true, runLoginUnlock},
+	{"backup", "backup [create [--label 标签] | list | verify <快照> | prune]", "生成、列出、校验和清理 SQLite 快照", false, runBackup},
+	{"restore", "restore --yes <快照名或文件路径>", "用快照恢复 SQLite 数据库（需先停止服务）", false, runRestore},
+	{"trash", "trash purge [--older-than 时长]", "彻底删除回收站中超过保留期的记录", true, runTrash},
+	{"search-reindex", "search-reindex", "重建课程全文索引（仅 SQLite）", true, runSearchReindex},
+	{"integrity-check", "integrity-check [--repair]", "检查并修复评分、评论和求评价请求中的孤立记录", true, runIntegrityCheck},
+	{"send-test-mail", "send-test-mail <收件地址>", "按当前配置发送测试邮件", false, runSendTestMail},
+	{"routes", "routes", "列出全部已注册的接口", false, runRoutes},
+	{"fake-oidc", "fake-oidc [--addr 地址] [--client-id ID] [--client-secret 密钥]", "启动本地模拟的 OIDC 提供方，用于联调第三方登录", false, runFakeOIDC},
+}
+
+// Run 解析全局参数和子命令并执行，返回进程退出码
+func Run(args []string) int {
+	// 配置有误时直接退出，避免以错误的配置对外提供服务
+	cfg, rest, err := config.Load(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "配置加载失败:\n%v\n", err)
+		return 1
+	}
+
+	name := "serve"
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return 0
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", name)
+		printUsage()
+		return 2
+	}
+
+	db, err := config.ConnectDatabase(cfg.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "连接数据库失败: %v\n", err)
+		return 1
+	}
+	defer closeDatabase(db)
+
+	if cmd.schema {
+		if err := applyPendingMigrations(db, cfg.Database.AutoMigrate); err != nil {
+			fmt.Fprintf(os.Stderr, "数据库初始化失败: %v\n", err)
+			return 1
+		}
+	}
+
+	a, err := app.New(cfg, db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化失败: %v\n", err)
+		return 1
+	}
+	if err := cmd.run(a, rest); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "用法: xuan-ke-tong [全局参数] <命令> [命令参数]")
+	fmt.Fprintln(os.Stderr, "\n命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+		fmt.Fprintf(os.Stderr, "  %-16s   %s\n", "", cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\n全局参数: -config -env -addr -db-driver -db-dsn -cors-origins，详见 config.example.yaml")
+}
+
+func closeDatabase(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// applyPendingMigrations 执行尚未执行的迁移。
+// 关闭自动迁移时只做检查，存在未执行的迁移则拒绝继续
+func applyPendingMigrations(db *gorm.DB, autoMigrate bool) error {
+	if !autoMigrate {
+		pending, err := migrations.Pending(db)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("存在 %d 个未执行的迁移，请先运行 migrate up", pending)
+		}
+		return nil
+	}
+
+	applied, err := migrations.Up(db, 0)
+	for _, m := range applied {
+		fmt.Printf("已执行迁移 %04d_%s\n", m.Version, m.Name)
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"xuan-ke-tong/app"
+)
+
+// runIntegrityCheck 报告评分、评论和求评价请求中的孤立记录，--repair 时一并修复
+func runIntegrityCheck(a *app.Application, args []string) error {
+	fs := flag.NewFlagSet("integrity-check", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "删除父记录不存在的行，并对父记录在回收站中的行补做级联处理")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("integrity-check 不接受参数: %v", fs.Args())
+	}
+
+	issues, err := a.Repos.Integrity.Check()
+	if *repair {
+		issues, err = a.Repos.Integrity.Repair()
+	}
+	if err != nil {
+		return err
+	}
+
+	verb := "发现"
+	if *repair {
+		verb = "已修复"
+	}
+	var total int64
+	for _, issue := range issues {
+		ref := fmt.Sprintf("%s.%s -> %s", issue.Table, issue.Column, issue.Parent)
+		fmt.Printf("%-42s %s 父记录不存在 %d 条，父记录在回收站 %d 条\n", ref, verb, issue.Missing, issue.Stale)
+		total += issue.Missing + issue.Stale
+	}
+
+	switch {
+	case total == 0:
+		fmt.Println("未发现完整性问题")
+	case *repair:
+		fmt.Printf("共修复 %d 条记录\n", total)
+	default:
+		// 以非零退出码结束，便于在脚本和定时任务中发现问题
+		return errors.New("发现完整性问题，可加 --repair 修复")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+)
+
+// 清理过期登录失败记录的间隔
+const loginPruneInterval = time.Hour
+
+// runLoginUnlock 解除账号或 IP 的登录锁定，管理员自己被锁定时使用
+func runLoginUnlock(a *app.Application, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: login-unlock <用户名或 IP>")
+	}
+
+	scope, value := models.LoginScopeUser, strings.ToLower(args[0])
+	if net.ParseIP(args[0]) != nil {
+		scope, value = models.LoginScopeIP, args[0]
+	}
+	if err := a.Repos.LoginAttempts.Reset(scope, value); err != nil {
+		return err
+	}
+	fmt.Printf("已解除 %s %s 的登录锁定\n", scope, value)
+	return nil
+}
+
+// pruneLoginEventsPeriodically 定期删除超过保留期的登录失败记录，直到 ctx 结束
+func pruneLoginEventsPeriodically(ctx context.Context, attempts repository.LoginAttemptRepository, retention time.Duration) {
+	ticker := time.NewTicker(loginPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if n, err := attempts.Prune(now.Add(-retention), now); err != nil {
+			log.Printf("清理登录失败记录失败: %v", err)
+		} else if n > 0 {
+			log.Printf("已删除 %d 条过期的登录失败记录", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/mail"
+)
+
+// runSendTestMail 按当前配置发送一封测试邮件，用于检查 SMTP 设置
+func runSendTestMail(a *app.Application, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: send-test-mail <收件地址>")
+	}
+	if err := a.Mailer.Send(context.Background(), mail.Test(args[0])); err != nil {
+		return err
+	}
+	if a.Config.Mail.Driver == config.MailDriverFile {
+		fmt.Printf("测试邮件已写入 %s\n", a.Config.Mail.OutboxDir)
+	} else {
+		fmt.Printf("测试邮件已发送到 %s\n", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/migrations"
+)
+
+// runMigrate 处理 migrate up|down|status 子命令
+func runMigrate(a *app.Application, args []string) error {
+	usage := fmt.Errorf("用法: migrate up [版本号] | migrate down [步数] | migrate status")
+	if len(args) == 0 {
+		return usage
+	}
+
+	// 可选的数字参数：up 为目标版本号，down 为回滚步数
+	n := 0
+	if len(args) > 1 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 0 {
+			return usage
+		}
+		n = v
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(a.DB, n)
+		for _, m := range applied {
+			fmt.Printf("已执行迁移 %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("数据库结构已是最新版本")
+		}
+		return err
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		reverted, err := migrations.Down(a.DB, n)
+		for _, m := range reverted {
+			fmt.Printf("已回滚迁移 %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrations.List(a.DB)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return usage
+	}
+}
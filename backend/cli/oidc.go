@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/oauth"
+)
+
+// runFakeOIDC 在本地启动模拟的 OpenID Connect 提供方，用于开发和联调第三方登录
+func runFakeOIDC(a *app.Application, args []string) error {
+	fs := flag.NewFlagSet("fake-oidc", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:9400", "监听地址")
+	clientID := fs.String("client-id", "xuanketong", "客户端 ID")
+	clientSecret := fs.String("client-secret", "fake-secret", "客户端密钥")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	issuer := "http://" + *addr
+	fmt.Printf("模拟 OIDC 提供方已启动: %s\n", issuer)
+	fmt.Printf("在 oauth2.providers 中加入:\n  - name: fake\n    issuer: %s\n    clientId: %s\n    clientSecret: %s\n",
+		issuer, *clientID, *clientSecret)
+	fmt.Println("授权地址加上 login_hint=邮箱 可指定登录的账号，默认 fake@example.com")
+	return http.ListenAndServe(*addr, oauth.NewFakeProvider(issuer, *clientID, *clientSecret))
+}
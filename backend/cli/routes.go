@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runRoutes 按路径列出全部已注册的接口
+func runRoutes(a *app.Application, args []string) error {
+	gin.SetMode(gin.ReleaseMode)
+	list := routes.NewRouter(a).Routes()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Path != list[j].Path {
+			return list[i].Path < list[j].Path
+		}
+		return list[i].Method < list[j].Method
+	})
+	for _, route := range list {
+		fmt.Printf("%-7s %s\n", route.Method, route.Path)
+	}
+	return nil
+}
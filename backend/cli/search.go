@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/config"
+)
+
+// runSearchReindex 重建课程全文索引。索引由触发器随写入同步，只在索引损坏或直接改动数据库后需要重建
+func runSearchReindex(a *app.Application, args []string) error {
+	if len(args) > 0 {
+		return errors.New("用法: search-reindex")
+	}
+	if a.DB.Dialector.Name() != config.DriverSQLite {
+		fmt.Println("当前数据库不使用全文索引，搜索直接查询课程表，无需重建")
+		return nil
+	}
+
+	n, err := a.Repos.Search.Rebuild()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已重建课程全文索引，共 %d 门课程\n", n)
+	return nil
+}
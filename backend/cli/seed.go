@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/seed"
+)
+
+func runSeed(a *app.Application, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	env := fs.String("env", seed.DefaultEnv, "种子数据环境: "+strings.Join(seed.Envs(), " | "))
+	fixtures := fs.String("fixtures", "", "种子数据文件 (YAML/JSON)，指定后忽略 --env")
+	dryRun := fs.Bool("dry-run", false, "只输出将要发生的变更，不写入数据库")
+	reset := fs.Bool("reset", false, "导入前清空用户、课程、评分、评论和求评价")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *reset && a.Config.IsProduction() {
+		return errors.New("生产环境禁止使用 --reset")
+	}
+
+	path := *fixtures
+	if path == "" {
+		path = seed.EnvFile(*env)
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("未知的种子数据环境 %s，可用: %s", *env, strings.Join(seed.Envs(), ", "))
+		}
+	}
+	fx, err := seed.Load(path)
+	if err != nil {
+		return err
+	}
+
+	report, err := seed.Apply(a.DB, fx, seed.Options{DryRun: *dryRun, Reset: *reset})
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		for _, c := range report.Changes {
+			line := fmt.Sprintf("%s %s %s", actionSymbol(c.Action), c.Kind, c.Key)
+			if c.Detail != "" {
+				line += " (" + c.Detail + ")"
+			}
+			fmt.Println(line)
+		}
+		fmt.Printf("[dry-run] %s：将新建 %d 条、更新 %d 条、删除 %d 条，%d 条无变化\n", path,
+			report.Count(seed.ActionCreate), report.Count(seed.ActionUpdate), report.Deleted, report.Unchanged)
+		return nil
+	}
+
+	fmt.Printf("已导入 %s：新建 %d 条、更新 %d 条、删除 %d 条，%d 条无变化\n", path,
+		report.Count(seed.ActionCreate), report.Count(seed.ActionUpdate), report.Deleted, report.Unchanged)
+	return nil
+}
+
+func actionSymbol(action string) string {
+	switch action {
+	case seed.ActionCreate:
+		return "+"
+	case seed.ActionUpdate:
+		return "~"
+	case seed.ActionDelete:
+		return "-"
+	default:
+		return "?"
+	}
+}
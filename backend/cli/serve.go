@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/oauth"
+	"xuan-ke-tong/routes"
+	"xuan-ke-tong/server"
+)
+
+// runServe 启动 HTTP 服务，收到 SIGINT/SIGTERM 后优雅退出。
+// 数据库连接池由 Run 在服务停止后关闭
+func runServe(a *app.Application, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("serve 不接受参数: %v", args)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if interval := a.Config.Backup.Interval; interval > 0 {
+		if a.Backups.Supported() {
+			log.Printf("每 %s 生成一次数据库快照，保留最近 %d 个", interval, a.Config.Backup.Keep)
+			go a.Backups.RunSchedule(ctx, interval)
+		} else {
+			log.Printf("当前数据库不支持在线快照，已忽略 backup.interval")
+		}
+	}
+
+	if retention := a.Config.Trash.Retention; retention > 0 {
+		go purgeTrashPeriodically(ctx, a.Repos.Trash, retention)
+	}
+
+	if err := issueBootstrapToken(a); err != nil {
+		return err
+	}
+
+	go pruneLoginEventsPeriodically(ctx, a.Repos.LoginAttempts, a.Config.Auth.Lockout.EventRetention)
+	go oauth.RunSweeper(ctx, a.OAuthStates, a.Config.OAuth2.StateTTL)
+
+	return server.Run(ctx, a.Config.Server, routes.NewRouter(a))
+}
+
+// issueBootstrapToken 尚无管理员时签发一次性引导令牌并写入日志，
+// 用于通过 POST /api/v1/auth/bootstrap 创建首位管理员
+func issueBootstrapToken(a *app.Application) error {
+	admins, err := a.Repos.Users.CountByRole(models.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("检查管理员失败: %v", err)
+	}
+	if admins > 0 {
+		return nil
+	}
+	token, err := a.Bootstrap.Issue()
+	if err != nil {
+		return err
+	}
+	log.Printf("尚无管理员，请使用一次性引导令牌 %s 调用 POST /api/v1/auth/bootstrap 创建首位管理员，或执行 create-admin", token)
+	return nil
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/repository"
+)
+
+// 服务运行期间检查回收站过期记录的间隔
+const trashPurgeInterval = time.Hour
+
+// runTrash 处理 trash purge 子命令
+func runTrash(a *app.Application, args []string) error {
+	if len(args) == 0 || args[0] != "purge" {
+		return errors.New("用法: trash purge [--older-than 时长]")
+	}
+
+	fs := flag.NewFlagSet("trash purge", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", a.Config.Trash.Retention, "清除删除时间早于该时长的记录，0 表示清空回收站")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *olderThan < 0 {
+		return errors.New("--older-than 不能为负数")
+	}
+
+	purged, err := a.Repos.Trash.Purge(time.Now().Add(-*olderThan))
+	if err != nil {
+		return err
+	}
+	for _, kind := range repository.TrashKinds {
+		fmt.Printf("%-10s 已彻底删除 %d 条\n", kind, purged[kind])
+	}
+	// 求评价请求不进回收站，只在其课程或用户被清除时连带删除
+	if n := purged["evaluation_requests"]; n > 0 {
+		fmt.Printf("另有 %d 条求评价请求随课程或用户一并删除\n", n)
+	}
+	return nil
+}
+
+// purgeTrashPeriodically 定期彻底删除超过保留期的回收站记录，直到 ctx 结束
+func purgeTrashPeriodically(ctx context.Context, trash repository.TrashRepository, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := trash.Purge(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("清理回收站失败: %v", err)
+		} else {
+			for table, n := range purged {
+				if n > 0 {
+					log.Printf("已从回收站彻底删除 %d 条 %s", n, table)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 与注册接口保持一致的最小密码长度
+const minPasswordLength = 6
+
+// passwordFlags 设置密码的公共参数：显式指定、从标准输入读取或随机生成
+type passwordFlags struct {
+	value *string
+	stdin *bool
+}
+
+func addPasswordFlags(fs *flag.FlagSet) passwordFlags {
+	return passwordFlags{
+		value: fs.String("password", "", "新密码；省略时随机生成并输出"),
+		stdin: fs.Bool("password-stdin", false, "从标准输入读取密码"),
+	}
+}
+
+// resolve 返回最终使用的密码，generated 表示密码为随机生成，需要告知操作者
+func (p passwordFlags) resolve() (password string, generated bool, err error) {
+	switch {
+	case *p.stdin:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", false, fmt.Errorf("读取密码失败: %v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	case *p.value != "":
+		password = *p.value
+	default:
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return "", false, err
+		}
+		return base64.RawURLEncoding.EncodeToString(buf), true, nil
+	}
+
+	if len(password) < minPasswordLength {
+		return "", false, fmt.Errorf("密码长度至少为 %d 位", minPasswordLength)
+	}
+	return password, false, nil
+}
+
+func runCreateAdmin(a *app.Application, args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := fs.String("username", "", "管理员用户名")
+	email := fs.String("email", "", "管理员邮箱")
+	nickname := fs.String("nickname", "", "昵称，默认与用户名相同")
+	promote := fs.Bool("promote", false, "用户已存在时将其提升为管理员，而不是报错")
+	pw := addPasswordFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *username == "" {
+		return errors.New("必须指定 --username")
+	}
+
+	existing, err := a.Repos.Users.FindByUsername(*username)
+	switch {
+	case err == nil:
+		if !*promote {
+			return fmt.Errorf("用户 %s 已存在，如需将其设为管理员请加 --promote", *username)
+		}
+		if existing.Role == models.RoleAdmin {
+			fmt.Printf("用户 %s 已是管理员\n", existing.Username)
+			return nil
+		}
+		err := a.Repos.Roles.ChangeUserRole(&models.RoleChange{
+			UserID:   existing.ID,
+			Username: existing.Username,
+			FromRole: existing.Role,
+			ToRole:   models.RoleAdmin,
+			Source:   models.RoleChangeCLI,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已将用户 %s 设为管理员\n", existing.Username)
+		return nil
+	case !errors.Is(err, repository.ErrNotFound):
+		return err
+	}
+
+	if taken, err := a.Repos.Users.UsernameTaken(*username); err != nil {
+		return err
+	} else if taken {
+		return fmt.Errorf("用户 %s 在回收站中，请先恢复或等待其被彻底清除", *username)
+	}
+
+	if *email == "" {
+		return errors.New("必须指定 --email")
+	}
+	if taken, err := a.Repos.Users.EmailTaken(*email); err != nil {
+		return err
+	} else if taken {
+		return fmt.Errorf("邮箱 %s 已被使用", *email)
+	}
+
+	password, generated, err := pw.resolve()
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if *nickname == "" {
+		*nickname = *username
+	}
+	now := time.Now()
+	user := models.User{
+		Username:        *username,
+		Password:        string(hashedPassword),
+		Email:           *email,
+		Nickname:        *nickname,
+		Role:            models.RoleAdmin,
+		EmailVerifiedAt: &now,
+	}
+	if err := a.Repos.Roles.CreateUser(&user, &models.RoleChange{Source: models.RoleChangeCLI}); err != nil {
+		return err
+	}
+
+	fmt.Printf("已创建管理员 %s (ID %d)\n", user.Username, user.ID)
+	if generated {
+		fmt.Printf("初始密码: %s\n", password)
+	}
+	return nil
+}
+
+func runResetPassword(a *app.Application, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	pw := addPasswordFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("用法: reset-password [--password 密码 | --password-stdin] <用户名或邮箱>")
+	}
+
+	user, err := findUserByLogin(a, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	password, generated, err := pw.resolve()
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+	if err := a.Repos.Users.Save(user); err != nil {
+		return err
+	}
+
+	// 与网页找回密码一致，重置后已登录的设备全部下线，账号被盗时攻击者的会话随之失效
+	revoked, err := a.Repos.Sessions.RevokeAllByUser(user.ID, 0)
+	if err != nil {
+		return err
+	}
+	if err := a.Repos.LoginAttempts.Reset(models.LoginScopeUser, strings.ToLower(user.Username)); err != nil {
+		return err
+	}
+
+	fmt.Printf("已重置用户 %s 的密码，吊销 %d 个会话并解除登录锁定\n", user.Username, revoked)
+	if generated {
+		fmt.Printf("新密码: %s\n", password)
+	}
+	return nil
+}
+
+// runResetTwoFactor 为丢失验证器和恢复码的用户关闭两步验证并吊销其全部会话
+func runResetTwoFactor(a *app.Application, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: reset-2fa <用户名或邮箱>")
+	}
+	user, err := findUserByLogin(a, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := a.Repos.TwoFactor.Disable(user.ID); err != nil {
+		return err
+	}
+	after := *user
+	after.TOTPEnabledAt = nil
+	changes, err := models.DiffAudit(user, &after)
+	if err != nil {
+		return err
+	}
+	err = a.Repos.Audit.Record(&models.AuditLog{
+		Action:     models.AuditUserResetTwoFactor,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID,
+		Changes:    changes,
+	})
+	if err != nil {
+		return err
+	}
+	n, err := a.Repos.Sessions.RevokeAllByUser(user.ID, 0)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已关闭用户 %s 的两步验证，并吊销 %d 个会话\n", user.Username, n)
+	return nil
+}
+
+// findUserByLogin 按用户名或邮箱查找用户
+func findUserByLogin(a *app.Application, login string) (*models.User, error) {
+	user, err := a.Repos.Users.FindByUsername(login)
+	if errors.Is(err, repository.ErrNotFound) {
+		user, err = a.Repos.Users.FindByEmail(login)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("用户 %s 不存在", login)
+	}
+	return user, err
+}
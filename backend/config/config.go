@@ -0,0 +1,770 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// 运行环境
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// 开发环境下未配置密钥时使用的占位值，生产环境禁止使用
+const devJWTSecret = "dev-only-secret-do-not-use-in-production"
+
+// Config 应用的全部配置项
+type Config struct {
+	Env       string         `yaml:"env"`
+	PublicURL string         `yaml:"publicURL"` // 前端地址，用于拼接邮件中的链接
+	Server    ServerConfig   `yaml:"server"`
+	Database  DatabaseConfig `yaml:"database"`
+	JWT       JWTConfig      `yaml:"jwt"`
+	Auth      AuthConfig     `yaml:"auth"`
+	Mail      MailConfig     `yaml:"mail"`
+	CORS      CORSConfig     `yaml:"cors"`
+	OAuth2    OAuth2Config   `yaml:"oauth2"`
+	Backup    BackupConfig   `yaml:"backup"`
+	Trash     TrashConfig    `yaml:"trash"`
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"` // 监听地址，例如 ":8080"
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout"` // 收到退出信号后等待进行中请求完成的最长时间
+	TLS               TLSConfig     `yaml:"tls"`
+	// TrustedProxies 可信反向代理的 IP 或 CIDR，只采用来自这些地址的 X-Forwarded-For；
+	// 为空时客户端 IP 取连接的对端地址，请求头无法伪造
+	TrustedProxies []string `yaml:"trustedProxies"`
+}
+
+// TLSConfig HTTPS 证书配置，两项都为空时使用 HTTP
+type TLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// Enabled 是否启用 HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// JWTConfig 登录令牌配置
+type JWTConfig struct {
+	Secret     string         `yaml:"secret"`     // 未配置 keys 时使用的 HS256 密钥
+	Keys       []JWTKeyConfig `yaml:"keys"`       // 签名密钥环，配置后忽略 secret
+	TTL        time.Duration  `yaml:"ttl"`        // 访问令牌有效期
+	RefreshTTL time.Duration  `yaml:"refreshTTL"` // 刷新令牌（会话）有效期，每次刷新后重新计算
+	Issuer     string         `yaml:"issuer"`
+}
+
+// 支持的令牌签名算法
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+	JWTAlgEdDSA = "EdDSA" // Ed25519
+)
+
+// JWTKeyConfig 密钥环中的一个签名密钥，令牌头部的 kid 即为 ID。
+// 在 notBefore 之前只用于发布和校验，之后最新生效的密钥负责签名；
+// 超过 notAfter 后不再接受由它签名的令牌
+type JWTKeyConfig struct {
+	ID             string    `yaml:"id"`
+	Algorithm      string    `yaml:"algorithm"`      // HS256、RS256 或 EdDSA
+	Secret         string    `yaml:"secret"`         // HS256 的共享密钥
+	PrivateKeyFile string    `yaml:"privateKeyFile"` // RS256/EdDSA 的 PEM 私钥
+	PublicKeyFile  string    `yaml:"publicKeyFile"`  // 只有公钥时该密钥仅用于校验
+	NotBefore      time.Time `yaml:"notBefore"`
+	NotAfter       time.Time `yaml:"notAfter"`
+}
+
+// AuthConfig 账号安全相关配置
+type AuthConfig struct {
+	PasswordResetTTL     time.Duration      `yaml:"passwordResetTTL"`     // 重置密码链接的有效期
+	EmailVerificationTTL time.Duration      `yaml:"emailVerificationTTL"` // 邮箱验证链接的有效期
+	Registration         RegistrationConfig `yaml:"registration"`
+	Lockout              LockoutConfig      `yaml:"lockout"`
+	TwoFactor            TwoFactorConfig    `yaml:"twoFactor"`
+}
+
+// TwoFactorConfig 两步验证（TOTP）的设置。哪些角色必须启用由管理员在角色管理中设置
+type TwoFactorConfig struct {
+	Issuer       string        `yaml:"issuer"`       // 验证器应用中显示的发行方名称
+	ChallengeTTL time.Duration `yaml:"challengeTTL"` // 密码验证通过后输入动态验证码的时限
+}
+
+// LockoutConfig 登录失败后的锁定策略。账号和 IP 分别计数，
+// 达到阈值后锁定 baseDuration，之后每多失败一次锁定时长翻倍，最长 maxDuration
+type LockoutConfig struct {
+	MaxAttempts    int           `yaml:"maxAttempts"`    // 同一账号连续失败多少次后锁定，0 表示不锁定
+	IPMaxAttempts  int           `yaml:"ipMaxAttempts"`  // 同一 IP 失败多少次后锁定，校园网出口共用 IP，应明显大于 maxAttempts
+	BaseDuration   time.Duration `yaml:"baseDuration"`   // 首次锁定时长
+	MaxDuration    time.Duration `yaml:"maxDuration"`    // 锁定时长上限
+	Window         time.Duration `yaml:"window"`         // 超过该时长没有新的失败时重新计数
+	EventRetention time.Duration `yaml:"eventRetention"` // 登录失败记录的保留时长
+}
+
+// LockFor 返回累计失败 failures 次后的锁定时长，threshold 为 0 或未达到阈值时返回 0
+func (c LockoutConfig) LockFor(failures, threshold int) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+	d := c.BaseDuration
+	for i := threshold; i < failures && d < c.MaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, c.MaxDuration)
+}
+
+// 注册方式
+const (
+	RegistrationOpen   = "open"   // 任何邮箱均可注册
+	RegistrationDomain = "domain" // 只允许 allowedDomains 中的邮箱，持有邀请码的不受限制
+	RegistrationInvite = "invite" // 必须持有管理员发放的邀请码
+)
+
+// RegistrationConfig 限制谁可以注册新账号
+type RegistrationConfig struct {
+	Mode           string   `yaml:"mode"`
+	AllowedDomains []string `yaml:"allowedDomains"` // 例如 pku.edu.cn，或用 *.edu.cn 匹配全部子域名
+}
+
+// AllowsEmail 邮箱的域名是否在 allowedDomains 中
+func (c RegistrationConfig) AllowsEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, pattern := range c.AllowedDomains {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(domain, "."+suffix) {
+				return true
+			}
+		} else if domain == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// 支持的邮件发送方式
+const (
+	MailDriverSMTP = "smtp"
+	MailDriverFile = "file" // 写入本地 outbox 目录，供开发和测试查看
+)
+
+// MailConfig 邮件发送配置
+type MailConfig struct {
+	Driver    string     `yaml:"driver"`
+	From      string     `yaml:"from"`
+	OutboxDir string     `yaml:"outboxDir"` // file 驱动写入 .eml 文件的目录
+	SMTP      SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig SMTP 服务器配置
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"` // 为空时不认证，适用于本地的测试 SMTP 服务
+	Password string `yaml:"password"`
+	TLS      string `yaml:"tls"` // starttls（必须升级为加密连接，服务器不支持时发送失败）、tls（直接 TLS，通常为 465 端口）或 none
+}
+
+// BackupConfig 数据库快照配置，仅 SQLite 可用
+type BackupConfig struct {
+	Dir      string        `yaml:"dir"`      // 快照存放目录
+	Interval time.Duration `yaml:"interval"` // 定时快照间隔，0 表示不定时备份
+	Keep     int           `yaml:"keep"`     // 保留最近的快照数量
+}
+
+// TrashConfig 回收站配置
+type TrashConfig struct {
+	Retention time.Duration `yaml:"retention"` // 删除的记录保留多久后彻底清除，0 表示不自动清除
+}
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins"` // "*" 表示允许所有来源
+}
+
+// OAuth2 state 的存储方式
+const (
+	OAuth2StateStoreDatabase = "database" // 保存在数据库中，多实例部署时共享
+	OAuth2StateStoreMemory   = "memory"   // 保存在进程内存中，仅适用于单实例
+)
+
+// OAuth2Config 第三方登录配置。appId 等四项为集市 OAuth2 的配置，
+// 注册为名为 market 的提供方；其他提供方在 providers 中配置
+type OAuth2Config struct {
+	AppID            string           `yaml:"appId"`
+	AppSecret        string           `yaml:"appSecret"`
+	TokenURL         string           `yaml:"tokenURL"`
+	UserInfoURL      string           `yaml:"userInfoURL"`
+	MarketTrustEmail bool             `yaml:"marketTrustEmail"` // 集市账号按邮箱匹配已有用户，见 OAuth2Provider.TrustEmail
+	Providers        []OAuth2Provider `yaml:"providers"`
+	StateStore       string           `yaml:"stateStore"`
+	StateTTL         time.Duration    `yaml:"stateTTL"` // 发起授权到回调的最长时间
+}
+
+// MarketProviderName 集市 OAuth2 提供方的名称
+const MarketProviderName = "market"
+
+// OAuth2Provider 一个 OpenID Connect 提供方
+type OAuth2Provider struct {
+	Name         string   `yaml:"name"` // 接口和账号绑定中使用的标识，小写字母、数字和连字符
+	DisplayName  string   `yaml:"displayName"`
+	Issuer       string   `yaml:"issuer"` // 从 issuer/.well-known/openid-configuration 读取各端点
+	ClientID     string   `yaml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret"`
+	Scopes       []string `yaml:"scopes"`      // 默认 openid email profile
+	RedirectURL  string   `yaml:"redirectURL"` // 默认 publicURL/auth/oauth2/callback
+	// TrustEmail 为 true 时，首次登录的外部账号若邮箱已验证且与已有用户相同则直接绑定该用户。
+	// 只应对能保证邮箱归属的提供方开启，否则他人可以借同名邮箱登录已有账号
+	TrustEmail bool `yaml:"trustEmail"`
+}
+
+// Enabled 是否配置了 OAuth2 登录
+func (c OAuth2Config) Enabled() bool {
+	return c.AppID != "" || c.AppSecret != "" || c.TokenURL != "" || c.UserInfoURL != ""
+}
+
+// AllowAllOrigins 是否允许所有跨域来源
+func (c CORSConfig) AllowAllOrigins() bool {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsProduction 是否运行在生产环境
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Env:       EnvDevelopment,
+		PublicURL: "http://localhost:5173",
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: DatabaseConfig{
+			AutoMigrate: true,
+		},
+		JWT: JWTConfig{
+			TTL:        15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+			Issuer:     "xuan-ke-tong",
+		},
+		Auth: AuthConfig{
+			PasswordResetTTL:     time.Hour,
+			EmailVerificationTTL: 48 * time.Hour,
+			Registration: RegistrationConfig{
+				Mode: RegistrationOpen,
+			},
+			Lockout: LockoutConfig{
+				MaxAttempts:    5,
+				IPMaxAttempts:  50,
+				BaseDuration:   time.Minute,
+				MaxDuration:    time.Hour,
+				Window:         24 * time.Hour,
+				EventRetention: 30 * 24 * time.Hour,
+			},
+			TwoFactor: TwoFactorConfig{
+				Issuer:       "选课通",
+				ChallengeTTL: 5 * time.Minute,
+			},
+		},
+		Mail: MailConfig{
+			Driver:    MailDriverFile,
+			From:      "选课通 <no-reply@localhost>",
+			OutboxDir: "data/outbox",
+			SMTP: SMTPConfig{
+				Port: 587,
+				TLS:  "starttls",
+			},
+		},
+		// 前端和管理后台的开发服务器。OAuth2 登录需要携带 Cookie，不能允许所有来源
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:5173", "http://localhost:3000"},
+		},
+		Backup: BackupConfig{
+			Dir:  "data/backups",
+			Keep: 7,
+		},
+		Trash: TrashConfig{
+			Retention: 30 * 24 * time.Hour,
+		},
+		OAuth2: OAuth2Config{
+			StateStore: OAuth2StateStoreDatabase,
+			StateTTL:   10 * time.Minute,
+		},
+	}
+}
+
+// Load 依次从默认值、配置文件、环境变量（含 .env）和命令行参数加载配置，
+// 后者覆盖前者。返回的字符串切片为命令行中未被解析的位置参数
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("xuan-ke-tong", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "配置文件路径 (YAML)")
+	env := fs.String("env", "", "运行环境: development | production")
+	addr := fs.String("addr", "", "HTTP 监听地址，例如 :8080")
+	dbDriver := fs.String("db-driver", "", "数据库驱动: sqlite | postgres | mysql")
+	dbDSN := fs.String("db-dsn", "", "数据库连接串，sqlite 下为文件路径")
+	corsOrigins := fs.String("cors-origins", "", "允许的跨域来源，逗号分隔")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	// .env 文件可选，缺失时忽略
+	_ = godotenv.Load(".env")
+
+	path := *configFile
+	if path == "" {
+		if _, err := os.Stat("config.yaml"); err == nil {
+			path = "config.yaml"
+		}
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	// 命令行参数优先级最高
+	if *env != "" {
+		cfg.Env = *env
+	}
+	if *addr != "" {
+		cfg.Server.Addr = *addr
+	}
+	if *dbDriver != "" {
+		cfg.Database.Driver = *dbDriver
+	}
+	if *dbDSN != "" {
+		cfg.Database.DSN = *dbDSN
+	}
+	if *corsOrigins != "" {
+		cfg.CORS.AllowedOrigins = splitList(*corsOrigins)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// loadEnv 用环境变量覆盖配置，取值无效的变量全部列出后一并返回
+func (c *Config) loadEnv() error {
+	var errs []error
+
+	if v := os.Getenv("APP_ENV"); v != "" {
+		c.Env = v
+	}
+	if v := os.Getenv("PUBLIC_URL"); v != "" {
+		c.PublicURL = v
+	}
+	if v := os.Getenv("SERVER_ADDR"); v != "" {
+		c.Server.Addr = v
+	} else if v := os.Getenv("PORT"); v != "" {
+		c.Server.Addr = ":" + v
+	}
+
+	envDuration("SERVER_READ_TIMEOUT", &c.Server.ReadTimeout, &errs)
+	envDuration("SERVER_READ_HEADER_TIMEOUT", &c.Server.ReadHeaderTimeout, &errs)
+	envDuration("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout, &errs)
+	envDuration("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout, &errs)
+	envDuration("SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout, &errs)
+	if v := os.Getenv("TLS_CERT_FILE"); v != "" {
+		c.Server.TLS.CertFile = v
+	}
+	if v := os.Getenv("TLS_KEY_FILE"); v != "" {
+		c.Server.TLS.KeyFile = v
+	}
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		c.Server.TrustedProxies = splitList(v)
+	}
+
+	if v := os.Getenv("DB_DRIVER"); v != "" {
+		c.Database.Driver = v
+	}
+	if v := os.Getenv("DB_DSN"); v != "" {
+		c.Database.DSN = v
+	}
+	envBool("DB_AUTO_MIGRATE", &c.Database.AutoMigrate, &errs)
+
+	if v := os.Getenv("JWT_SECRET"); v != "" {
+		c.JWT.Secret = v
+	}
+	envDuration("JWT_TTL", &c.JWT.TTL, &errs)
+	envDuration("JWT_REFRESH_TTL", &c.JWT.RefreshTTL, &errs)
+
+	envDuration("PASSWORD_RESET_TTL", &c.Auth.PasswordResetTTL, &errs)
+	envDuration("EMAIL_VERIFICATION_TTL", &c.Auth.EmailVerificationTTL, &errs)
+	envInt("LOGIN_MAX_ATTEMPTS", &c.Auth.Lockout.MaxAttempts, &errs)
+	envInt("LOGIN_IP_MAX_ATTEMPTS", &c.Auth.Lockout.IPMaxAttempts, &errs)
+	envDuration("LOGIN_LOCKOUT_BASE", &c.Auth.Lockout.BaseDuration, &errs)
+	envDuration("LOGIN_LOCKOUT_MAX", &c.Auth.Lockout.MaxDuration, &errs)
+	envDuration("LOGIN_FAILURE_WINDOW", &c.Auth.Lockout.Window, &errs)
+	envDuration("LOGIN_EVENT_RETENTION", &c.Auth.Lockout.EventRetention, &errs)
+	envDuration("TWO_FACTOR_CHALLENGE_TTL", &c.Auth.TwoFactor.ChallengeTTL, &errs)
+	if v := os.Getenv("TWO_FACTOR_ISSUER"); v != "" {
+		c.Auth.TwoFactor.Issuer = v
+	}
+	if v := os.Getenv("REGISTRATION_MODE"); v != "" {
+		c.Auth.Registration.Mode = v
+	}
+	if v := os.Getenv("REGISTRATION_ALLOWED_DOMAINS"); v != "" {
+		c.Auth.Registration.AllowedDomains = splitList(v)
+	}
+
+	for name, dst := range map[string]*string{
+		"MAIL_DRIVER":     &c.Mail.Driver,
+		"MAIL_FROM":       &c.Mail.From,
+		"MAIL_OUTBOX_DIR": &c.Mail.OutboxDir,
+		"SMTP_HOST":       &c.Mail.SMTP.Host,
+		"SMTP_USERNAME":   &c.Mail.SMTP.Username,
+		"SMTP_PASSWORD":   &c.Mail.SMTP.Password,
+		"SMTP_TLS":        &c.Mail.SMTP.TLS,
+	} {
+		if v := os.Getenv(name); v != "" {
+			*dst = v
+		}
+	}
+	envInt("SMTP_PORT", &c.Mail.SMTP.Port, &errs)
+
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		c.CORS.AllowedOrigins = splitList(v)
+	}
+
+	if v := os.Getenv("BACKUP_DIR"); v != "" {
+		c.Backup.Dir = v
+	}
+	envDuration("BACKUP_INTERVAL", &c.Backup.Interval, &errs)
+	envInt("BACKUP_KEEP", &c.Backup.Keep, &errs)
+
+	envDuration("TRASH_RETENTION", &c.Trash.Retention, &errs)
+
+	if v := os.Getenv("OAUTH2_MARKET_APP_ID"); v != "" {
+		c.OAuth2.AppID = v
+	}
+	if v := os.Getenv("OAUTH2_MARKET_APP_SECRET"); v != "" {
+		c.OAuth2.AppSecret = v
+	}
+	if v := os.Getenv("OAUTH2_MARKET_TOKEN_URL"); v != "" {
+		c.OAuth2.TokenURL = v
+	}
+	if v := os.Getenv("OAUTH2_MARKET_USERINFO_URL"); v != "" {
+		c.OAuth2.UserInfoURL = v
+	}
+	envBool("OAUTH2_MARKET_TRUST_EMAIL", &c.OAuth2.MarketTrustEmail, &errs)
+	if v := os.Getenv("OAUTH2_STATE_STORE"); v != "" {
+		c.OAuth2.StateStore = v
+	}
+	envDuration("OAUTH2_STATE_TTL", &c.OAuth2.StateTTL, &errs)
+
+	return errors.Join(errs...)
+}
+
+// envDuration 在环境变量 name 非空时按 time.ParseDuration 解析到 dst，无效时记入 errs
+func envDuration(name string, dst *time.Duration, errs *[]error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s 取值无效: %s", name, v))
+		return
+	}
+	*dst = d
+}
+
+// envInt 在环境变量 name 非空时解析整数到 dst，无效时记入 errs
+func envInt(name string, dst *int, errs *[]error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s 取值无效: %s", name, v))
+		return
+	}
+	*dst = n
+}
+
+// envBool 在环境变量 name 非空时解析布尔值到 dst，无效时记入 errs
+func envBool(name string, dst *bool, errs *[]error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s 取值无效: %s", name, v))
+		return
+	}
+	*dst = b
+}
+
+// Validate 校验配置并补全开发环境下允许缺省的值
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.Env {
+	case EnvDevelopment, EnvProduction:
+	default:
+		errs = append(errs, fmt.Errorf("env 必须为 %s 或 %s", EnvDevelopment, EnvProduction))
+	}
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr 不能为空"))
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.ReadHeaderTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		errs = append(errs, errors.New("server 的超时时间不能为负数"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout 必须大于 0"))
+	}
+	if c.Server.TLS.Enabled() && (c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls 需要同时配置 certFile 和 keyFile"))
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trustedProxies 中的 %q 不是有效的 IP 或 CIDR", proxy))
+		}
+	}
+
+	if driver, err := c.Database.resolveDriver(); err != nil {
+		errs = append(errs, err)
+	} else if driver != DriverSQLite && c.Database.DSN == "" {
+		errs = append(errs, fmt.Errorf("使用 %s 时必须配置 database.dsn (DB_DSN)", driver))
+	}
+
+	if len(c.JWT.Keys) > 0 {
+		errs = append(errs, c.validateJWTKeys()...)
+	} else {
+		if c.JWT.Secret == "" && !c.IsProduction() {
+			c.JWT.Secret = devJWTSecret
+		}
+		switch {
+		case c.JWT.Secret == "":
+			errs = append(errs, errors.New("生产环境必须配置 jwt.secret (JWT_SECRET) 或 jwt.keys"))
+		case c.IsProduction() && c.JWT.Secret == devJWTSecret:
+			errs = append(errs, errors.New("生产环境不能使用开发用的 jwt.secret"))
+		case c.IsProduction() && len(c.JWT.Secret) < 32:
+			errs = append(errs, errors.New("jwt.secret 长度至少为 32 字节"))
+		}
+	}
+	if c.JWT.TTL <= 0 {
+		errs = append(errs, errors.New("jwt.ttl 必须大于 0"))
+	}
+	if c.JWT.RefreshTTL < c.JWT.TTL {
+		errs = append(errs, errors.New("jwt.refreshTTL 不能小于 jwt.ttl"))
+	}
+
+	if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("publicURL 必须是 http(s) 地址: %s", c.PublicURL))
+	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		errs = append(errs, errors.New("auth.passwordResetTTL 必须大于 0"))
+	}
+	if c.Auth.EmailVerificationTTL <= 0 {
+		errs = append(errs, errors.New("auth.emailVerificationTTL 必须大于 0"))
+	}
+	if lo := c.Auth.Lockout; lo.MaxAttempts < 0 || lo.IPMaxAttempts < 0 {
+		errs = append(errs, errors.New("auth.lockout 的失败次数阈值不能为负数"))
+	} else if lo.BaseDuration <= 0 || lo.MaxDuration < lo.BaseDuration {
+		errs = append(errs, errors.New("auth.lockout.baseDuration 必须大于 0 且不大于 maxDuration"))
+	} else if lo.Window <= 0 || lo.EventRetention <= 0 {
+		errs = append(errs, errors.New("auth.lockout.window 和 eventRetention 必须大于 0"))
+	}
+	if c.Auth.TwoFactor.Issuer == "" || strings.Contains(c.Auth.TwoFactor.Issuer, ":") {
+		errs = append(errs, errors.New("auth.twoFactor.issuer 不能为空，也不能包含冒号"))
+	}
+	if c.Auth.TwoFactor.ChallengeTTL <= 0 {
+		errs = append(errs, errors.New("auth.twoFactor.challengeTTL 必须大于 0"))
+	}
+	switch c.Auth.Registration.Mode {
+	case RegistrationOpen, RegistrationInvite:
+	case RegistrationDomain:
+		if len(c.Auth.Registration.AllowedDomains) == 0 {
+			errs = append(errs, errors.New("auth.registration.mode 为 domain 时必须配置 allowedDomains"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.registration.mode 必须为 %s、%s 或 %s", RegistrationOpen, RegistrationDomain, RegistrationInvite))
+	}
+
+	if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+		errs = append(errs, fmt.Errorf("mail.from 不是有效的邮件地址: %s", c.Mail.From))
+	}
+	switch c.Mail.Driver {
+	case MailDriverFile:
+		if c.Mail.OutboxDir == "" {
+			errs = append(errs, errors.New("mail.outboxDir 不能为空"))
+		}
+	case MailDriverSMTP:
+		if c.Mail.SMTP.Host == "" || c.Mail.SMTP.Port <= 0 {
+			errs = append(errs, errors.New("使用 smtp 发送邮件时必须配置 mail.smtp.host 和 port"))
+		}
+		switch c.Mail.SMTP.TLS {
+		case "starttls", "tls", "none":
+		default:
+			errs = append(errs, errors.New("mail.smtp.tls 必须为 starttls、tls 或 none"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail.driver 必须为 %s 或 %s", MailDriverSMTP, MailDriverFile))
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("cors.allowedOrigins 不能为空"))
+	}
+	if c.IsProduction() && c.CORS.AllowAllOrigins() {
+		errs = append(errs, errors.New("生产环境不能允许所有跨域来源，请配置 cors.allowedOrigins"))
+	} else if c.CORS.AllowAllOrigins() && (c.OAuth2.Enabled() || len(c.OAuth2.Providers) > 0) {
+		// 允许所有来源时不能携带 Cookie，浏览器不会保存和发送 OAuth2 的绑定 Cookie
+		errs = append(errs, errors.New("启用 OAuth2 登录时 cors.allowedOrigins 不能为 *，请列出前端的来源"))
+	}
+
+	if c.Backup.Dir == "" {
+		errs = append(errs, errors.New("backup.dir 不能为空"))
+	}
+	if c.Backup.Interval < 0 {
+		errs = append(errs, errors.New("backup.interval 不能为负数"))
+	}
+	if c.Backup.Keep < 1 {
+		errs = append(errs, errors.New("backup.keep 至少为 1"))
+	}
+
+	if c.Trash.Retention < 0 {
+		errs = append(errs, errors.New("trash.retention 不能为负数"))
+	}
+
+	if c.OAuth2.Enabled() {
+		if c.OAuth2.AppID == "" || c.OAuth2.AppSecret == "" || c.OAuth2.TokenURL == "" || c.OAuth2.UserInfoURL == "" {
+			errs = append(errs, errors.New("oauth2 配置不完整：appId、appSecret、tokenURL、userInfoURL 均为必填"))
+		}
+	}
+	errs = append(errs, c.validateOAuth2Providers()...)
+	switch c.OAuth2.StateStore {
+	case OAuth2StateStoreDatabase, OAuth2StateStoreMemory:
+	default:
+		errs = append(errs, fmt.Errorf("oauth2.stateStore 必须为 %s 或 %s", OAuth2StateStoreDatabase, OAuth2StateStoreMemory))
+	}
+	if c.OAuth2.StateTTL <= 0 {
+		errs = append(errs, errors.New("oauth2.stateTTL 必须大于 0"))
+	}
+
+	return errors.Join(errs...)
+}
+
+var providerNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,31}$`)
+
+// validateOAuth2Providers 校验 OpenID Connect 提供方，端点在首次使用时才从 issuer 读取
+func (c *Config) validateOAuth2Providers() []error {
+	var errs []error
+	seen := map[string]bool{MarketProviderName: c.OAuth2.Enabled()}
+	for i, p := range c.OAuth2.Providers {
+		if !providerNamePattern.MatchString(p.Name) {
+			errs = append(errs, fmt.Errorf("oauth2.providers[%d].name 只能包含小写字母、数字和连字符: %q", i, p.Name))
+		} else if seen[p.Name] {
+			errs = append(errs, fmt.Errorf("oauth2.providers[%d].name 重复: %s", i, p.Name))
+		}
+		seen[p.Name] = true
+		if p.Issuer == "" || p.ClientID == "" || p.ClientSecret == "" {
+			errs = append(errs, fmt.Errorf("oauth2.providers[%d] 配置不完整：issuer、clientId、clientSecret 均为必填", i))
+		}
+	}
+	return errs
+}
+
+// validateJWTKeys 校验密钥环的结构，密钥文件在创建令牌管理器时读取
+func (c *Config) validateJWTKeys() []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, key := range c.JWT.Keys {
+		name := fmt.Sprintf("jwt.keys[%d]", i)
+		if key.ID == "" {
+			errs = append(errs, fmt.Errorf("%s.id 不能为空", name))
+		} else if seen[key.ID] {
+			errs = append(errs, fmt.Errorf("%s.id 重复: %s", name, key.ID))
+		}
+		seen[key.ID] = true
+
+		switch key.Algorithm {
+		case JWTAlgHS256:
+			if key.Secret == "" {
+				errs = append(errs, fmt.Errorf("%s 使用 HS256 时必须配置 secret", name))
+			} else if c.IsProduction() && len(key.Secret) < 32 {
+				errs = append(errs, fmt.Errorf("%s.secret 长度至少为 32 字节", name))
+			}
+		case JWTAlgRS256, JWTAlgEdDSA:
+			if key.PrivateKeyFile == "" && key.PublicKeyFile == "" {
+				errs = append(errs, fmt.Errorf("%s 使用 %s 时必须配置 privateKeyFile 或 publicKeyFile", name, key.Algorithm))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s.algorithm 必须为 %s、%s 或 %s", name, JWTAlgHS256, JWTAlgRS256, JWTAlgEdDSA))
+		}
+
+		if !key.NotAfter.IsZero() && !key.NotAfter.After(key.NotBefore) {
+			errs = append(errs, fmt.Errorf("%s.notAfter 必须晚于 notBefore", name))
+		}
+	}
+	return errs
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
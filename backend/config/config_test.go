@@ -0,0 +1,47 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"xuan-ke-tong/config"
+)
+
+func TestLoadEnvReportsEveryInvalidValue(t *testing.T) {
+	t.Chdir(t.TempDir()) // 不读取工作目录中的 .env 和 config.yaml
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("JWT_TTL", "30m")
+	t.Setenv("TRASH_RETENTION", "forever")
+	t.Setenv("OAUTH2_STATE_TTL", "10")
+	t.Setenv("BACKUP_KEEP", "many")
+	t.Setenv("DB_AUTO_MIGRATE", "sometimes")
+
+	_, _, err := config.Load(nil)
+	if err == nil {
+		t.Fatal("无效的环境变量没有报错")
+	}
+	for _, name := range []string{"TRASH_RETENTION", "OAUTH2_STATE_TTL", "BACKUP_KEEP", "DB_AUTO_MIGRATE"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("错误中没有指出 %s: %v", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "JWT_TTL") {
+		t.Errorf("有效的 JWT_TTL 被报告为无效: %v", err)
+	}
+}
+
+func TestLoadEnvDurations(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("JWT_TTL", "30m")
+	t.Setenv("TRASH_RETENTION", "720h")
+	t.Setenv("OAUTH2_STATE_TTL", "5m")
+
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.JWT.TTL != 30*time.Minute || cfg.Trash.Retention != 720*time.Hour || cfg.OAuth2.StateTTL != 5*time.Minute {
+		t.Fatalf("JWT.TTL=%v Trash.Retention=%v OAuth2.StateTTL=%v", cfg.JWT.TTL, cfg.Trash.Retention, cfg.OAuth2.StateTTL)
+	}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// 暂先使用不依赖cgo的SQLite驱动，解决Windows适配问题
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 支持的数据库驱动名称，与 gorm Dialector.Name() 的返回值保持一致
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// 默认的本地开发数据库文件
+const defaultSQLitePath = "data/test.db"
+
+// DatabaseConfig 描述数据库后端的选择
+type DatabaseConfig struct {
+	Driver      string `yaml:"driver"`      // sqlite, postgres, mysql；为空时根据 DSN 推断
+	DSN         string `yaml:"dsn"`         // 驱动对应的连接串；sqlite 下为数据库文件路径
+	AutoMigrate bool   `yaml:"autoMigrate"` // 启动时是否自动执行未执行的迁移
+}
+
+// resolveDriver 根据显式配置或 DSN 的形式确定数据库驱动
+func (c DatabaseConfig) resolveDriver() (string, error) {
+	driver := strings.ToLower(strings.TrimSpace(c.Driver))
+	switch driver {
+	case DriverSQLite, "sqlite3":
+		return DriverSQLite, nil
+	case DriverPostgres, "postgresql", "pg":
+		return DriverPostgres, nil
+	case DriverMySQL, "mariadb":
+		return DriverMySQL, nil
+	case "":
+	default:
+		return "", fmt.Errorf("不支持的数据库驱动: %s", c.Driver)
+	}
+
+	dsn := strings.TrimSpace(c.DSN)
+	switch {
+	case dsn == "":
+		return DriverSQLite, nil
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"),
+		strings.Contains(dsn, "host=") && strings.Contains(dsn, "dbname="):
+		return DriverPostgres, nil
+	case strings.HasPrefix(dsn, "mysql://"), strings.Contains(dsn, "@tcp("), strings.Contains(dsn, "@unix("):
+		return DriverMySQL, nil
+	default:
+		return DriverSQLite, nil
+	}
+}
+
+// Dialector 返回与配置对应的 gorm 方言
+func (c DatabaseConfig) Dialector() (gorm.Dialector, error) {
+	driver, err := c.resolveDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	switch driver {
+	case DriverPostgres:
+		return postgres.Open(c.DSN), nil
+	case DriverMySQL:
+		dsn := strings.TrimPrefix(c.DSN, "mysql://")
+		// 时间字段需要 parseTime 才能扫描到 time.Time
+		if !strings.Contains(dsn, "parseTime=") {
+			if strings.Contains(dsn, "?") {
+				dsn += "&parseTime=true"
+			} else {
+				dsn += "?parseTime=true"
+			}
+		}
+		return mysql.Open(dsn), nil
+	default:
+		dbPath := c.DSN
+		if dbPath == "" {
+			dbPath = defaultSQLitePath
+		}
+		// Create data directory if it doesn't exist
+		if dir := filepath.Dir(sqliteFilePath(dbPath)); dir != "." && dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create data directory: %v", err)
+			}
+		}
+		return sqlite.Open(withForeignKeys(dbPath)), nil
+	}
+}
+
+// withForeignKeys 为 SQLite 连接串加上 foreign_keys 编译指示。
+// SQLite 默认不检查外键，且该设置按连接生效，必须写在连接串中才能作用于连接池里的每个连接
+func withForeignKeys(dsn string) string {
+	if strings.Contains(dsn, "foreign_keys") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_pragma=foreign_keys(1)"
+	}
+	return dsn + "?_pragma=foreign_keys(1)"
+}
+
+// SQLitePath 返回 SQLite 数据库文件的路径，其他驱动返回错误
+func (c DatabaseConfig) SQLitePath() (string, error) {
+	driver, err := c.resolveDriver()
+	if err != nil {
+		return "", err
+	}
+	if driver != DriverSQLite {
+		return "", fmt.Errorf("当前数据库驱动为 %s，仅 SQLite 支持该操作", driver)
+	}
+	if c.DSN == "" {
+		return defaultSQLitePath, nil
+	}
+	return sqliteFilePath(c.DSN), nil
+}
+
+// sqliteFilePath 去掉 file: 前缀和查询参数，得到数据库文件的路径
+func sqliteFilePath(dsn string) string {
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// ConnectDatabase 按配置打开数据库连接
+func ConnectDatabase(cfg DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := cfg.Dialector()
+	if err != nil {
+		return nil, err
+	}
+
+	// 按唯一键查找不存在的记录属于正常流程，不记为错误日志
+	database, err := gorm.Open(dialector, &gorm.Config{
+		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+			Colorful:                  true,
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("使用数据库驱动: %s", database.Dialector.Name())
+
+	return database, nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import "strings"
+
+// columnTypes 描述建表语句中随方言变化的列类型
+type columnTypes struct {
+	PrimaryKey string // 自增主键
+	ForeignKey string // 引用自增主键的列，类型需与主键一致
+	String     string // 需要唯一约束或索引的短字符串
+	Text       string // 长文本
+	Integer    string
+	Float      string
+	Timestamp  string
+	Now        string // 时间列的默认值，精度须与 Timestamp 一致
+}
+
+var dialectColumnTypes = map[string]columnTypes{
+	DriverSQLite: {
+		PrimaryKey: "INTEGER PRIMARY KEY AUTOINCREMENT",
+		ForeignKey: "INTEGER",
+		String:     "TEXT",
+		Text:       "TEXT",
+		Integer:    "INTEGER",
+		Float:      "REAL",
+		Timestamp:  "DATETIME",
+		Now:        "CURRENT_TIMESTAMP",
+	},
+	DriverPostgres: {
+		PrimaryKey: "BIGSERIAL PRIMARY KEY",
+		ForeignKey: "BIGINT",
+		String:     "VARCHAR(191)",
+		Text:       "TEXT",
+		Integer:    "BIGINT",
+		Float:      "DOUBLE PRECISION",
+		Timestamp:  "TIMESTAMPTZ",
+		Now:        "CURRENT_TIMESTAMP",
+	},
+	DriverMySQL: {
+		PrimaryKey: "BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY",
+		ForeignKey: "BIGINT UNSIGNED",
+		String:     "VARCHAR(191)",
+		Text:       "LONGTEXT",
+		Integer:    "BIGINT",
+		Float:      "DOUBLE",
+		Timestamp:  "DATETIME(3)",
+		// MySQL 要求默认值的精度与列一致，否则报 1067 Invalid default value
+		Now: "CURRENT_TIMESTAMP(3)",
+	},
+}
+
+// DDLFor 将建表语句中的类型占位符替换为指定方言的写法。
+// 支持的占位符：{{pk}} {{fk}} {{string}} {{text}} {{int}} {{float}} {{timestamp}} {{now}}
+func DDLFor(dialect, stmt string) string {
+	types, ok := dialectColumnTypes[dialect]
+	if !ok {
+		types = dialectColumnTypes[DriverSQLite]
+	}
+
+	return strings.NewReplacer(
+		"{{pk}}", types.PrimaryKey,
+		"{{fk}}", types.ForeignKey,
+		"{{string}}", types.String,
+		"{{text}}", types.Text,
+		"{{int}}", types.Integer,
+		"{{float}}", types.Float,
+		"{{timestamp}}", types.Timestamp,
+		"{{now}}", types.Now,
+	).Replace(stmt)
+}
+
+// CastInteger 返回将表达式转换为整数的 SQL 片段
+func CastInteger(dialect, expr string) string {
+	if dialect == DriverMySQL {
+		return "CAST(" + expr + " AS SIGNED)"
+	}
+	return "CAST(" + expr + " AS INTEGER)"
+}
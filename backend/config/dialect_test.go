@@ -0,0 +1,107 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/testdb"
+
+	"gorm.io/gorm"
+)
+
+func TestDDLForReplacesEveryPlaceholder(t *testing.T) {
+	stmt := "{{pk}} {{fk}} {{string}} {{text}} {{int}} {{float}} {{timestamp}} {{now}}"
+	for _, dialect := range []string{config.DriverSQLite, config.DriverPostgres, config.DriverMySQL, "unknown"} {
+		got := config.DDLFor(dialect, stmt)
+		for _, placeholder := range []string{"{{", "}}"} {
+			if strings.Contains(got, placeholder) {
+				t.Errorf("%s: 仍有未替换的占位符: %s", dialect, got)
+			}
+		}
+	}
+	if got, want := config.DDLFor("unknown", stmt), config.DDLFor(config.DriverSQLite, stmt); got != want {
+		t.Errorf("未知方言应按 SQLite 处理: %s", got)
+	}
+}
+
+func TestCastInteger(t *testing.T) {
+	cases := map[string]string{
+		config.DriverSQLite:   "CAST(x AS INTEGER)",
+		config.DriverPostgres: "CAST(x AS INTEGER)",
+		config.DriverMySQL:    "CAST(x AS SIGNED)",
+	}
+	for dialect, want := range cases {
+		if got := config.CastInteger(dialect, "x"); got != want {
+			t.Errorf("CastInteger(%s) = %s，期望 %s", dialect, got, want)
+		}
+	}
+}
+
+// 生成的建表语句能在各数据库上执行，{{fk}} 与 {{pk}} 的类型一致，外键可以建立并生效
+func TestDDLForExecutes(t *testing.T) {
+	testdb.Each(t, func(t *testing.T, db *gorm.DB) {
+		dialect := db.Dialector.Name()
+		for _, stmt := range []string{`
+			CREATE TABLE ddl_parents (
+				id {{pk}},
+				code {{string}} NOT NULL UNIQUE,
+				body {{text}},
+				amount {{int}} NOT NULL DEFAULT 0,
+				ratio {{float}},
+				created_at {{timestamp}} DEFAULT {{now}}
+			)`, `
+			CREATE TABLE ddl_children (
+				id {{pk}},
+				parent_id {{fk}} NOT NULL,
+				FOREIGN KEY (parent_id) REFERENCES ddl_parents(id)
+			)`,
+		} {
+			if err := db.Exec(config.DDLFor(dialect, stmt)).Error; err != nil {
+				t.Fatalf("建表失败: %v", err)
+			}
+		}
+
+		if err := db.Exec("INSERT INTO ddl_parents (code, amount, ratio) VALUES (?, ?, ?)", "a", 7, 0.5).Error; err != nil {
+			t.Fatal(err)
+		}
+		var parentID uint
+		if err := db.Raw("SELECT id FROM ddl_parents WHERE code = ?", "a").Scan(&parentID).Error; err != nil || parentID == 0 {
+			t.Fatalf("读取自增主键失败: id=%d err=%v", parentID, err)
+		}
+		if err := db.Exec("INSERT INTO ddl_children (parent_id) VALUES (?)", parentID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Exec("INSERT INTO ddl_children (parent_id) VALUES (?)", parentID+100).Error; err == nil {
+			t.Fatal("引用不存在的父行时应违反外键约束")
+		}
+
+		// 未指定时间时取默认值
+		var missing int64
+		if err := db.Raw("SELECT COUNT(*) FROM ddl_parents WHERE created_at IS NULL").Scan(&missing).Error; err != nil || missing != 0 {
+			t.Fatalf("created_at 没有默认值: %d 行为空, %v", missing, err)
+		}
+
+		var engagement int64
+		if err := db.Raw("SELECT " + config.CastInteger(dialect, "amount * ratio + 10") + " FROM ddl_parents").Scan(&engagement).Error; err != nil {
+			t.Fatal(err)
+		}
+		// 7 * 0.5 + 10 = 13.5，各数据库对小数部分的处理不同（截断或四舍五入）
+		if engagement != 13 && engagement != 14 {
+			t.Fatalf("CastInteger 结果为 %d", engagement)
+		}
+	})
+}
+
+// MySQL 时间列的默认值精度须与列一致
+func TestDDLForNowMatchesTimestampPrecision(t *testing.T) {
+	cases := map[string]string{
+		config.DriverSQLite:   "DATETIME DEFAULT CURRENT_TIMESTAMP",
+		config.DriverPostgres: "TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP",
+		config.DriverMySQL:    "DATETIME(3) DEFAULT CURRENT_TIMESTAMP(3)",
+	}
+	for dialect, want := range cases {
+		if got := config.DDLFor(dialect, "{{timestamp}} DEFAULT {{now}}"); got != want {
+			t.Errorf("%s: %s，期望 %s", dialect, got, want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 未指定有效期时的默认天数和允许的最长天数
+	defaultAccessTokenDays = 90
+	maxAccessTokenDays     = 365
+	// 每个用户同时有效的令牌数量上限
+	maxAccessTokensPerUser = 20
+)
+
+type CreateAccessTokenInput struct {
+	Name          string   `json:"name" binding:"required,max=50"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expiresInDays" binding:"min=0"` // 0 表示默认的 90 天
+}
+
+// AccessTokenController 处理用户自助管理个人访问令牌
+type AccessTokenController struct {
+	tokens repository.AccessTokenRepository
+	authz  *rbac.Authorizer
+}
+
+func NewAccessTokenController(repos *repository.Repositories, authz *rbac.Authorizer) *AccessTokenController {
+	return &AccessTokenController{tokens: repos.AccessTokens, authz: authz}
+}
+
+// ListScopes 返回当前用户的角色可以授予令牌的权限范围
+func (ctrl *AccessTokenController) ListScopes(c *gin.Context) {
+	role := c.GetString("role")
+	scopes := []models.ScopeInfo{}
+	for _, scope := range models.Scopes {
+		if ctrl.grantable(role, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": scopes})
+}
+
+// ListTokens 列出当前用户未吊销的令牌，不含令牌明文
+func (ctrl *AccessTokenController) ListTokens(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	tokens, err := ctrl.tokens.ListByUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取令牌列表失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+// CreateToken 创建令牌，明文只在本次响应中返回
+func (ctrl *AccessTokenController) CreateToken(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+
+	var input CreateAccessTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.ExpiresInDays == 0 {
+		input.ExpiresInDays = defaultAccessTokenDays
+	}
+	if input.ExpiresInDays > maxAccessTokenDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("有效期最长为 %d 天", maxAccessTokenDays)})
+		return
+	}
+
+	role := c.GetString("role")
+	// 否则未通过两步验证的会话可以借令牌绕过两步验证
+	if ctrl.authz.RequiresTwoFactor(role) && !c.GetBool("twoFactor") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "当前角色要求两步验证，请通过两步验证登录后再创建令牌", "twoFactorRequired": true})
+		return
+	}
+	scopes := models.PermissionSet{}
+	seen := make(map[string]bool)
+	for _, name := range input.Scopes {
+		scope, ok := models.FindScope(name)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未知的权限范围: " + name})
+			return
+		}
+		if !ctrl.grantable(role, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "当前角色不能授予权限范围: " + name})
+			return
+		}
+		if !seen[name] {
+			seen[name] = true
+			scopes = append(scopes, name)
+		}
+	}
+
+	now := time.Now()
+	count, err := ctrl.tokens.CountActiveByUser(userID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建令牌失败"})
+		return
+	}
+	if count >= maxAccessTokensPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("最多同时拥有 %d 个有效令牌，请先吊销不再使用的令牌", maxAccessTokensPerUser)})
+		return
+	}
+
+	secret, _, err := utils.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建令牌失败"})
+		return
+	}
+	plaintext := models.AccessTokenPrefix + secret
+	token := models.AccessToken{
+		UserID:    userID,
+		Name:      input.Name,
+		TokenHash: utils.HashOpaqueToken(plaintext),
+		Hint:      plaintext[len(plaintext)-4:],
+		Scopes:    scopes,
+		ExpiresAt: now.AddDate(0, 0, input.ExpiresInDays),
+	}
+	if c.GetBool("twoFactor") {
+		token.TwoFactorAt = &now
+	}
+	if err := ctrl.tokens.Create(&token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "令牌已创建，请立即保存，之后无法再次查看",
+		"token":   plaintext,
+		"data":    token,
+	})
+}
+
+// RevokeToken 吊销当前用户的令牌，立即失效
+func (ctrl *AccessTokenController) RevokeToken(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	id, ok := paramID(c, "id")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "令牌不存在"})
+		return
+	}
+
+	err := ctrl.tokens.Revoke(userID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "令牌不存在"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销令牌失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "令牌已吊销"})
+}
+
+// grantable 角色能否授予权限范围：不能超出角色本身拥有的权限
+func (ctrl *AccessTokenController) grantable(role string, scope models.ScopeInfo) bool {
+	return scope.Permission == "" || ctrl.authz.Can(role, scope.Permission)
+}
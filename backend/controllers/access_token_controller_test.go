@@ -0,0 +1,88 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/utils"
+)
+
+// 角色要求两步验证时，只有通过两步验证的会话创建的个人访问令牌才能使用权限；
+// 要求之前创建的令牌不会因用户随后启用两步验证而通过
+func TestAccessTokenRequiresTwoFactorSession(t *testing.T) {
+	s := newTestServer(t, nil)
+	client := http.DefaultClient
+	admin := s.createUser("root", "root@example.com", "secret123")
+	if err := s.app.DB.Model(admin).Update("role", models.RoleAdmin).Error; err != nil {
+		t.Fatal(err)
+	}
+	createToken := func(session string) (int, map[string]interface{}) {
+		return s.do(client, http.MethodPost, "/api/v1/auth/tokens", session,
+			map[string]interface{}{"name": "stats", "scopes": []string{models.ScopeReadStats}})
+	}
+
+	status, login := s.do(client, http.MethodPost, "/api/v1/auth/login", "",
+		map[string]string{"username": "root", "password": "secret123"})
+	if status != http.StatusOK {
+		t.Fatalf("登录返回 %d: %v", status, login)
+	}
+	status, created := createToken(login["token"].(string))
+	if status != http.StatusCreated {
+		t.Fatalf("创建令牌返回 %d: %v", status, created)
+	}
+	early := created["token"].(string)
+	if status, body := s.do(client, http.MethodGet, "/api/v1/admin/stats", early, nil); status != http.StatusOK {
+		t.Fatalf("角色不要求两步验证时令牌返回 %d: %v", status, body)
+	}
+
+	// 用户启用两步验证，随后管理员角色要求两步验证
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery, hash, err := utils.NewRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.app.Repos.TwoFactor.SetPendingSecret(admin.ID, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.app.Repos.TwoFactor.Enable(admin.ID, 0, []string{hash}); err != nil {
+		t.Fatal(err)
+	}
+	role, err := s.app.Repos.Roles.FindByName(models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	role.TwoFactorRequiredAt = &now
+	if err := s.app.Repos.Roles.Save(role); err != nil {
+		t.Fatal(err)
+	}
+	s.app.Authz.Invalidate()
+
+	status, body := s.do(client, http.MethodGet, "/api/v1/admin/stats", early, nil)
+	if status != http.StatusForbidden || body["twoFactorRequired"] != true {
+		t.Fatalf("未经两步验证创建的令牌返回 %d: %v，期望 403", status, body)
+	}
+
+	// 通过两步验证登录后创建的令牌可以使用
+	status, challenge := s.do(client, http.MethodPost, "/api/v1/auth/login", "",
+		map[string]string{"username": "root", "password": "secret123"})
+	if status != http.StatusOK || challenge["twoFactorRequired"] != true {
+		t.Fatalf("登录返回 %d: %v，期望两步验证挑战", status, challenge)
+	}
+	status, verified := s.do(client, http.MethodPost, "/api/v1/auth/login/2fa", "",
+		map[string]string{"challengeToken": challenge["challengeToken"].(string), "recoveryCode": recovery})
+	if status != http.StatusOK {
+		t.Fatalf("两步验证返回 %d: %v", status, verified)
+	}
+	status, created = createToken(verified["token"].(string))
+	if status != http.StatusCreated {
+		t.Fatalf("两步验证后创建令牌返回 %d: %v", status, created)
+	}
+	if status, body := s.do(client, http.MethodGet, "/api/v1/admin/stats", created["token"].(string), nil); status != http.StatusOK {
+		t.Fatalf("两步验证会话创建的令牌返回 %d: %v", status, body)
+	}
+}
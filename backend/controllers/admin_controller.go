@@ -1,14 +1,44 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
-	"xuan-ke-tong/config"
+	"time"
 	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
+// AdminController 处理管理后台的用户管理和统计请求
+type AdminController struct {
+	users     repository.UserRepository
+	courses   repository.CourseRepository
+	ratings   repository.RatingRepository
+	comments  repository.CommentRepository
+	roles     repository.RoleRepository
+	sessions  repository.SessionRepository
+	twoFactor repository.TwoFactorRepository
+	authz     *rbac.Authorizer
+	audit     auditor
+}
+
+func NewAdminController(repos *repository.Repositories, authz *rbac.Authorizer) *AdminController {
+	return &AdminController{
+		users:     repos.Users,
+		courses:   repos.Courses,
+		ratings:   repos.Ratings,
+		comments:  repos.Comments,
+		roles:     repos.Roles,
+		sessions:  repos.Sessions,
+		twoFactor: repos.TwoFactor,
+		authz:     authz,
+		audit:     auditor{logs: repos.Audit},
+	}
+}
+
 // StatsResponse 定义了仪表盘统计数据的结构
 type StatsResponse struct {
 	TotalUsers       int64   `json:"total_users"`
@@ -20,30 +50,30 @@ type StatsResponse struct {
 }
 
 // GetStats 获取仪表盘的统计数据
-func GetStats(c *gin.Context) {
+func (ctrl *AdminController) GetStats(c *gin.Context) {
 	var stats StatsResponse
 	var err error
 
 	// 1. 获取用户总数
-	if err = config.DB.Model(&models.User{}).Count(&stats.TotalUsers).Error; err != nil {
+	if stats.TotalUsers, err = ctrl.users.Count(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取用户总数"})
 		return
 	}
 
 	// 2. 获取课程总数
-	if err = config.DB.Model(&models.Course{}).Count(&stats.TotalCourses).Error; err != nil {
+	if stats.TotalCourses, err = ctrl.courses.Count(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取课程总数"})
 		return
 	}
 
 	// 3. 获取评论总数
-	if err = config.DB.Model(&models.Comment{}).Count(&stats.TotalComments).Error; err != nil {
+	if stats.TotalComments, err = ctrl.comments.Count(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取评论总数"})
 		return
 	}
 
 	// 4. 计算平均评分
-	if err = config.DB.Model(&models.Rating{}).Select("avg(score)").Row().Scan(&stats.AverageRating); err != nil {
+	if stats.AverageRating, err = ctrl.ratings.AverageScore(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法计算平均评分"})
 		return
 	}
@@ -65,32 +95,17 @@ func GetStats(c *gin.Context) {
 }
 
 // GetAllUsers 获取所有用户列表（带分页和搜索）
-func GetAllUsers(c *gin.Context) {
+func (ctrl *AdminController) GetAllUsers(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
-	keyword := c.Query("keyword")
-	role := c.Query("role")
-
-	var users []models.User
-	query := config.DB.Model(&models.User{})
-
-	// 搜索功能
-	if keyword != "" {
-		query = query.Where("username LIKE ? OR nickname LIKE ? OR email LIKE ?",
-			"%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%")
-	}
-
-	// 角色筛选
-	if role != "" {
-		query = query.Where("role = ?", role)
-	}
-
-	var total int64
-	query.Count(&total)
 
-	// 分页
-	offset := (page - 1) * pageSize
-	query.Offset(offset).Limit(pageSize).Find(&users)
+	// 搜索、角色筛选和分页
+	users, total, _ := ctrl.users.List(repository.UserFilter{
+		Keyword:  c.Query("keyword"),
+		Role:     c.Query("role"),
+		Page:     page,
+		PageSize: pageSize,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"data":     users,
@@ -101,18 +116,14 @@ func GetAllUsers(c *gin.Context) {
 }
 
 // GetUserByID 根据ID获取用户详情
-func GetUserByID(c *gin.Context) {
-	var user models.User
-	if err := config.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+func (ctrl *AdminController) GetUserByID(c *gin.Context) {
+	user, ok := ctrl.findUser(c)
+	if !ok {
 		return
 	}
 
-	var ratings []models.Rating
-	config.DB.Where("user_id = ?", c.Param("id")).Find(&ratings)
-
-	var comments []models.Comment
-	config.DB.Where("user_id = ?", c.Param("id")).Find(&comments)
+	ratings, _ := ctrl.ratings.ListByUser(user.ID)
+	comments, _ := ctrl.comments.ListByUser(user.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"user":     user,
@@ -122,18 +133,29 @@ func GetUserByID(c *gin.Context) {
 }
 
 // UpdateUser 更新用户信息（管理员功能）
-func UpdateUser(c *gin.Context) {
-	var user models.User
-	if err := config.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+func (ctrl *AdminController) UpdateUser(c *gin.Context) {
+	user, ok := ctrl.findUser(c)
+	if !ok {
+		return
+	}
+
+	// 拥有 user.manage 的其他角色不能修改管理员的邮箱等信息，避免借此接管管理员账户
+	if user.Role == models.RoleAdmin && c.GetString("role") != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以修改管理员账户"})
 		return
 	}
+	before := *user
 
+	// 未提供的字段保持不变；提供的昵称和邮箱与注册时的规则一致
 	var updateData struct {
-		Nickname string `json:"nickname"`
-		Email    string `json:"email"`
-		Role     string `json:"role"`
-		Avatar   string `json:"avatar"`
+		Nickname *string `json:"nickname" binding:"omitnil,min=2,max=30"`
+		Email    *string `json:"email" binding:"omitnil,email"`
+		Role     string  `json:"role"` // 为空时保持不变
+		Avatar   *string `json:"avatar"`
+		// EmailVerified 为空时保持不变
+		EmailVerified *bool `json:"emailVerified"`
+		// CurrentPassword 操作者的当前密码，修改角色时必须提供以确认操作
+		CurrentPassword string `json:"currentPassword"`
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -142,59 +164,179 @@ func UpdateUser(c *gin.Context) {
 	}
 
 	// 检查邮箱是否已被使用
-	if updateData.Email != user.Email {
-		var existingUser models.User
-		if err := config.DB.Where("email = ?", updateData.Email).First(&existingUser).Error; err == nil {
+	if updateData.Email != nil && *updateData.Email != user.Email {
+		taken, err := ctrl.users.EmailTaken(*updateData.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "检查邮箱失败"})
+			return
+		}
+		if taken {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱已被使用"})
 			return
 		}
 	}
 
-	user.Nickname = updateData.Nickname
-	user.Email = updateData.Email
-	user.Role = updateData.Role
-	user.Avatar = updateData.Avatar
+	var change *models.RoleChange
+	if updateData.Role != "" && updateData.Role != user.Role {
+		if change, ok = ctrl.roleChange(c, user, updateData.Role, updateData.CurrentPassword); !ok {
+			return
+		}
+	}
+
+	if updateData.Nickname != nil {
+		user.Nickname = *updateData.Nickname
+	}
+	if updateData.Email != nil {
+		user.Email = *updateData.Email
+	}
+	if updateData.Avatar != nil {
+		user.Avatar = *updateData.Avatar
+	}
+	if updateData.EmailVerified != nil {
+		switch {
+		case !*updateData.EmailVerified:
+			user.EmailVerifiedAt = nil
+		case user.EmailVerifiedAt == nil:
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+	}
 
-	if err := config.DB.Save(&user).Error; err != nil {
+	// 修改角色时与资料在同一事务中写入，任何一步失败都不会留下一半的修改
+	var err error
+	if change != nil {
+		err = ctrl.roles.SaveUserWithRole(user, change)
+	} else {
+		err = ctrl.users.Save(user)
+	}
+	switch {
+	case errors.Is(err, repository.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": "不能撤销最后一位管理员"})
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusConflict, gin.H{"error": "用户角色已被修改，请刷新后重试"})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户失败"})
 		return
 	}
 
+	// 访问令牌中带有角色，吊销会话使新角色立即生效
+	if change != nil {
+		if _, err := ctrl.sessions.RevokeAllByUser(user.ID, 0); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销用户会话失败"})
+			return
+		}
+	}
+	ctrl.audit.record(c, models.AuditUserUpdate, models.AuditTargetUser, user.ID, &before, user)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "用户更新成功",
 		"data":    user,
 	})
 }
 
+// roleChange 检查操作者能否把用户改为 role 并确认其密码，返回待写入的角色变更，失败时写入错误响应。
+// 修改角色需要 role.manage 权限，授予或撤销管理员只能由管理员操作
+func (ctrl *AdminController) roleChange(c *gin.Context, user *models.User, role, password string) (*models.RoleChange, bool) {
+	actorRole := c.GetString("role")
+	if !ctrl.authz.Can(actorRole, models.PermRoleManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有分配角色的权限", "permission": models.PermRoleManage})
+		return nil, false
+	}
+	if role == models.RoleAdmin && actorRole != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以授予管理员角色"})
+		return nil, false
+	}
+	if _, err := ctrl.roles.FindByName(role); errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "角色不存在"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查角色失败"})
+		return nil, false
+	}
+
+	if password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "修改角色需要提供当前密码确认"})
+		return nil, false
+	}
+	actorID, _, _ := currentSession(c)
+	actor, err := ctrl.users.FindByID(actorID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return nil, false
+	}
+	if !checkPassword(c, actor, password) {
+		return nil, false
+	}
+
+	return &models.RoleChange{
+		UserID:   user.ID,
+		Username: user.Username,
+		ActorID:  &actor.ID,
+		FromRole: user.Role,
+		ToRole:   role,
+		Source:   models.RoleChangeAdmin,
+		IP:       c.ClientIP(),
+	}, true
+}
+
 // DeleteUser 删除用户（管理员功能）
-func DeleteUser(c *gin.Context) {
-	var user models.User
-	if err := config.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+func (ctrl *AdminController) DeleteUser(c *gin.Context) {
+	user, ok := ctrl.findUser(c)
+	if !ok {
 		return
 	}
 
 	// 不允许删除管理员账户
-	if user.Role == "admin" {
+	if user.Role == models.RoleAdmin {
 		c.JSON(http.StatusForbidden, gin.H{"error": "不能删除管理员账户"})
 		return
 	}
 
-	// 删除用户的评分和评论
-	config.DB.Where("user_id = ?", user.ID).Delete(&models.Rating{})
-	config.DB.Where("user_id = ?", user.ID).Delete(&models.Comment{})
-
-	// 删除用户
-	if err := config.DB.Delete(&user).Error; err != nil {
+	// 用户连同其评分和评论移入回收站
+	if err := ctrl.users.Delete(user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败"})
 		return
 	}
+	ctrl.audit.record(c, models.AuditUserDelete, models.AuditTargetUser, user.ID, user, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "用户删除成功"})
 }
 
+// ResetTwoFactor 为丢失验证器和恢复码的用户关闭两步验证，并吊销其全部会话。
+// 角色要求两步验证的用户下次登录后需重新启用
+func (ctrl *AdminController) ResetTwoFactor(c *gin.Context) {
+	user, ok := ctrl.findUser(c)
+	if !ok {
+		return
+	}
+	if user.Role == models.RoleAdmin && c.GetString("role") != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以修改管理员账户"})
+		return
+	}
+	if user.TOTPEnabledAt == nil && user.TOTPSecret == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "该用户未启用两步验证"})
+		return
+	}
+
+	if err := ctrl.twoFactor.Disable(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置两步验证失败"})
+		return
+	}
+	after := *user
+	after.TOTPEnabledAt = nil
+	ctrl.audit.record(c, models.AuditUserResetTwoFactor, models.AuditTargetUser, user.ID, user, &after)
+
+	if _, err := ctrl.sessions.RevokeAllByUser(user.ID, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销会话失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已关闭该用户的两步验证，用户需重新登录"})
+}
+
 // GetUserStats 获取用户统计信息
-func GetUserStats(c *gin.Context) {
+func (ctrl *AdminController) GetUserStats(c *gin.Context) {
 	var stats struct {
 		TotalUsers        int64   `json:"total_users"`
 		TotalAdmins       int64   `json:"total_admins"`
@@ -204,32 +346,83 @@ func GetUserStats(c *gin.Context) {
 	}
 
 	// 总用户数
-	config.DB.Model(&models.User{}).Count(&stats.TotalUsers)
+	stats.TotalUsers, _ = ctrl.users.Count()
 
 	// 管理员数
-	config.DB.Model(&models.User{}).Where("role = ?", "admin").Count(&stats.TotalAdmins)
+	stats.TotalAdmins, _ = ctrl.users.CountByRole(models.RoleAdmin)
 
 	// 普通用户数
-	config.DB.Model(&models.User{}).Where("role = ?", "user").Count(&stats.TotalRegularUsers)
+	stats.TotalRegularUsers, _ = ctrl.users.CountByRole(models.RoleUser)
 
 	// 平均评分
-	config.DB.Model(&models.Rating{}).Select("avg(score)").Row().Scan(&stats.AverageRating)
+	stats.AverageRating, _ = ctrl.ratings.AverageScore()
 
 	// 有评论的用户数
-	config.DB.Model(&models.Comment{}).Distinct("user_id").Count(&stats.UsersWithComment)
+	stats.UsersWithComment, _ = ctrl.comments.CountDistinctUsers()
 
 	c.JSON(http.StatusOK, stats)
 }
 
-func GetAllRatings(c *gin.Context) {
-	var ratings []models.Rating
-	config.DB.Find(&ratings)
+func (ctrl *AdminController) GetAllRatings(c *gin.Context) {
+	ratings, _ := ctrl.ratings.ListAll()
 	c.JSON(http.StatusOK, gin.H{"data": ratings})
 }
 
-func GetAllComments(c *gin.Context) {
-	var comments []models.Comment
-	config.DB.Find(&comments)
+func (ctrl *AdminController) GetAllComments(c *gin.Context) {
+	comments, _ := ctrl.comments.ListAll()
 	c.JSON(http.StatusOK, gin.H{"data": comments})
 }
 
+// DeleteRating 将评分移入回收站（审核功能），记录在审计日志中
+func (ctrl *AdminController) DeleteRating(c *gin.Context) {
+	id, _ := paramID(c, "id")
+	rating, err := ctrl.ratings.FindByID(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "评分不存在"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取评分失败"})
+		return
+	}
+
+	// 删除会写入 deletedAt，先保留删除前的内容
+	before := *rating
+	if err := ctrl.ratings.Delete(rating); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除评分失败"})
+		return
+	}
+	ctrl.audit.record(c, models.AuditRatingDelete, models.AuditTargetRating, rating.ID, &before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "评分已移入回收站"})
+}
+
+// DeleteComment 将评论移入回收站（审核功能），记录在审计日志中
+func (ctrl *AdminController) DeleteComment(c *gin.Context) {
+	id, _ := paramID(c, "id")
+	comment, err := ctrl.comments.FindByID(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "评论不存在"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取评论失败"})
+		return
+	}
+
+	before := *comment
+	if err := ctrl.comments.Delete(comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除评论失败"})
+		return
+	}
+	ctrl.audit.record(c, models.AuditCommentDelete, models.AuditTargetComment, comment.ID, &before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "评论已移入回收站"})
+}
+
+// findUser 按路径参数查询用户，不存在时直接写入 404 响应
+func (ctrl *AdminController) findUser(c *gin.Context) (*models.User, bool) {
+	id, _ := paramID(c, "id")
+	user, err := ctrl.users.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,88 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"xuan-ke-tong/models"
+)
+
+// 管理员修改用户时只写入提供的字段，角色与资料一起写入或一起失败
+func TestAdminUpdateUser(t *testing.T) {
+	s := newTestServer(t, nil)
+	client := http.DefaultClient
+	admin := s.createUser("root", "root@example.com", "secret123")
+	if err := s.app.DB.Model(admin).Update("role", models.RoleAdmin).Error; err != nil {
+		t.Fatal(err)
+	}
+	alice := s.createUser("alice", "alice@example.com", "secret123")
+	if err := s.app.DB.Model(alice).Update("nickname", "爱丽丝").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	status, login := s.do(client, http.MethodPost, "/api/v1/auth/login", "",
+		map[string]string{"username": "root", "password": "secret123"})
+	if status != http.StatusOK {
+		t.Fatalf("登录返回 %d: %v", status, login)
+	}
+	token := login["token"].(string)
+	update := func(user *models.User, body map[string]interface{}) (int, map[string]interface{}) {
+		return s.do(client, http.MethodPut, fmt.Sprintf("/api/v1/admin/users/%d", user.ID), token, body)
+	}
+	reload := func(user *models.User) *models.User {
+		var fresh models.User
+		if err := s.app.DB.First(&fresh, user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return &fresh
+	}
+
+	if status, body := update(alice, map[string]interface{}{"avatar": "alice.png"}); status != http.StatusOK {
+		t.Fatalf("只修改头像返回 %d: %v", status, body)
+	}
+	if got := reload(alice); got.Avatar != "alice.png" || got.Nickname != "爱丽丝" || got.Email != "alice@example.com" {
+		t.Fatalf("只修改头像后用户为 %q/%q/%q，昵称和邮箱不应改变", got.Avatar, got.Nickname, got.Email)
+	}
+
+	for _, body := range []map[string]interface{}{
+		{"email": ""},
+		{"email": "not-an-email"},
+		{"nickname": ""},
+		{"nickname": "a"},
+	} {
+		if status, resp := update(alice, body); status != http.StatusBadRequest {
+			t.Errorf("提交 %v 返回 %d: %v，期望 400", body, status, resp)
+		}
+	}
+	if got := reload(alice); got.Nickname != "爱丽丝" || got.Email != "alice@example.com" {
+		t.Fatalf("无效的修改写入了数据库: %q/%q", got.Nickname, got.Email)
+	}
+
+	// 撤销最后一位管理员失败时，同一请求中的资料修改也不写入
+	status, body := update(admin, map[string]interface{}{
+		"nickname": "新昵称", "role": models.RoleUser, "currentPassword": "secret123",
+	})
+	if status != http.StatusConflict {
+		t.Fatalf("撤销最后一位管理员返回 %d: %v，期望 409", status, body)
+	}
+	if got := reload(admin); got.Role != models.RoleAdmin || got.Nickname == "新昵称" {
+		t.Fatalf("角色修改失败后用户为 %s/%q，期望保持不变", got.Role, got.Nickname)
+	}
+
+	status, body = update(alice, map[string]interface{}{
+		"email": "alice@example.org", "role": models.RoleAdmin, "currentPassword": "secret123",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("修改角色和邮箱返回 %d: %v", status, body)
+	}
+	if got := reload(alice); got.Role != models.RoleAdmin || got.Email != "alice@example.org" || got.Nickname != "爱丽丝" {
+		t.Fatalf("修改后用户为 %s/%q/%q", got.Role, got.Email, got.Nickname)
+	}
+	var changes int64
+	if err := s.app.DB.Model(&models.RoleChange{}).Where("user_id = ? AND to_role = ?", alice.ID, models.RoleAdmin).Count(&changes).Error; err != nil {
+		t.Fatal(err)
+	}
+	if changes != 1 {
+		t.Fatalf("记录了 %d 条角色变更，期望 1 条", changes)
+	}
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditor 记录管理和审核操作，修改数据的各控制器共用
+type auditor struct {
+	logs repository.AuditRepository
+}
+
+// record 记录当前操作者对 target 的操作，before 和 after 为操作前后的对象：
+// 新建时 before 为 nil，删除时 after 为 nil。操作已经完成，写入失败时只记录日志
+func (a auditor) record(c *gin.Context, action, targetType string, targetID uint, before, after interface{}) {
+	changes, err := models.DiffAudit(before, after)
+	if err != nil {
+		log.Printf("生成审计记录失败 (%s %s/%d): %v", action, targetType, targetID, err)
+		changes = models.AuditChanges{}
+	}
+	entry := models.AuditLog{
+		ActorName:  c.GetString("username"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		IP:         c.ClientIP(),
+		RequestID:  c.GetString("requestId"),
+	}
+	if actorID, _, ok := currentSession(c); ok {
+		entry.ActorID = &actorID
+	}
+	if err := a.logs.Record(&entry); err != nil {
+		log.Printf("写入审计记录失败 (%s %s/%d): %v", action, targetType, targetID, err)
+	}
+}
+
+// AuditController 处理管理后台审计日志的查询和导出
+type AuditController struct {
+	logs repository.AuditRepository
+}
+
+func NewAuditController(logs repository.AuditRepository) *AuditController {
+	return &AuditController{logs: logs}
+}
+
+// ListAudit 分页列出审计日志，可按 actorId、action、targetType、targetId 和时间范围 from、to 筛选
+func (ctrl *AuditController) ListAudit(c *gin.Context) {
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	entries, total, err := ctrl.logs.List(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取审计日志失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     entries,
+		"total":    total,
+		"page":     filter.Page,
+		"pageSize": filter.PageSize,
+	})
+}
+
+// ExportAudit 以 CSV 导出满足筛选条件的全部审计日志，筛选参数与 ListAudit 相同
+func (ctrl *AuditController) ExportAudit(c *gin.Context) {
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.csv", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// 带 BOM，Excel 打开时按 UTF-8 识别中文
+	c.Writer.WriteString("\ufeff")
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "createdAt", "actorId", "actorName", "action", "targetType", "targetId", "changes", "ip", "requestId"})
+
+	err := ctrl.logs.Each(filter, func(entry models.AuditLog) error {
+		actorID := ""
+		if entry.ActorID != nil {
+			actorID = strconv.FormatUint(uint64(*entry.ActorID), 10)
+		}
+		changes, _ := entry.Changes.Value()
+		return w.Write([]string{
+			strconv.FormatUint(uint64(entry.ID), 10),
+			entry.CreatedAt.Format(time.RFC3339),
+			actorID,
+			csvCell(entry.ActorName),
+			entry.Action,
+			entry.TargetType,
+			strconv.FormatUint(uint64(entry.TargetID), 10),
+			csvCell(changes.(string)),
+			entry.IP,
+			csvCell(entry.RequestID),
+		})
+	})
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		// 响应头已经发出，只能中断输出
+		log.Printf("导出审计日志失败: %v", err)
+	}
+}
+
+// auditFilter 解析审计日志的筛选参数，参数有误时写入 400 响应
+func auditFilter(c *gin.Context) (repository.AuditFilter, bool) {
+	filter := repository.AuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("targetType"),
+	}
+	actorID, err1 := optionalID(c.Query("actorId"))
+	targetID, err2 := optionalID(c.Query("targetId"))
+	from, err3 := auditTime(c.Query("from"), false)
+	to, err4 := auditTime(c.Query("to"), true)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "筛选参数错误，时间应为 RFC 3339 格式或 YYYY-MM-DD"})
+		return filter, false
+	}
+	filter.ActorID, filter.TargetID, filter.From, filter.To = actorID, targetID, from, to
+	return filter, true
+}
+
+func optionalID(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	return uint(id), err
+}
+
+// auditTime 解析 RFC 3339 时间或日期；end 为 true 时日期表示当天结束
+func auditTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err == nil && end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, err
+}
+
+// csvCell 为以公式字符开头的单元格加上单引号，避免在电子表格中被当作公式执行
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
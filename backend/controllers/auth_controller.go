@@ -1,9 +1,16 @@
 package controllers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 	"xuan-ke-tong/config"
+	"xuan-ke-tong/mail"
 	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
 	"xuan-ke-tong/utils"
 
 	"github.com/gin-gonic/gin"
@@ -16,6 +23,8 @@ type RegisterInput struct {
 	Email    string `json:"email" binding:"required,email"`
 	Nickname string `json:"nickname" binding:"required,min=2,max=30"`
 	Avatar   string `json:"avatar"`
+	// InviteCode 仅在注册受限时需要，见 config.RegistrationConfig
+	InviteCode string `json:"inviteCode"`
 }
 
 type LoginInput struct {
@@ -23,112 +32,369 @@ type LoginInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshInput struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// AuthResponse 登录、注册和刷新成功后返回的令牌。
+// token 为短期访问令牌，过期前用 refreshToken 调用 /auth/refresh 换取新的一对令牌
 type AuthResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refreshToken"`
+	ExpiresIn    int64       `json:"expiresIn"` // 访问令牌的有效秒数
+	User         models.User `json:"user"`
+	// TwoFactorSetupRequired 角色要求两步验证而用户尚未启用，启用前不能使用任何权限
+	TwoFactorSetupRequired bool `json:"twoFactorSetupRequired,omitempty"`
+	// RecoveryCodesRemaining 使用恢复码登录时剩余的恢复码数量
+	RecoveryCodesRemaining *int64 `json:"recoveryCodesRemaining,omitempty"`
+}
+
+// SessionResponse 登录设备列表中的一项
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"` // 是否为发起请求的会话
+}
+
+// AuthController 处理注册、登录、令牌刷新和会话管理
+type AuthController struct {
+	users        repository.UserRepository
+	sessions     repository.SessionRepository
+	invites      repository.InviteRepository
+	tokens       *utils.TokenManager
+	issuer       sessionIssuer
+	login        twoFactorLogin
+	verifier     verificationSender
+	guard        loginGuard
+	registration config.RegistrationConfig
+}
+
+func NewAuthController(repos *repository.Repositories, tokens *utils.TokenManager, authz *rbac.Authorizer, mailer mail.Mailer, publicURL string, cfg config.AuthConfig) *AuthController {
+	issuer := sessionIssuer{sessions: repos.Sessions, tokens: tokens}
+	return &AuthController{
+		users:        repos.Users,
+		sessions:     repos.Sessions,
+		invites:      repos.Invites,
+		tokens:       tokens,
+		issuer:       issuer,
+		login:        twoFactorLogin{issuer: issuer, userTokens: repos.UserTokens, authz: authz, ttl: cfg.TwoFactor.ChallengeTTL},
+		verifier:     newVerificationSender(repos, mailer, publicURL, cfg),
+		guard:        loginGuard{attempts: repos.LoginAttempts, cfg: cfg.Lockout},
+		registration: cfg.Registration,
+	}
 }
 
-func Register(c *gin.Context) {
+// RegistrationPolicy 返回当前的注册限制，前端据此决定是否显示邀请码输入框
+func (ctrl *AuthController) RegistrationPolicy(c *gin.Context) {
+	domains := ctrl.registration.AllowedDomains
+	if domains == nil {
+		domains = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mode":           ctrl.registration.Mode,
+		"allowedDomains": domains,
+	})
+}
+
+// checkRegistration 按注册限制检查邮箱域名，需要邀请码时返回其摘要，由创建用户时一并占用
+func (ctrl *AuthController) checkRegistration(c *gin.Context, input RegisterInput) (string, bool) {
+	switch ctrl.registration.Mode {
+	case config.RegistrationOpen:
+		return "", true
+	case config.RegistrationDomain:
+		if ctrl.registration.AllowsEmail(input.Email) {
+			return "", true
+		}
+		if input.InviteCode == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email domain is not allowed, an invite code is required"})
+			return "", false
+		}
+	default:
+		if input.InviteCode == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "An invite code is required to register"})
+			return "", false
+		}
+	}
+	return utils.HashInviteCode(input.InviteCode), true
+}
+
+func (ctrl *AuthController) Register(c *gin.Context) {
 	var input RegisterInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check if username already exists
-	var existingUser models.User
-	if err := config.DB.Where("username = ?", input.Username).First(&existingUser).Error; err == nil {
+	// Check if username already exists (including users in the trash)
+	if taken, err := ctrl.users.UsernameTaken(input.Username); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check username"})
+		return
+	} else if taken {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Username already exists"})
 		return
 	}
 
 	// Check if email already exists
-	if err := config.DB.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
+	if taken, err := ctrl.users.EmailTaken(input.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email"})
+		return
+	} else if taken {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
 		return
 	}
 
+	inviteHash, ok := ctrl.checkRegistration(c, input)
+	if !ok {
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
 
+	// New accounts stay unverified until the emailed link is opened
 	user := models.User{
 		Username: input.Username,
 		Password: string(hashedPassword),
 		Email:    input.Email,
 		Nickname: input.Nickname,
 		Avatar:   input.Avatar,
-		Role:     "user",
+		Role:     models.RoleUser,
 	}
 
-	if user.Username == "admin" {
-		user.Role = "admin"
+	// The invite is only used up when the account is actually created
+	if inviteHash == "" {
+		err = ctrl.users.Create(&user)
+	} else {
+		err = ctrl.invites.RedeemFor(inviteHash, &user)
 	}
-
-	if err := config.DB.Create(&user).Error; err != nil {
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired invite code"})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	ctrl.verifier.sendInBackground(user)
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user)
+	// Start a session and issue tokens
+	resp, err := ctrl.issuer.issue(c, user, false)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusCreated, resp)
 }
 
-func Login(c *gin.Context) {
+func (ctrl *AuthController) Login(c *gin.Context) {
 	var input LoginInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var user models.User
-	if err := config.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
+	// Refuse attempts while the account or IP is locked out
+	wait, err := ctrl.guard.lockedFor(c, input.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return
+	}
+	if wait > 0 {
+		ctrl.guard.fail(c, input.Username, models.LoginFailureLocked)
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":      "Too many failed login attempts, please try again later",
+			"retryAfter": seconds,
+		})
+		return
+	}
+
+	user, err := ctrl.users.FindByUsername(input.Username)
+	if errors.Is(err, repository.ErrNotFound) {
+		ctrl.guard.fail(c, input.Username, models.LoginFailureUnknownUser)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find user"})
+		return
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		ctrl.guard.fail(c, input.Username, models.LoginFailureBadPassword)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user)
+	// Start a session, or ask for the second factor first.
+	// Failure counts are kept until the second factor succeeds, so they also limit code guessing
+	resp, challenge, err := ctrl.login.begin(c, *user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+	ctrl.guard.succeed(input.Username)
 
-	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
-func GetCurrentUser(c *gin.Context) {
+// Refresh 用刷新令牌换取新的访问令牌和刷新令牌，旧刷新令牌随即失效。
+// 已换掉的旧令牌再次出现说明令牌可能被盗用，整个会话会被吊销
+func (ctrl *AuthController) Refresh(c *gin.Context) {
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	oldHash := utils.HashOpaqueToken(input.RefreshToken)
+	session, err := ctrl.sessions.FindByTokenHash(oldHash)
+	switch {
+	case errors.Is(err, repository.ErrTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token already used, session revoked"})
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
+		return
+	}
+
+	user, err := ctrl.users.FindByID(session.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	refreshToken, newHash, err := utils.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+	err = ctrl.sessions.Rotate(session, oldHash, newHash, time.Now().Add(ctrl.tokens.RefreshTTL()))
+	if errors.Is(err, repository.ErrTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token already used"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	resp, err := ctrl.issuer.respond(*user, *session, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout 吊销当前会话，其访问令牌和刷新令牌立即失效
+func (ctrl *AuthController) Logout(c *gin.Context) {
+	userID, sessionID, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := ctrl.sessions.Revoke(userID, sessionID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// ListSessions 列出当前用户已登录的设备
+func (ctrl *AuthController) ListSessions(c *gin.Context) {
+	userID, sessionID, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessions, err := ctrl.sessions.ListActiveByUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	data := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		data = append(data, SessionResponse{Session: session, Current: session.ID == sessionID})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// RevokeSession 让当前用户的某个设备下线
+func (ctrl *AuthController) RevokeSession(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, ok := paramID(c, "id")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	err := ctrl.sessions.Revoke(userID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions 让当前会话以外的全部设备下线
+func (ctrl *AuthController) RevokeOtherSessions(c *gin.Context) {
+	userID, sessionID, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	revoked, err := ctrl.sessions.RevokeAllByUser(userID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked", "revoked": revoked})
+}
+
+func (ctrl *AuthController) GetCurrentUser(c *gin.Context) {
 	userId, exists := c.Get("userId")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	var user models.User
-	if err := config.DB.First(&user, userId).Error; err != nil {
+	user, err := ctrl.users.FindByID(userId.(uint))
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
+
+// JWKS 公开非对称签名密钥的公钥，其他服务据此校验本系统签发的令牌
+func (ctrl *AuthController) JWKS(c *gin.Context) {
+	// 轮换密钥时新公钥会提前发布，短时间缓存即可
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, ctrl.tokens.JWKS())
+}
@@ -0,0 +1,51 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// 任何一个已轮换掉的刷新令牌（不只是上一个）再次出现时吊销整个会话：之后的刷新令牌和访问令牌都失效
+func TestRefreshTokenReplayRevokesSession(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser("alice", "alice@example.com", "secret123")
+	client := http.DefaultClient
+
+	status, login := s.do(client, http.MethodPost, "/api/v1/auth/login", "",
+		map[string]string{"username": "alice", "password": "secret123"})
+	if status != http.StatusOK {
+		t.Fatalf("登录返回 %d: %v", status, login)
+	}
+	first := login["refreshToken"].(string)
+
+	status, rotated := s.do(client, http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": first})
+	if status != http.StatusOK {
+		t.Fatalf("刷新返回 %d: %v", status, rotated)
+	}
+	second := rotated["refreshToken"].(string)
+	if second == first {
+		t.Fatal("刷新后应换发新的刷新令牌")
+	}
+
+	status, rotated = s.do(client, http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": second})
+	if status != http.StatusOK {
+		t.Fatalf("再次刷新返回 %d: %v", status, rotated)
+	}
+	third, token := rotated["refreshToken"].(string), rotated["token"].(string)
+	if status, body := s.do(client, http.MethodGet, "/api/v1/auth/me", token, nil); status != http.StatusOK {
+		t.Fatalf("新访问令牌返回 %d: %v", status, body)
+	}
+
+	// 重放两次轮换之前的令牌
+	if status, body := s.do(client, http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": first}); status != http.StatusUnauthorized {
+		t.Fatalf("重放旧刷新令牌返回 %d: %v，期望 401", status, body)
+	}
+
+	// 同一会话的新令牌随之失效
+	if status, body := s.do(client, http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": third}); status != http.StatusUnauthorized {
+		t.Fatalf("会话吊销后刷新返回 %d: %v，期望 401", status, body)
+	}
+	if status, body := s.do(client, http.MethodGet, "/api/v1/auth/me", token, nil); status != http.StatusUnauthorized {
+		t.Fatalf("会话吊销后访问令牌返回 %d: %v，期望 401", status, body)
+	}
+}
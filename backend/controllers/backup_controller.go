@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/http"
+	"xuan-ke-tong/backup"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackupController 处理管理后台的数据库快照请求。
+// 恢复需要停止服务，只能通过命令行 restore 完成
+type BackupController struct {
+	backups *backup.Manager
+}
+
+func NewBackupController(backups *backup.Manager) *BackupController {
+	return &BackupController{backups: backups}
+}
+
+// ListBackups 列出已有快照
+func (ctrl *BackupController) ListBackups(c *gin.Context) {
+	snapshots, err := ctrl.backups.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取快照列表失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": snapshots, "supported": ctrl.backups.Supported()})
+}
+
+// CreateBackup 立即生成一个快照并清理过期快照
+func (ctrl *BackupController) CreateBackup(c *gin.Context) {
+	if !ctrl.backups.Supported() {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "仅 SQLite 数据库支持在线快照"})
+		return
+	}
+
+	snapshot, err := ctrl.backups.Create("manual")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	removed, err := ctrl.backups.Prune()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "快照已生成，但清理过期快照失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": snapshot, "pruned": removed})
+}
+
+// DownloadBackup 下载快照文件
+func (ctrl *BackupController) DownloadBackup(c *gin.Context) {
+	path, err := ctrl.backups.Path(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "快照不存在"})
+		return
+	}
+	c.FileAttachment(path, c.Param("name"))
+}
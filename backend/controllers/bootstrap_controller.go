@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BootstrapInput 创建首位管理员，账号字段的校验规则与 RegisterInput 保持一致
+type BootstrapInput struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username" binding:"required,min=3,max=20"`
+	Password string `json:"password" binding:"required,min=6,max=50"`
+	Email    string `json:"email" binding:"required,email"`
+	Nickname string `json:"nickname" binding:"required,min=2,max=30"`
+}
+
+// BootstrapController 处理使用一次性引导令牌创建首位管理员
+type BootstrapController struct {
+	users     repository.UserRepository
+	roles     repository.RoleRepository
+	bootstrap *rbac.Bootstrap
+	issuer    sessionIssuer
+}
+
+func NewBootstrapController(repos *repository.Repositories, tokens *utils.TokenManager, bootstrap *rbac.Bootstrap) *BootstrapController {
+	return &BootstrapController{
+		users:     repos.Users,
+		roles:     repos.Roles,
+		bootstrap: bootstrap,
+		issuer:    sessionIssuer{sessions: repos.Sessions, tokens: tokens},
+	}
+}
+
+// BootstrapStatus 返回是否仍在等待创建首位管理员，前端据此显示初始化页面
+func (ctrl *BootstrapController) BootstrapStatus(c *gin.Context) {
+	admins, err := ctrl.users.CountByRole(models.RoleAdmin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查管理员失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"required": admins == 0 && ctrl.bootstrap.Pending()})
+}
+
+// Bootstrap 校验引导令牌并创建首位管理员，成功后直接登录
+func (ctrl *BootstrapController) Bootstrap(c *gin.Context) {
+	var input BootstrapInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if taken, err := ctrl.users.UsernameTaken(input.Username); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查用户名失败"})
+		return
+	} else if taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
+		return
+	}
+	if taken, err := ctrl.users.EmailTaken(input.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查邮箱失败"})
+		return
+	} else if taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱已被使用"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		return
+	}
+	now := time.Now()
+	user := models.User{
+		Username:        input.Username,
+		Password:        string(hashedPassword),
+		Email:           input.Email,
+		Nickname:        input.Nickname,
+		EmailVerifiedAt: &now,
+	}
+	change := models.RoleChange{Source: models.RoleChangeBootstrap, IP: c.ClientIP()}
+
+	err = ctrl.bootstrap.Redeem(input.Token, func() error {
+		return ctrl.roles.CreateBootstrapAdmin(&user, &change)
+	})
+	switch {
+	case errors.Is(err, rbac.ErrInvalidBootstrapToken):
+		c.JSON(http.StatusForbidden, gin.H{"error": "引导令牌无效或已使用"})
+		return
+	case errors.Is(err, repository.ErrAdminExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "系统已有管理员"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建管理员失败"})
+		return
+	}
+
+	resp, err := ctrl.issuer.issue(c, user, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "签发令牌失败"})
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
@@ -2,13 +2,22 @@ package controllers
 
 import (
 	"net/http"
-	"xuan-ke-tong/config"
 	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CreateComment(c *gin.Context) {
+// CommentController 处理课程评论
+type CommentController struct {
+	comments repository.CommentRepository
+}
+
+func NewCommentController(comments repository.CommentRepository) *CommentController {
+	return &CommentController{comments: comments}
+}
+
+func (ctrl *CommentController) CreateComment(c *gin.Context) {
 	var input struct {
 		CourseID uint   `json:"courseId" binding:"required"`
 		Content  string `json:"content" binding:"required"`
@@ -30,7 +39,7 @@ func CreateComment(c *gin.Context) {
 		Content:  input.Content,
 	}
 
-	if err := config.DB.Create(&comment).Error; err != nil {
+	if err := ctrl.comments.Create(&comment); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 		return
 	}
@@ -38,9 +47,10 @@ func CreateComment(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Comment created successfully"})
 }
 
-func GetCommentsByCourse(c *gin.Context) {
-	var comments []models.Comment
-	if err := config.DB.Preload("User").Where("course_id = ?", c.Param("id")).Find(&comments).Error; err != nil {
+func (ctrl *CommentController) GetCommentsByCourse(c *gin.Context) {
+	courseID, _ := paramID(c, "id")
+	comments, err := ctrl.comments.ListByCourse(courseID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
 		return
 	}
@@ -1,14 +1,40 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
-	"xuan-ke-tong/config"
 	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CreateCourse(c *gin.Context) {
+// CourseController 处理课程的增删改查。拥有 course.manage 的角色可以修改全部课程，
+// 只有 course.edit 的角色只能修改和删除自己负责的课程
+type CourseController struct {
+	courses repository.CourseRepository
+	ratings repository.RatingRepository
+	users   repository.UserRepository
+	authz   *rbac.Authorizer
+	audit   auditor
+}
+
+func NewCourseController(repos *repository.Repositories, authz *rbac.Authorizer) *CourseController {
+	return &CourseController{
+		courses: repos.Courses,
+		ratings: repos.Ratings,
+		users:   repos.Users,
+		authz:   authz,
+		audit:   auditor{logs: repos.Audit},
+	}
+}
+
+type CourseOwnerInput struct {
+	UserID uint `json:"userId" binding:"required"`
+}
+
+func (ctrl *CourseController) CreateCourse(c *gin.Context) {
 	var input struct {
 		Name        string `json:"Name"`
 		Description string `json:"Description"`
@@ -36,29 +62,37 @@ func CreateCourse(c *gin.Context) {
 		ImageURL:    input.ImageURL,
 	}
 
-	if err := config.DB.Create(&course).Error; err != nil {
+	// 不能修改全部课程的用户创建的课程由自己负责，否则创建后就无法再修改
+	var err error
+	if ctrl.managesAll(c) {
+		err = ctrl.courses.Create(&course)
+	} else {
+		userID, _, _ := currentSession(c)
+		err = ctrl.courses.CreateOwned(&course, userID)
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create course"})
 		return
 	}
+	ctrl.audit.record(c, models.AuditCourseCreate, models.AuditTargetCourse, course.ID, nil, &course)
 
 	c.JSON(http.StatusOK, gin.H{"data": course})
 }
 
-func GetCourses(c *gin.Context) {
-	var courses []models.Course
-	query := config.DB
-
-	if grade := c.Query("grade"); grade != "" {
-		query = query.Where("grade = ?", grade)
+func (ctrl *CourseController) GetCourses(c *gin.Context) {
+	filter := repository.CourseFilter{
+		Grade:    c.Query("grade"),
+		Semester: c.Query("semester"),
+		Subject:  c.Query("subject"),
 	}
-	if semester := c.Query("semester"); semester != "" {
-		query = query.Where("semester = ?", semester)
-	}
-	if subject := c.Query("subject"); subject != "" {
-		query = query.Where("subject = ?", subject)
+	// 管理后台中 owned=true 只列出当前用户负责的课程
+	if c.Query("owned") == "true" {
+		if userID, _, ok := currentSession(c); ok {
+			filter.OwnerID = userID
+		}
 	}
-
-	if err := query.Unscoped().Find(&courses).Error; err != nil {
+	courses, err := ctrl.courses.List(filter)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get courses"})
 		return
 	}
@@ -74,29 +108,11 @@ func GetCourses(c *gin.Context) {
 	var coursesWithRatings []CourseWithRating
 
 	for _, course := range courses {
-		var avgRating float64
-		var totalRatings int64
-
 		// 计算平均评分
-		config.DB.Model(&models.Rating{}).
-			Where("course_id = ?", course.ID).
-			Select("AVG(score) as avg_rating, COUNT(*) as total").
-			Row().
-			Scan(&avgRating, &totalRatings)
-
-		if avgRating == 0 {
-			avgRating = 0
-		}
+		avgRating, totalRatings, _ := ctrl.ratings.CourseSummary(course.ID)
 
 		// 获取评分分布（1-5星）
-		ratingDistribution := make(map[int]int)
-		for i := 1; i <= 5; i++ {
-			var count int64
-			config.DB.Model(&models.Rating{}).
-				Where("course_id = ? AND score >= ? AND score < ?", course.ID, float64(i)-0.5, float64(i)+0.5).
-				Count(&count)
-			ratingDistribution[i] = int(count)
-		}
+		ratingDistribution, _ := ctrl.ratings.ScoreDistribution(course.ID)
 
 		coursesWithRatings = append(coursesWithRatings, CourseWithRating{
 			Course:             course,
@@ -109,22 +125,22 @@ func GetCourses(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": coursesWithRatings})
 }
 
-func GetCourse(c *gin.Context) {
-	var course models.Course
-	if err := config.DB.Where("id = ?", c.Param("id")).First(&course).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+func (ctrl *CourseController) GetCourse(c *gin.Context) {
+	course, ok := ctrl.findCourse(c)
+	if !ok {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"data": course})
 }
 
-func UpdateCourse(c *gin.Context) {
-	var course models.Course
-	if err := config.DB.First(&course, c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+func (ctrl *CourseController) UpdateCourse(c *gin.Context) {
+	course, ok := ctrl.findEditableCourse(c)
+	if !ok {
 		return
 	}
 
+	before := *course
+
 	var input models.Course
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -143,25 +159,160 @@ func UpdateCourse(c *gin.Context) {
 		"ImageURL":    input.ImageURL,
 	}
 
-	if err := config.DB.Model(&course).Updates(updateData).Error; err != nil {
+	if err := ctrl.courses.Update(course, updateData); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update course"})
 		return
 	}
+	ctrl.audit.record(c, models.AuditCourseUpdate, models.AuditTargetCourse, course.ID, &before, course)
 
 	c.JSON(http.StatusOK, gin.H{"data": course})
 }
 
-func DeleteCourse(c *gin.Context) {
-	var course models.Course
-	if err := config.DB.First(&course, c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+func (ctrl *CourseController) DeleteCourse(c *gin.Context) {
+	course, ok := ctrl.findEditableCourse(c)
+	if !ok {
 		return
 	}
 
-	if err := config.DB.Delete(&course).Error; err != nil {
+	if err := ctrl.courses.Delete(course); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete course"})
 		return
 	}
+	ctrl.audit.record(c, models.AuditCourseDelete, models.AuditTargetCourse, course.ID, course, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Course deleted successfully"})
 }
+
+// ListOwners 列出课程的负责教师
+func (ctrl *CourseController) ListOwners(c *gin.Context) {
+	course, ok := ctrl.findCourse(c)
+	if !ok {
+		return
+	}
+	owners, err := ctrl.courses.ListOwners(course.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get course owners"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": owners})
+}
+
+// AddOwner 指派课程的负责教师，被指派的用户须拥有 course.edit 权限
+func (ctrl *CourseController) AddOwner(c *gin.Context) {
+	course, ok := ctrl.findCourse(c)
+	if !ok {
+		return
+	}
+	var input CourseOwnerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := ctrl.users.FindByID(input.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if !ctrl.authz.Can(user.Role, models.PermCourseEdit) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User's role cannot edit courses", "permission": models.PermCourseEdit})
+		return
+	}
+
+	actorID, _, _ := currentSession(c)
+	owner := models.CourseOwner{CourseID: course.ID, UserID: user.ID, AssignedBy: &actorID}
+	err = ctrl.courses.AddOwner(&owner)
+	if errors.Is(err, repository.ErrAlreadyOwner) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already owns this course"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add course owner"})
+		return
+	}
+	ctrl.audit.record(c, models.AuditCourseAddOwner, models.AuditTargetCourse, course.ID, nil, &owner)
+
+	owner.User = *user
+	c.JSON(http.StatusCreated, gin.H{"data": owner})
+}
+
+// RemoveOwner 取消课程负责教师的指派
+func (ctrl *CourseController) RemoveOwner(c *gin.Context) {
+	course, ok := ctrl.findCourse(c)
+	if !ok {
+		return
+	}
+	userID, ok := paramID(c, "userId")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Course owner not found"})
+		return
+	}
+
+	owner, err := ctrl.courses.RemoveOwner(course.ID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Course owner not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove course owner"})
+		return
+	}
+	ctrl.audit.record(c, models.AuditCourseRemoveOwner, models.AuditTargetCourse, course.ID, owner, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Course owner removed"})
+}
+
+// LegacyCourseMutation 响应已停用的 POST/PUT/DELETE /api/v1/courses，
+// 不修改任何数据，指向 /api/v1/admin/courses 下对应的接口
+func LegacyCourseMutation(c *gin.Context) {
+	successor := "/api/v1/admin/courses"
+	if id := c.Param("id"); id != "" {
+		successor += "/" + id
+	}
+	c.Header("Deprecation", "true")
+	c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+	c.JSON(http.StatusGone, gin.H{
+		"error":     "This endpoint has been removed, use " + c.Request.Method + " " + successor + " instead",
+		"successor": successor,
+	})
+}
+
+// managesAll 当前用户能否修改全部课程
+func (ctrl *CourseController) managesAll(c *gin.Context) bool {
+	return ctrl.authz.Can(c.GetString("role"), models.PermCourseManage)
+}
+
+// findEditableCourse 查询当前用户可以修改的课程，不存在时写入 404，不负责该课程时写入 403
+func (ctrl *CourseController) findEditableCourse(c *gin.Context) (*models.Course, bool) {
+	course, ok := ctrl.findCourse(c)
+	if !ok || ctrl.managesAll(c) {
+		return course, ok
+	}
+
+	userID, _, _ := currentSession(c)
+	owns, err := ctrl.courses.IsOwner(course.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check course owner"})
+		return nil, false
+	}
+	if !owns {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit courses assigned to you", "permission": models.PermCourseManage})
+		return nil, false
+	}
+	return course, true
+}
+
+// findCourse 按路径参数查询课程，不存在时直接写入 404 响应
+func (ctrl *CourseController) findCourse(c *gin.Context) (*models.Course, bool) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		return nil, false
+	}
+
+	course, err := ctrl.courses.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		return nil, false
+	}
+	return course, true
+}
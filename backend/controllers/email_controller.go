@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/mail"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// verificationSender 生成邮箱验证令牌并发送验证邮件，注册和重新发送共用
+type verificationSender struct {
+	userTokens repository.UserTokenRepository
+	mailer     mail.Mailer
+	publicURL  string
+	ttl        time.Duration
+}
+
+func newVerificationSender(repos *repository.Repositories, mailer mail.Mailer, publicURL string, cfg config.AuthConfig) verificationSender {
+	return verificationSender{
+		userTokens: repos.UserTokens,
+		mailer:     mailer,
+		publicURL:  strings.TrimRight(publicURL, "/"),
+		ttl:        cfg.EmailVerificationTTL,
+	}
+}
+
+// send 向用户当前的邮箱发送验证链接，之前发出的链接随之作废
+func (s verificationSender) send(ctx context.Context, user models.User) error {
+	link, err := s.issue(user, models.TokenPurposeEmailVerification, user.Email)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mail.EmailVerification(user.Email, user.Nickname, user.Username, link, s.ttl))
+}
+
+// sendChange 向待修改的新邮箱发送确认链接，确认后才会替换用户的邮箱
+func (s verificationSender) sendChange(ctx context.Context, user models.User, email string) error {
+	link, err := s.issue(user, models.TokenPurposeEmailChange, email)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mail.EmailChange(email, user.Nickname, user.Username, link, s.ttl))
+}
+
+// issue 保存发往 email 的一次性令牌，返回邮件中的链接
+func (s verificationSender) issue(user models.User, purpose, email string) (string, error) {
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	record := models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		Email:     email,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.userTokens.Replace(&record); err != nil {
+		return "", err
+	}
+	return s.publicURL + "/verify-email?token=" + url.QueryEscape(token), nil
+}
+
+// sendInBackground 在后台发送验证链接，失败时只记录日志
+func (s verificationSender) sendInBackground(user models.User) {
+	go func() {
+		if err := s.send(context.Background(), user); err != nil {
+			log.Printf("发送验证邮件给用户 %d 失败: %v", user.ID, err)
+		}
+	}()
+}
+
+// EmailController 处理邮箱验证
+type EmailController struct {
+	users      repository.UserRepository
+	userTokens repository.UserTokenRepository
+	sender     verificationSender
+}
+
+func NewEmailController(repos *repository.Repositories, mailer mail.Mailer, publicURL string, cfg config.AuthConfig) *EmailController {
+	return &EmailController{
+		users:      repos.Users,
+		userTokens: repos.UserTokens,
+		sender:     newVerificationSender(repos, mailer, publicURL, cfg),
+	}
+}
+
+// VerifyEmail 用邮件中的一次性令牌完成邮箱验证或确认修改后的新邮箱，
+// 无需登录，便于在其他设备上打开链接
+func (ctrl *EmailController) VerifyEmail(c *gin.Context) {
+	var input VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash := utils.HashOpaqueToken(input.Token)
+	token, err := ctrl.userTokens.Consume(models.TokenPurposeEmailVerification, hash)
+	if errors.Is(err, repository.ErrNotFound) {
+		token, err = ctrl.userTokens.Consume(models.TokenPurposeEmailChange, hash)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证链接无效或已过期，请登录后重新发送"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证邮箱失败"})
+		return
+	}
+
+	user, err := ctrl.users.FindByID(token.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证链接无效或已过期，请登录后重新发送"})
+		return
+	}
+
+	oldEmail := user.Email
+	switch {
+	case token.Purpose == models.TokenPurposeEmailChange:
+		if token.Email != user.Email {
+			taken, err := ctrl.users.EmailTaken(token.Email)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "验证邮箱失败"})
+				return
+			}
+			if taken {
+				c.JSON(http.StatusConflict, gin.H{"error": "该邮箱已被其他账号使用"})
+				return
+			}
+			user.Email = token.Email
+		}
+	case token.Email != "" && token.Email != user.Email:
+		// 发出验证邮件后邮箱已被修改，旧地址的链接不再有效
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证链接无效或已过期，请登录后重新发送"})
+		return
+	case user.EmailVerifiedAt != nil:
+		c.JSON(http.StatusOK, gin.H{"message": "邮箱验证成功", "data": user})
+		return
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := ctrl.users.Save(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证邮箱失败"})
+		return
+	}
+
+	if user.Email != oldEmail {
+		notice := mail.EmailChanged(oldEmail, user.Nickname, user.Username, user.Email)
+		go func() {
+			if err := ctrl.sender.mailer.Send(context.Background(), notice); err != nil {
+				log.Printf("发送邮箱修改通知给用户 %d 失败: %v", user.ID, err)
+			}
+		}()
+		c.JSON(http.StatusOK, gin.H{"message": "邮箱已修改", "data": user})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "邮箱验证成功", "data": user})
+}
+
+// ResendVerification 为当前用户重新发送验证邮件
+func (ctrl *EmailController) ResendVerification(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
+	}
+	user, err := ctrl.users.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱已验证"})
+		return
+	}
+
+	if err := ctrl.sender.send(c.Request.Context(), *user); err != nil {
+		log.Printf("发送验证邮件给用户 %d 失败: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送验证邮件失败，请稍后再试"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "验证邮件已发送，请查收"})
+}
@@ -3,14 +3,24 @@ package controllers
 import (
 	"net/http"
 	"strconv"
-	"xuan-ke-tong/config"
 	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
+// EvaluationRequestController 处理求评价请求
+type EvaluationRequestController struct {
+	requests repository.EvaluationRequestRepository
+	courses  repository.CourseRepository
+}
+
+func NewEvaluationRequestController(requests repository.EvaluationRequestRepository, courses repository.CourseRepository) *EvaluationRequestController {
+	return &EvaluationRequestController{requests: requests, courses: courses}
+}
+
 // CreateEvaluationRequest 处理POST /api/evaluation-requests请求，创建新的求评价请求
-func CreateEvaluationRequest(c *gin.Context) {
+func (ctrl *EvaluationRequestController) CreateEvaluationRequest(c *gin.Context) {
 	// 获取当前用户信息
 	userID, exists := c.Get("userId")
 	if !exists {
@@ -28,15 +38,13 @@ func CreateEvaluationRequest(c *gin.Context) {
 	}
 
 	// 检查课程是否存在
-	var course models.Course
-	if err := config.DB.First(&course, input.CourseID).Error; err != nil {
+	if _, err := ctrl.courses.FindByID(input.CourseID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "课程不存在"})
 		return
 	}
 
 	// 检查是否已经存在活跃的求评价请求
-	var existingRequest models.EvaluationRequest
-	if err := config.DB.Where("user_id = ? AND course_id = ? AND status = ?", userID, input.CourseID, "pending").First(&existingRequest).Error; err == nil {
+	if _, err := ctrl.requests.FindByUserCourseStatus(userID.(uint), input.CourseID, "pending"); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "该课程已经存在一个活跃的求评价请求"})
 		return
 	}
@@ -48,13 +56,15 @@ func CreateEvaluationRequest(c *gin.Context) {
 		Status:   "pending",
 	}
 
-	if err := config.DB.Create(&evaluationRequest).Error; err != nil {
+	if err := ctrl.requests.Create(&evaluationRequest); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建求评价请求失败"})
 		return
 	}
 
 	// 加载关联的用户和课程信息
-	config.DB.Preload("User").Preload("Course").First(&evaluationRequest, evaluationRequest.ID)
+	if loaded, err := ctrl.requests.FindByID(evaluationRequest.ID); err == nil {
+		evaluationRequest = *loaded
+	}
 
 	// 返回响应数据
 	response := gin.H{
@@ -77,7 +87,7 @@ func CreateEvaluationRequest(c *gin.Context) {
 }
 
 // GetEvaluationRequests 处理GET /api/evaluation-requests请求，获取所有状态为pending的求评价列表
-func GetEvaluationRequests(c *gin.Context) {
+func (ctrl *EvaluationRequestController) GetEvaluationRequests(c *gin.Context) {
 	// 获取分页参数
 	page := 1
 	pageSize := 10
@@ -94,21 +104,9 @@ func GetEvaluationRequests(c *gin.Context) {
 		}
 	}
 
-	// 计算偏移量
-	offset := (page - 1) * pageSize
-
-	// 查询求评价请求列表
-	var evaluationRequests []models.EvaluationRequest
-	var total int64
-
-	// 先获取总数
-	config.DB.Model(&models.EvaluationRequest{}).Where("status = ?", "pending").Count(&total)
-
 	// 获取分页数据，包含关联的用户和课程信息
-	if err := config.DB.Preload("User").Preload("Course").
-		Where("status = ?", "pending").
-		Limit(pageSize).Offset(offset).
-		Find(&evaluationRequests).Error; err != nil {
+	evaluationRequests, total, err := ctrl.requests.ListByStatus("pending", page, pageSize)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取求评价列表失败"})
 		return
 	}
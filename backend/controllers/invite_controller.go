@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateInviteInput struct {
+	Note      string     `json:"note" binding:"max=200"`
+	MaxUses   int        `json:"maxUses" binding:"min=0"` // 0 表示不限次数
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// InviteController 处理管理后台的注册邀请码
+type InviteController struct {
+	invites repository.InviteRepository
+}
+
+func NewInviteController(invites repository.InviteRepository) *InviteController {
+	return &InviteController{invites: invites}
+}
+
+// ListInvites 列出全部邀请码，邀请码本身只在创建时返回
+func (ctrl *InviteController) ListInvites(c *gin.Context) {
+	invites, err := ctrl.invites.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取邀请码失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": invites})
+}
+
+// CreateInvite 生成新的邀请码
+func (ctrl *InviteController) CreateInvite(c *gin.Context) {
+	var input CreateInviteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "过期时间不能早于当前时间"})
+		return
+	}
+
+	code, hash, err := utils.NewInviteCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成邀请码失败"})
+		return
+	}
+	invite := models.Invite{
+		CodeHash:  hash,
+		Note:      input.Note,
+		MaxUses:   input.MaxUses,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := ctrl.invites.Create(&invite); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成邀请码失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "邀请码已生成，请妥善保存，之后无法再次查看",
+		"code":    code,
+		"data":    invite,
+	})
+}
+
+// DeleteInvite 作废邀请码
+func (ctrl *InviteController) DeleteInvite(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	err := ctrl.invites.Delete(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "邀请码不存在"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除邀请码失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "邀请码已作废"})
+}
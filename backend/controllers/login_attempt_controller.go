@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginThrottleResponse 登录失败计数及其当前是否锁定
+type LoginThrottleResponse struct {
+	models.LoginThrottle
+	Locked bool `json:"locked"`
+}
+
+// LoginAttemptController 处理管理后台的登录失败记录和锁定管理
+type LoginAttemptController struct {
+	attempts repository.LoginAttemptRepository
+	users    repository.UserRepository
+	window   time.Duration
+}
+
+func NewLoginAttemptController(repos *repository.Repositories, cfg config.LockoutConfig) *LoginAttemptController {
+	return &LoginAttemptController{
+		attempts: repos.LoginAttempts,
+		users:    repos.Users,
+		window:   cfg.Window,
+	}
+}
+
+// ListLoginEvents 分页列出登录失败记录，可按用户名和 IP 筛选
+func (ctrl *LoginAttemptController) ListLoginEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	events, total, err := ctrl.attempts.ListEvents(repository.LoginEventFilter{
+		Username: c.Query("username"),
+		IP:       c.Query("ip"),
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取登录失败记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     events,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// ListLockouts 列出计数窗口内仍有失败记录的账号和 IP
+func (ctrl *LoginAttemptController) ListLockouts(c *gin.Context) {
+	now := time.Now()
+	throttles, err := ctrl.attempts.ListThrottles(now.Add(-ctrl.window))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取登录锁定失败"})
+		return
+	}
+
+	data := make([]LoginThrottleResponse, 0, len(throttles))
+	for _, t := range throttles {
+		data = append(data, LoginThrottleResponse{
+			LoginThrottle: t,
+			Locked:        t.LockedUntil != nil && t.LockedUntil.After(now),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// DeleteLockout 清除一条账号或 IP 的失败计数并解除锁定
+func (ctrl *LoginAttemptController) DeleteLockout(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	err := ctrl.attempts.Delete(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "记录不存在"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解除锁定失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已解除锁定"})
+}
+
+// UnlockUser 解除用户账号的登录锁定
+func (ctrl *LoginAttemptController) UnlockUser(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+	user, err := ctrl.users.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if err := ctrl.attempts.Reset(models.LoginScopeUser, accountKey(user.Username)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解除锁定失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已解除用户 " + user.Username + " 的登录锁定"})
+}
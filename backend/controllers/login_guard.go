@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginGuard 按账号和 IP 统计登录失败，连续失败后按指数退避临时锁定
+type loginGuard struct {
+	attempts repository.LoginAttemptRepository
+	cfg      config.LockoutConfig
+}
+
+// accountKey 用户名不区分大小写计数，避免通过改变大小写绕过锁定
+func accountKey(username string) string {
+	return strings.ToLower(username)
+}
+
+// lockedFor 返回账号或 IP 剩余的锁定时长，未锁定时返回 0
+func (g loginGuard) lockedFor(c *gin.Context, username string) (time.Duration, error) {
+	now := time.Now()
+	throttle, err := g.attempts.FindLocked(now,
+		models.LoginThrottle{Scope: models.LoginScopeUser, Value: accountKey(username)},
+		models.LoginThrottle{Scope: models.LoginScopeIP, Value: c.ClientIP()},
+	)
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return throttle.LockedUntil.Sub(now), nil
+}
+
+// fail 记录一次登录失败；锁定期间的尝试只记录事件，不延长锁定
+func (g loginGuard) fail(c *gin.Context, username, reason string) {
+	event := models.LoginEvent{
+		Username:  username,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Reason:    reason,
+	}
+	if err := g.attempts.LogEvent(&event); err != nil {
+		log.Printf("记录登录失败事件失败: %v", err)
+	}
+	if reason == models.LoginFailureLocked {
+		return
+	}
+
+	now := time.Now()
+	for _, s := range []struct {
+		scope, value string
+		threshold    int
+	}{
+		{models.LoginScopeUser, accountKey(username), g.cfg.MaxAttempts},
+		{models.LoginScopeIP, c.ClientIP(), g.cfg.IPMaxAttempts},
+	} {
+		threshold := s.threshold
+		throttle, err := g.attempts.RecordFailure(s.scope, s.value, now, g.cfg.Window, func(failures int) time.Duration {
+			return g.cfg.LockFor(failures, threshold)
+		})
+		if err != nil {
+			log.Printf("记录登录失败次数失败: %v", err)
+			continue
+		}
+		if throttle.LockedUntil != nil && throttle.Failures == threshold {
+			log.Printf("登录连续失败 %d 次，已锁定 %s %s 至 %s", throttle.Failures, s.scope, s.value, throttle.LockedUntil.Format(time.DateTime))
+		}
+	}
+}
+
+// succeed 登录成功后清除账号的失败计数。IP 的计数不清除，
+// 否则攻击者可以穿插登录自己的账号来绕过 IP 锁定
+func (g loginGuard) succeed(username string) {
+	if err := g.attempts.Reset(models.LoginScopeUser, accountKey(username)); err != nil {
+		log.Printf("清除登录失败次数失败: %v", err)
+	}
+}
+
+// requestLimit 限制匿名接口的请求频率，与登录失败共用计数表：
+// 每个维度在 window 内累计达到上限后锁定 window
+type requestLimit struct {
+	attempts repository.LoginAttemptRepository
+	window   time.Duration
+}
+
+// limitSubject 一个计数维度及其上限
+type limitSubject struct {
+	scope, value string
+	max          int
+}
+
+// take 检查各维度是否已被锁定，未锁定时各计一次请求。返回剩余的锁定时长，未锁定时返回 0
+func (l requestLimit) take(subjects ...limitSubject) (time.Duration, error) {
+	now := time.Now()
+	throttles := make([]models.LoginThrottle, len(subjects))
+	for i, s := range subjects {
+		throttles[i] = models.LoginThrottle{Scope: s.scope, Value: s.value}
+	}
+	throttle, err := l.attempts.FindLocked(now, throttles...)
+	if err == nil {
+		return throttle.LockedUntil.Sub(now), nil
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return 0, err
+	}
+
+	for _, s := range subjects {
+		max := s.max
+		_, err := l.attempts.RecordFailure(s.scope, s.value, now, l.window, func(requests int) time.Duration {
+			if requests >= max {
+				return l.window
+			}
+			return 0
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
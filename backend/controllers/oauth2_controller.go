@@ -2,109 +2,134 @@ package controllers
 
 import (
 	"crypto/rand"
-	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"math/big"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 	"xuan-ke-tong/config"
 	"xuan-ke-tong/models"
+	"xuan-ke-tong/oauth"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
 	"xuan-ke-tong/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type OAuth2State struct {
-	State     string    `json:"state"`
-	ExpiresAt time.Time `json:"expiresAt"`
-}
-
-var oauth2States = make(map[string]OAuth2State)
+// oauth2BindingCookie 将 state 绑定到发起授权的浏览器，防止授权回调被注入到他人的浏览器中
+const oauth2BindingCookie = "oauth2_binding"
 
-// OAuth2配置结构
-type OAuth2Config struct {
-	AppID       string
-	AppSecret   string
-	TokenURL    string
-	UserInfoURL string
+// ProviderResponse 可用于登录的提供方
+type ProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
 }
 
-// 从.env文件加载OAuth2配置
-func loadOAuth2Config() (*OAuth2Config, error) {
-	err := godotenv.Load(".env")
-	if err != nil {
-		return nil, fmt.Errorf("加载环境配置文件失败: %v", err)
-	}
+// OAuth2Controller 处理第三方授权登录和外部账号绑定
+type OAuth2Controller struct {
+	providers    *oauth.Registry
+	users        repository.UserRepository
+	roles        repository.RoleRepository
+	identities   repository.IdentityRepository
+	states       oauth.StateStore
+	login        twoFactorLogin
+	stateTTL     time.Duration
+	secureCookie bool
+}
 
-	// 从环境变量中读取配置
-	config := &OAuth2Config{
-		AppID:       os.Getenv("OAUTH2_MARKET_APP_ID"),
-		AppSecret:   os.Getenv("OAUTH2_MARKET_APP_SECRET"),
-		TokenURL:    os.Getenv("OAUTH2_MARKET_TOKEN_URL"),
-		UserInfoURL: os.Getenv("OAUTH2_MARKET_USERINFO_URL"),
+func NewOAuth2Controller(cfg *config.Config, repos *repository.Repositories, tokens *utils.TokenManager, authz *rbac.Authorizer, providers *oauth.Registry, states oauth.StateStore) *OAuth2Controller {
+	issuer := sessionIssuer{sessions: repos.Sessions, tokens: tokens}
+	return &OAuth2Controller{
+		providers:    providers,
+		users:        repos.Users,
+		roles:        repos.Roles,
+		identities:   repos.Identities,
+		states:       states,
+		login:        twoFactorLogin{issuer: issuer, userTokens: repos.UserTokens, authz: authz, ttl: cfg.Auth.TwoFactor.ChallengeTTL},
+		stateTTL:     cfg.OAuth2.StateTTL,
+		secureCookie: cfg.IsProduction(),
 	}
-
-	return config, nil
 }
 
-// 集市授权的用户信息结构
-type SSEUserInfo struct {
-	UserID    int    `json:"user_id"`
-	Name      string `json:"name"`
-	Email     string `json:"email"`
-	AvatarURL string `json:"avatar_url"`
-	Intro     string `json:"intro"`
-	Identity  string `json:"identity"`
+// setBindingCookie 写入或清除（maxAge 为 -1）浏览器绑定 Cookie
+func (ctrl *OAuth2Controller) setBindingCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauth2BindingCookie, value, maxAge, "/api/v1/auth/oauth2", "", ctrl.secureCookie, true)
 }
 
-type SSEUserInfoResponse struct {
-	Code int         `json:"code"`
-	Msg  string      `json:"msg"`
-	Data SSEUserInfo `json:"data"`
+// ListProviders 列出已配置的提供方
+func (ctrl *OAuth2Controller) ListProviders(c *gin.Context) {
+	providers := []ProviderResponse{}
+	for _, p := range ctrl.providers.List() {
+		providers = append(providers, ProviderResponse{Name: p.Name(), DisplayName: p.DisplayName()})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": providers})
 }
 
-// 集市授权的Token响应结构
-type SSETokenResponse struct {
-	Code int    `json:"code"`
-	Msg  string `json:"msg"`
-	Data struct {
-		AccessToken string `json:"access_token"`
-		TokenType   string `json:"token_type"`
-		ExpiresIn   int    `json:"expires_in"`
-		Scope       string `json:"scope"`
-	} `json:"data"`
+// GenerateOAuth2State 发起登录：生成 state 和 PKCE 参数，并将 state 绑定到当前浏览器。
+// provider 默认为集市
+func (ctrl *OAuth2Controller) GenerateOAuth2State(c *gin.Context) {
+	ctrl.authorize(c, c.DefaultQuery("provider", config.MarketProviderName), nil)
 }
 
-func GenerateOAuth2State(c *gin.Context) {
-	stateBytes := make([]byte, 16)
-	if _, err := io.ReadFull(rand.Reader, stateBytes); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "生成state失败",
-		})
+// LinkIdentity 为当前用户发起绑定，回调时将外部账号绑定到该用户
+func (ctrl *OAuth2Controller) LinkIdentity(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
 		return
 	}
+	ctrl.authorize(c, c.Query("provider"), &userID)
+}
 
-	state := hex.EncodeToString(stateBytes)
+// authorize 保存 state 并返回授权参数；提供方能给出授权地址时一并返回 authURL
+func (ctrl *OAuth2Controller) authorize(c *gin.Context, name string, linkUserID *uint) {
+	provider, ok := ctrl.providers.Get(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未配置该登录方式"})
+		return
+	}
 
-	// 存储state，有效期5分钟
-	oauth2States[state] = OAuth2State{
-		State:     state,
-		ExpiresAt: time.Now().Add(5 * time.Minute),
+	binding, _ := c.Cookie(oauth2BindingCookie)
+	auth, err := oauth.NewAuthorization(ctrl.stateTTL, binding)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成state失败"})
+		return
 	}
+	auth.State.Provider = provider.Name()
+	auth.State.LinkUserID = linkUserID
 
-	c.JSON(http.StatusOK, gin.H{
-		"state": state,
-	})
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), auth.State.State, auth.CodeChallenge)
+	if err != nil {
+		log.Printf("生成 %s 授权地址失败: %v", provider.Name(), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "无法连接登录提供方"})
+		return
+	}
+	if err := ctrl.states.Save(auth.State); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存state失败"})
+		return
+	}
+	ctrl.setBindingCookie(c, auth.Binding, int(ctrl.stateTTL.Seconds()))
+
+	resp := gin.H{
+		"provider":            provider.Name(),
+		"state":               auth.State.State,
+		"codeChallenge":       auth.CodeChallenge,
+		"codeChallengeMethod": "S256",
+		"expiresIn":           int64(ctrl.stateTTL.Seconds()),
+	}
+	if authURL != "" {
+		resp["authURL"] = authURL
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
-// OAuth2Callback 处理OAuth2回调
-func OAuth2Callback(c *gin.Context) {
+// OAuth2Callback 处理OAuth2回调：发起绑定的 state 绑定外部账号，否则登录
+func (ctrl *OAuth2Controller) OAuth2Callback(c *gin.Context) {
 	code := c.Query("code")
 	state := c.Query("state")
 
@@ -115,72 +140,86 @@ func OAuth2Callback(c *gin.Context) {
 		return
 	}
 
-	// 验证state
-	storedState, exists := oauth2States[state]
-	if !exists {
+	// 验证state，取出后即作废
+	storedState, err := ctrl.states.Take(state, time.Now())
+	switch {
+	case errors.Is(err, oauth.ErrStateNotFound):
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "无效的state",
 		})
 		return
-	}
-
-	// 检查state是否过期
-	if time.Now().After(storedState.ExpiresAt) {
-		delete(oauth2States, state)
+	case errors.Is(err, oauth.ErrStateExpired):
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "state已过期",
 		})
 		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "读取state失败",
+		})
+		return
 	}
 
-	// 清理已使用的state
-	delete(oauth2States, state)
+	// 回调必须来自发起授权的浏览器
+	binding, _ := c.Cookie(oauth2BindingCookie)
+	if !oauth.BoundTo(storedState, binding) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "state与当前浏览器不匹配",
+		})
+		return
+	}
 
-	// 使用code获取access_token
-	accessToken, err := getAccessToken(code)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取access_token出错: " + err.Error(),
+	// 升级前保存的 state 没有提供方，视为集市
+	name := storedState.Provider
+	if name == "" {
+		name = config.MarketProviderName
+	}
+	provider, ok := ctrl.providers.Get(name)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "未配置该登录方式",
 		})
 		return
 	}
 
-	// 使用access_token获取用户信息
-	userInfo, err := getUserInfo(accessToken)
+	// 使用code换取令牌并获取外部账号
+	identity, err := provider.Exchange(c.Request.Context(), code, storedState.CodeVerifier)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取用户信息失败: " + err.Error(),
+		log.Printf("%s 授权回调失败: %v", name, err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "获取用户信息失败",
 		})
 		return
 	}
 
-	// 检查用户是否已存在
-	var user models.User
-	err = config.DB.Where("email = ?", userInfo.Email).First(&user).Error
+	if storedState.LinkUserID != nil {
+		ctrl.linkIdentity(c, *storedState.LinkUserID, provider, identity)
+		return
+	}
 
-	if err != nil {
-		// 用户不存在，创建新用户
-		user, err = createUserFromSSE(userInfo)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "创建用户失败: " + err.Error(),
-			})
-			return
-		}
+	user, ok := ctrl.resolveUser(c, provider, identity)
+	if !ok {
+		return
 	}
 
-	// 生成JWT token
-	token, err := utils.GenerateToken(user)
+	// 创建会话并签发令牌；已启用两步验证的用户先返回挑战
+	resp, challenge, err := ctrl.login.begin(c, *user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "生成token失败",
 		})
 		return
 	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
 
 	// 返回成功响应，包含token和用户信息
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
+		"token":        resp.Token,
+		"refreshToken": resp.RefreshToken,
+		"expiresIn":    resp.ExpiresIn,
 		"user": gin.H{
 			"id":        user.ID,
 			"username":  user.Username,
@@ -191,97 +230,155 @@ func OAuth2Callback(c *gin.Context) {
 			"createdAt": user.CreatedAt,
 			"updatedAt": user.UpdatedAt,
 		},
-		"message": "OAuth2登录成功",
+		"twoFactorSetupRequired": resp.TwoFactorSetupRequired,
+		"message":                "OAuth2登录成功",
 	})
 }
 
-// getAccessToken 从SSE集市获取access_token
-func getAccessToken(code string) (string, error) {
-	// 加载OAuth2配置
-	config, err := loadOAuth2Config()
-	if err != nil {
-		return "", fmt.Errorf("加载OAuth2配置失败: %v", err)
+// resolveUser 按 (提供方, 外部账号 ID) 找到绑定的用户；首次登录时创建新用户，
+// 只有提供方可信且邮箱已验证时才按邮箱绑定已有用户。失败时写入错误响应
+func (ctrl *OAuth2Controller) resolveUser(c *gin.Context, provider oauth.Provider, identity *oauth.Identity) (*models.User, bool) {
+	linked, err := ctrl.identities.FindBySubject(provider.Name(), identity.Subject)
+	switch {
+	case err == nil:
+		user, err := ctrl.users.FindByID(linked.UserID)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "绑定的用户不存在或已被删除"})
+			return nil, false
+		}
+		if err := ctrl.identities.RecordLogin(linked, identity.Email, time.Now()); err != nil {
+			log.Printf("记录 %s 登录失败: %v", provider.Name(), err)
+		}
+		return user, true
+	case !errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询绑定失败"})
+		return nil, false
 	}
 
-	// 构建form-data请求参数
-	formData := map[string][]string{
-		"code":       {code},
-		"app_id":     {config.AppID},
-		"app_secret": {config.AppSecret},
+	if identity.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "登录提供方没有返回邮箱"})
+		return nil, false
+	}
+	user, err := ctrl.users.FindByEmail(identity.Email)
+	switch {
+	case err == nil:
+		if !provider.TrustEmail() || !identity.EmailVerified {
+			c.JSON(http.StatusConflict, gin.H{"error": "该邮箱已注册，请先用密码登录，再在个人资料中绑定该登录方式"})
+			return nil, false
+		}
+	case errors.Is(err, repository.ErrNotFound):
+		// 用户不存在，创建新用户
+		if user, err = ctrl.createUser(provider, identity); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建用户失败: " + err.Error()})
+			return nil, false
+		}
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+		return nil, false
 	}
 
-	// 发送POST请求获取token
-	resp, err := http.PostForm(config.TokenURL, formData)
+	now := time.Now()
+	err = ctrl.identities.Create(&models.ExternalIdentity{
+		UserID:      user.ID,
+		Provider:    provider.Name(),
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+	})
 	if err != nil {
-		return "", err
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "绑定外部账号失败"})
+		return nil, false
 	}
-	defer resp.Body.Close()
+	return user, true
+}
 
-	// 解析响应
-	var tokenResp SSETokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", err
+// linkIdentity 将外部账号绑定到发起绑定的用户，每个提供方只能绑定一个外部账号
+func (ctrl *OAuth2Controller) linkIdentity(c *gin.Context, userID uint, provider oauth.Provider, identity *oauth.Identity) {
+	if linked, err := ctrl.identities.FindBySubject(provider.Name(), identity.Subject); err == nil {
+		if linked.UserID == userID {
+			c.JSON(http.StatusOK, gin.H{"message": "该外部账号已绑定", "data": linked})
+		} else {
+			c.JSON(http.StatusConflict, gin.H{"error": "该外部账号已绑定其他用户"})
+		}
+		return
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询绑定失败"})
+		return
 	}
 
-	// 检查响应状态
-	if tokenResp.Code != 200 {
-		return "", fmt.Errorf("获取access_token出错: %s", tokenResp.Msg)
+	existing, err := ctrl.identities.ListByUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询绑定失败"})
+		return
+	}
+	for _, e := range existing {
+		if e.Provider == provider.Name() {
+			c.JSON(http.StatusConflict, gin.H{"error": "已绑定该登录方式的其他账号，请先解除绑定"})
+			return
+		}
 	}
 
-	return tokenResp.Data.AccessToken, nil
+	linked := models.ExternalIdentity{
+		UserID:   userID,
+		Provider: provider.Name(),
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+	if err := ctrl.identities.Create(&linked); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "绑定外部账号失败"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "绑定成功", "data": linked})
 }
 
-// getUserInfo 从SSE集市获取用户信息
-func getUserInfo(accessToken string) (*SSEUserInfo, error) {
-	// 加载OAuth2配置
-	config, err := loadOAuth2Config()
-	if err != nil {
-		return nil, fmt.Errorf("加载OAuth2配置失败: %v", err)
+// ListIdentities 列出当前用户绑定的外部账号
+func (ctrl *OAuth2Controller) ListIdentities(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
 	}
-
-	// 创建请求
-	req, err := http.NewRequest("POST", config.UserInfoURL, nil)
+	identities, err := ctrl.identities.ListByUser(userID)
 	if err != nil {
-		return nil, err
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取绑定失败"})
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"data": identities})
+}
 
-	// 设置Authorization头
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	// 发送请求
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// UnlinkIdentity 解除当前用户的一个外部账号绑定
+func (ctrl *OAuth2Controller) UnlinkIdentity(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return
 	}
-	defer resp.Body.Close()
-
-	// 解析响应
-	var userInfoResp SSEUserInfoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&userInfoResp); err != nil {
-		return nil, err
+	id, ok := paramID(c, "id")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "绑定不存在"})
+		return
 	}
-
-	// 检查响应状态
-	if userInfoResp.Code != 200 {
-		return nil, fmt.Errorf("获取用户信息出错: %s", userInfoResp.Msg)
+	if err := ctrl.identities.Delete(userID, id); errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "绑定不存在"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解除绑定失败"})
+		return
 	}
-
-	return &userInfoResp.Data, nil
+	c.JSON(http.StatusOK, gin.H{"message": "已解除绑定"})
 }
 
-// createUserFromSSE 根据SSE用户信息创建本地用户
-func createUserFromSSE(sseUser *SSEUserInfo) (models.User, error) {
+// createUser 根据外部账号创建本地用户，提供方给出的角色会记录到角色变更中
+func (ctrl *OAuth2Controller) createUser(provider oauth.Provider, identity *oauth.Identity) (*models.User, error) {
 	// 生成复杂的默认密码
 	defaultPassword := generateSecurePassword()
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(defaultPassword), bcrypt.DefaultCost)
 	if err != nil {
-		return models.User{}, err
+		return nil, err
 	}
 
-	// 生成用户名（基于邮箱前缀）
-	username := sseUser.Email
+	// 生成用户名（基于邮箱）
+	username := identity.Email
 	if len(username) > 50 {
 		username = username[:50]
 	}
@@ -290,9 +387,11 @@ func createUserFromSSE(sseUser *SSEUserInfo) (models.User, error) {
 	originalUsername := username
 	counter := 1
 	for {
-		var existingUser models.User
-		err := config.DB.Where("username = ?", username).First(&existingUser).Error
+		taken, err := ctrl.users.UsernameTaken(username)
 		if err != nil {
+			return nil, err
+		}
+		if !taken {
 			break // 用户名不存在，可以使用
 		}
 		username = fmt.Sprintf("%s_%d", originalUsername, counter)
@@ -302,26 +401,26 @@ func createUserFromSSE(sseUser *SSEUserInfo) (models.User, error) {
 	user := models.User{
 		Username: username,
 		Password: string(hashedPassword),
-		Email:    sseUser.Email,
-		Nickname: sseUser.Name,
-		Avatar:   sseUser.AvatarURL,
-		Role:     "user",
+		Email:    identity.Email,
+		Nickname: identity.Name,
+		Avatar:   identity.AvatarURL,
+		Role:     models.RoleUser,
+	}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
 	}
 
-	// 根据identity设置角色
-	if sseUser.Identity == "teacher" {
-		user.Role = "teacher"
-	} else if sseUser.Identity == "organization" {
-		user.Role = "user"
+	if identity.Role == "" || identity.Role == models.RoleUser {
+		err = ctrl.users.Create(&user)
 	} else {
-		user.Role = "user" // 默认为普通用户
+		user.Role = identity.Role
+		err = ctrl.roles.CreateUser(&user, &models.RoleChange{Source: models.RoleChangeOAuth})
 	}
-
-	if err := config.DB.Create(&user).Error; err != nil {
-		return models.User{}, err
+	if err != nil {
+		return nil, err
 	}
-
-	return user, nil
+	return &user, nil
 }
 
 // generateSecurePassword 生成安全的随机密码
@@ -0,0 +1,299 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/oauth"
+)
+
+// 测试中配置的两个提供方：trusted 可按已验证的邮箱绑定已有用户，untrusted 不可以
+const (
+	trustedProvider   = "trusted"
+	untrustedProvider = "untrusted"
+	callbackPath      = "/api/v1/auth/oauth2/callback"
+)
+
+// oauthEnv 接入了两个 FakeProvider 的后端
+type oauthEnv struct {
+	*testServer
+	fakes map[string]*oauth.FakeProvider
+}
+
+func newOAuthEnv(t *testing.T) *oauthEnv {
+	t.Helper()
+	fakes := map[string]*oauth.FakeProvider{}
+	server := newTestServer(t, func(cfg *config.Config) {
+		for _, p := range []struct {
+			name  string
+			trust bool
+		}{{trustedProvider, true}, {untrustedProvider, false}} {
+			srv := httptest.NewUnstartedServer(nil)
+			issuer := "http://" + srv.Listener.Addr().String()
+			fake := oauth.NewFakeProvider(issuer, p.name+"-client", p.name+"-secret")
+			srv.Config.Handler = fake
+			srv.Start()
+			t.Cleanup(srv.Close)
+
+			fakes[p.name] = fake
+			cfg.OAuth2.Providers = append(cfg.OAuth2.Providers, config.OAuth2Provider{
+				Name:         p.name,
+				Issuer:       issuer,
+				ClientID:     fake.ClientID,
+				ClientSecret: fake.ClientSecret,
+				RedirectURL:  "http://localhost" + callbackPath,
+				TrustEmail:   p.trust,
+			})
+		}
+	})
+	return &oauthEnv{testServer: server, fakes: fakes}
+}
+
+// browser 返回一个保存 Cookie 且不跟随跳转的客户端，相当于一个浏览器
+func (env *oauthEnv) browser() *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// authorize 以 browser 发起授权（linkToken 非空时为绑定），在提供方以 email 同意，
+// 返回回调地址中的查询参数
+func (env *oauthEnv) authorize(browser *http.Client, provider, email, linkToken string) url.Values {
+	env.t.Helper()
+	method, path := http.MethodGet, "/api/v1/auth/oauth2/state?provider="+provider
+	if linkToken != "" {
+		method, path = http.MethodPost, "/api/v1/auth/oauth2/link?provider="+provider
+	}
+	status, body := env.do(browser, method, path, linkToken, nil)
+	if status != http.StatusOK {
+		env.t.Fatalf("发起授权返回 %d: %v", status, body)
+	}
+	authURL, _ := body["authURL"].(string)
+	if authURL == "" {
+		env.t.Fatalf("响应中没有 authURL: %v", body)
+	}
+	return env.consent(authURL, email)
+}
+
+// consent 在提供方以 email 同意授权，返回回调地址中的查询参数
+func (env *oauthEnv) consent(authURL, email string) url.Values {
+	env.t.Helper()
+	resp, err := env.browser().Get(authURL + "&login_hint=" + url.QueryEscape(email))
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if resp.StatusCode != http.StatusFound || err != nil {
+		env.t.Fatalf("提供方返回 %d", resp.StatusCode)
+	}
+	return location.Query()
+}
+
+func (env *oauthEnv) callback(browser *http.Client, params url.Values) (int, map[string]interface{}) {
+	env.t.Helper()
+	return env.do(browser, http.MethodGet, callbackPath+"?"+params.Encode(), "", nil)
+}
+
+// login 完成一次授权登录，返回访问令牌和用户 ID
+func (env *oauthEnv) login(provider, email string) (string, uint) {
+	env.t.Helper()
+	browser := env.browser()
+	status, body := env.callback(browser, env.authorize(browser, provider, email, ""))
+	if status != http.StatusOK {
+		env.t.Fatalf("以 %s 登录 %s 返回 %d: %v", provider, email, status, body)
+	}
+	user, _ := body["user"].(map[string]interface{})
+	id, _ := user["id"].(float64)
+	return body["token"].(string), uint(id)
+}
+
+func (env *oauthEnv) identities(userID uint) []models.ExternalIdentity {
+	env.t.Helper()
+	identities, err := env.app.Repos.Identities.ListByUser(userID)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	return identities
+}
+
+func TestOAuth2FirstLoginCreatesUserAndIdentity(t *testing.T) {
+	env := newOAuthEnv(t)
+
+	_, userID := env.login(trustedProvider, "new@example.com")
+	user, err := env.app.Repos.Users.FindByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "new@example.com" || user.EmailVerifiedAt == nil {
+		t.Fatalf("新用户为 %+v，期望邮箱已验证", user)
+	}
+	identities := env.identities(userID)
+	if len(identities) != 1 || identities[0].Provider != trustedProvider || identities[0].Subject != "fake|new@example.com" {
+		t.Fatalf("绑定为 %+v", identities)
+	}
+
+	// 再次登录使用同一个用户
+	if _, again := env.login(trustedProvider, "new@example.com"); again != userID {
+		t.Fatalf("再次登录得到用户 %d，期望 %d", again, userID)
+	}
+	if count, _ := env.app.Repos.Users.Count(); count != 1 {
+		t.Fatalf("用户数为 %d，期望 1", count)
+	}
+}
+
+func TestOAuth2RefusesToLinkByEmail(t *testing.T) {
+	env := newOAuthEnv(t)
+	existing := env.createUser("alice", "alice@example.com", "secret123")
+	env.fakes[trustedProvider].UnverifiedEmails = []string{"alice@example.com"}
+
+	cases := []struct {
+		name     string
+		provider string
+	}{
+		{"提供方不可信", untrustedProvider},
+		{"邮箱未验证", trustedProvider},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			browser := env.browser()
+			status, body := env.callback(browser, env.authorize(browser, tc.provider, "alice@example.com", ""))
+			if status != http.StatusConflict {
+				t.Fatalf("返回 %d: %v，期望 409", status, body)
+			}
+		})
+	}
+	if identities := env.identities(existing.ID); len(identities) != 0 {
+		t.Fatalf("不应绑定外部账号: %+v", identities)
+	}
+
+	// 可信提供方返回已验证的邮箱时绑定已有用户
+	env.fakes[trustedProvider].UnverifiedEmails = nil
+	if _, userID := env.login(trustedProvider, "alice@example.com"); userID != existing.ID {
+		t.Fatalf("登录到用户 %d，期望已有用户 %d", userID, existing.ID)
+	}
+}
+
+func TestOAuth2LinkAndUnlink(t *testing.T) {
+	env := newOAuthEnv(t)
+	token, userID := env.login(trustedProvider, "bob@example.com")
+
+	browser := env.browser()
+	status, body := env.callback(browser, env.authorize(browser, untrustedProvider, "bob.other@example.com", token))
+	if status != http.StatusCreated {
+		t.Fatalf("绑定返回 %d: %v", status, body)
+	}
+	identities := env.identities(userID)
+	if len(identities) != 2 {
+		t.Fatalf("绑定后有 %d 个外部账号，期望 2", len(identities))
+	}
+
+	// 绑定后可用该外部账号登录同一用户
+	if _, again := env.login(untrustedProvider, "bob.other@example.com"); again != userID {
+		t.Fatalf("用绑定的账号登录到用户 %d，期望 %d", again, userID)
+	}
+
+	// 同一提供方不能再绑定另一个外部账号
+	browser = env.browser()
+	if status, body := env.callback(browser, env.authorize(browser, untrustedProvider, "bob.third@example.com", token)); status != http.StatusConflict {
+		t.Fatalf("重复绑定同一提供方返回 %d: %v，期望 409", status, body)
+	}
+
+	var linked models.ExternalIdentity
+	for _, identity := range identities {
+		if identity.Provider == untrustedProvider {
+			linked = identity
+		}
+	}
+	path := "/api/v1/auth/me/identities/" + strconv.FormatUint(uint64(linked.ID), 10)
+	if status, body := env.do(env.browser(), http.MethodDelete, path, token, nil); status != http.StatusOK {
+		t.Fatalf("解除绑定返回 %d: %v", status, body)
+	}
+	if status, _ := env.do(env.browser(), http.MethodDelete, path, token, nil); status != http.StatusNotFound {
+		t.Fatalf("再次解除绑定返回 %d，期望 404", status)
+	}
+	if identities := env.identities(userID); len(identities) != 1 || identities[0].Provider != trustedProvider {
+		t.Fatalf("解除绑定后为 %+v", identities)
+	}
+}
+
+func TestOAuth2LinkRejectsSubjectOfAnotherUser(t *testing.T) {
+	env := newOAuthEnv(t)
+	_, owner := env.login(untrustedProvider, "shared@example.com")
+	token, other := env.login(trustedProvider, "carol@example.com")
+
+	browser := env.browser()
+	status, body := env.callback(browser, env.authorize(browser, untrustedProvider, "shared@example.com", token))
+	if status != http.StatusConflict {
+		t.Fatalf("绑定他人的外部账号返回 %d: %v，期望 409", status, body)
+	}
+	if identities := env.identities(other); len(identities) != 1 {
+		t.Fatalf("不应为用户 %d 新增绑定: %+v", other, identities)
+	}
+	if identities := env.identities(owner); len(identities) != 1 {
+		t.Fatalf("原用户的绑定应保留: %+v", identities)
+	}
+}
+
+func TestOAuth2CallbackRejectsMismatch(t *testing.T) {
+	env := newOAuthEnv(t)
+
+	t.Run("其他浏览器", func(t *testing.T) {
+		params := env.authorize(env.browser(), trustedProvider, "dave@example.com", "")
+		if status, body := env.callback(env.browser(), params); status != http.StatusBadRequest {
+			t.Fatalf("返回 %d: %v，期望 400", status, body)
+		}
+	})
+
+	t.Run("未知的state", func(t *testing.T) {
+		browser := env.browser()
+		params := env.authorize(browser, trustedProvider, "dave@example.com", "")
+		params.Set("state", "unknown")
+		if status, body := env.callback(browser, params); status != http.StatusBadRequest {
+			t.Fatalf("返回 %d: %v，期望 400", status, body)
+		}
+	})
+
+	t.Run("state重复使用", func(t *testing.T) {
+		browser := env.browser()
+		params := env.authorize(browser, trustedProvider, "dave@example.com", "")
+		if status, body := env.callback(browser, params); status != http.StatusOK {
+			t.Fatalf("首次回调返回 %d: %v", status, body)
+		}
+		if status, body := env.callback(browser, params); status != http.StatusBadRequest {
+			t.Fatalf("重复回调返回 %d: %v，期望 400", status, body)
+		}
+	})
+
+	t.Run("PKCE不匹配", func(t *testing.T) {
+		browser := env.browser()
+		status, body := env.do(browser, http.MethodGet, "/api/v1/auth/oauth2/state?provider="+trustedProvider, "", nil)
+		if status != http.StatusOK {
+			t.Fatalf("发起授权返回 %d: %v", status, body)
+		}
+		// 提供方收到的 code_challenge 与服务端保存的 code_verifier 不对应
+		authURL, _ := url.Parse(body["authURL"].(string))
+		q := authURL.Query()
+		q.Set("code_challenge", oauth.CodeChallenge("another-verifier"))
+		authURL.RawQuery = q.Encode()
+
+		if status, body := env.callback(browser, env.consent(authURL.String(), "erin@example.com")); status != http.StatusBadGateway {
+			t.Fatalf("返回 %d: %v，期望 502", status, body)
+		}
+		if _, err := env.app.Repos.Users.FindByEmail("erin@example.com"); err == nil {
+			t.Fatal("PKCE 校验失败时不应创建用户")
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/mail"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 找回密码的频率限制，防止借此向他人邮箱轰炸或耗尽发信额度
+const (
+	resetMaxPerEmail = 5 // 同一邮箱在 resetLimitWindow 内最多申请的次数
+	resetMaxPerIP    = 20
+	resetLimitWindow = time.Hour
+	resetCooldown    = 2 * time.Minute // 距上一封重置邮件不足该时长时不再发送
+	resetMaxSending  = 8               // 同时在后台发送的重置邮件上限
+)
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6,max=50"`
+}
+
+// PasswordController 处理忘记密码和通过邮件链接重置密码
+type PasswordController struct {
+	users      repository.UserRepository
+	userTokens repository.UserTokenRepository
+	sessions   repository.SessionRepository
+	attempts   repository.LoginAttemptRepository
+	limit      requestLimit
+	sending    chan struct{} // 后台发送的信号量
+	mailer     mail.Mailer
+	publicURL  string
+	resetTTL   time.Duration
+}
+
+func NewPasswordController(repos *repository.Repositories, mailer mail.Mailer, publicURL string, cfg config.AuthConfig) *PasswordController {
+	return &PasswordController{
+		users:      repos.Users,
+		userTokens: repos.UserTokens,
+		sessions:   repos.Sessions,
+		attempts:   repos.LoginAttempts,
+		limit:      requestLimit{attempts: repos.LoginAttempts, window: resetLimitWindow},
+		sending:    make(chan struct{}, resetMaxSending),
+		mailer:     mailer,
+		publicURL:  strings.TrimRight(publicURL, "/"),
+		resetTTL:   cfg.PasswordResetTTL,
+	}
+}
+
+// ForgotPassword 向邮箱发送重置密码链接。
+// 无论邮箱是否注册都返回相同的结果，邮件在后台发送，避免通过响应内容或耗时探测账号。
+// 同一邮箱和同一 IP 的申请次数受限，超过后返回 429
+func (ctrl *PasswordController) ForgotPassword(c *gin.Context) {
+	var input ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wait, err := ctrl.limit.take(
+		limitSubject{models.LoginScopeResetEmail, strings.ToLower(input.Email), resetMaxPerEmail},
+		limitSubject{models.LoginScopeResetIP, c.ClientIP(), resetMaxPerIP},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送重置邮件失败"})
+		return
+	}
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "申请过于频繁，请稍后再试", "retryAfter": seconds})
+		return
+	}
+
+	select {
+	case ctrl.sending <- struct{}{}:
+		go func() {
+			defer func() { <-ctrl.sending }()
+			ctrl.sendResetLink(input.Email)
+		}()
+	default:
+		log.Printf("重置密码：后台发送的邮件已达上限 %d 封，忽略本次申请", resetMaxSending)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱已注册，重置密码的邮件已发送，请查收"})
+}
+
+func (ctrl *PasswordController) sendResetLink(email string) {
+	user, err := ctrl.users.FindByEmail(email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return
+	} else if err != nil {
+		log.Printf("重置密码：查询用户失败: %v", err)
+		return
+	}
+
+	// 刚发过的链接仍然有效，不重复发送
+	recent, err := ctrl.userTokens.IssuedSince(user.ID, models.TokenPurposePasswordReset, time.Now().Add(-resetCooldown))
+	if err != nil {
+		log.Printf("重置密码：查询令牌失败: %v", err)
+		return
+	}
+	if recent {
+		return
+	}
+
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		log.Printf("重置密码：生成令牌失败: %v", err)
+		return
+	}
+	record := models.UserToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposePasswordReset,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ctrl.resetTTL),
+	}
+	if err := ctrl.userTokens.Replace(&record); err != nil {
+		log.Printf("重置密码：保存令牌失败: %v", err)
+		return
+	}
+
+	link := ctrl.publicURL + "/reset-password?token=" + url.QueryEscape(token)
+	msg := mail.PasswordReset(user.Email, user.Nickname, user.Username, link, ctrl.resetTTL)
+	if err := ctrl.mailer.Send(context.Background(), msg); err != nil {
+		log.Printf("重置密码：发送邮件给用户 %d 失败: %v", user.ID, err)
+	}
+}
+
+// ResetPassword 用邮件中的一次性令牌设置新密码，成功后该用户的全部会话下线
+func (ctrl *PasswordController) ResetPassword(c *gin.Context) {
+	var input ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := ctrl.userTokens.Consume(models.TokenPurposePasswordReset, utils.HashOpaqueToken(input.Token))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "重置链接无效或已过期，请重新申请"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+		return
+	}
+
+	user, err := ctrl.users.FindByID(token.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "重置链接无效或已过期，请重新申请"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+		return
+	}
+	user.Password = string(hashedPassword)
+	if err := ctrl.users.Save(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+		return
+	}
+
+	// 旧密码可能已泄露，已登录的设备全部下线
+	if _, err := ctrl.sessions.RevokeAllByUser(user.ID, 0); err != nil {
+		log.Printf("重置密码：吊销用户 %d 的会话失败: %v", user.ID, err)
+	}
+	// 能收到重置邮件说明是本人，解除账号的登录锁定
+	if err := ctrl.attempts.Reset(models.LoginScopeUser, accountKey(user.Username)); err != nil {
+		log.Printf("重置密码：解除用户 %d 的登录锁定失败: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码已重置，请使用新密码登录"})
+}
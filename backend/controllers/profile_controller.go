@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/mail"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 以下输入的校验规则与 RegisterInput 保持一致
+
+type UpdateProfileInput struct {
+	Nickname string `json:"nickname" binding:"required,min=2,max=30"`
+	Avatar   string `json:"avatar"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=6,max=50"`
+}
+
+type ChangeEmailInput struct {
+	Email           string `json:"email" binding:"required,email"`
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+}
+
+// ProfileController 处理用户自助修改资料、密码和邮箱
+type ProfileController struct {
+	users        repository.UserRepository
+	sessions     repository.SessionRepository
+	sender       verificationSender
+	registration config.RegistrationConfig
+	authz        *rbac.Authorizer
+}
+
+func NewProfileController(repos *repository.Repositories, mailer mail.Mailer, publicURL string, cfg config.AuthConfig, authz *rbac.Authorizer) *ProfileController {
+	return &ProfileController{
+		users:        repos.Users,
+		sessions:     repos.Sessions,
+		sender:       newVerificationSender(repos, mailer, publicURL, cfg),
+		registration: cfg.Registration,
+		authz:        authz,
+	}
+}
+
+// GetPermissions 返回当前访问令牌中的角色及其拥有的权限，前端据此显示管理功能
+func (ctrl *ProfileController) GetPermissions(c *gin.Context) {
+	role := c.GetString("role")
+	c.JSON(http.StatusOK, gin.H{
+		"role":        role,
+		"permissions": ctrl.authz.Permissions(role),
+	})
+}
+
+// currentUser 读取当前登录的用户，失败时写入错误响应
+func (ctrl *ProfileController) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return nil, false
+	}
+	user, err := ctrl.users.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return nil, false
+	}
+	return user, true
+}
+
+// checkPassword 校验当前密码，修改密码和邮箱前需要再次确认身份
+func checkPassword(c *gin.Context, user *models.User, password string) bool {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "当前密码错误"})
+		return false
+	}
+	return true
+}
+
+// UpdateProfile 修改当前用户的昵称和头像
+func (ctrl *ProfileController) UpdateProfile(c *gin.Context) {
+	var input UpdateProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := ctrl.currentUser(c)
+	if !ok {
+		return
+	}
+
+	user.Nickname = input.Nickname
+	user.Avatar = input.Avatar
+	if err := ctrl.users.Save(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新资料失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "资料已更新", "data": user})
+}
+
+// ChangePassword 确认当前密码后修改密码，当前会话以外的设备全部下线
+func (ctrl *ProfileController) ChangePassword(c *gin.Context) {
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := ctrl.currentUser(c)
+	if !ok || !checkPassword(c, user, input.CurrentPassword) {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改密码失败"})
+		return
+	}
+	user.Password = string(hashedPassword)
+	if err := ctrl.users.Save(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改密码失败"})
+		return
+	}
+
+	_, sessionID, _ := currentSession(c)
+	revoked, err := ctrl.sessions.RevokeAllByUser(user.ID, sessionID)
+	if err != nil {
+		log.Printf("修改密码：吊销用户 %d 的其他会话失败: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码已修改，其他设备需要重新登录", "revoked": revoked})
+}
+
+// ChangeEmail 确认当前密码后向新邮箱发送确认链接，打开链接后邮箱才会被替换
+func (ctrl *ProfileController) ChangeEmail(c *gin.Context) {
+	var input ChangeEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := ctrl.currentUser(c)
+	if !ok || !checkPassword(c, user, input.CurrentPassword) {
+		return
+	}
+
+	if input.Email == user.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "新邮箱与当前邮箱相同"})
+		return
+	}
+	// 限制注册域名时，通过域名注册的用户不能改用其他域名的邮箱
+	if ctrl.registration.Mode == config.RegistrationDomain &&
+		ctrl.registration.AllowsEmail(user.Email) && !ctrl.registration.AllowsEmail(input.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不允许使用该域名的邮箱"})
+		return
+	}
+	if taken, err := ctrl.users.EmailTaken(input.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查邮箱失败"})
+		return
+	} else if taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱已被使用"})
+		return
+	}
+
+	if err := ctrl.sender.sendChange(c.Request.Context(), *user, input.Email); err != nil {
+		log.Printf("发送邮箱确认邮件给用户 %d 失败: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送确认邮件失败，请稍后再试"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "确认邮件已发送到新邮箱，打开邮件中的链接后生效"})
+}
@@ -3,14 +3,23 @@ package controllers
 import (
 	"fmt"
 	"net/http"
-	"xuan-ke-tong/config"
 	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RatingController 处理课程评分
+type RatingController struct {
+	ratings repository.RatingRepository
+}
+
+func NewRatingController(ratings repository.RatingRepository) *RatingController {
+	return &RatingController{ratings: ratings}
+}
+
 // CreateRating handles the creation of a new rating
-func CreateRating(c *gin.Context) {
+func (ctrl *RatingController) CreateRating(c *gin.Context) {
 	var input struct {
 		Score       float64 `json:"score" binding:"required"`
 		Difficulty  float64 `json:"difficulty" binding:"required"`
@@ -56,7 +65,7 @@ func CreateRating(c *gin.Context) {
 		Content:    input.Content,
 	}
 
-	if err := config.DB.Create(&rating).Error; err != nil {
+	if err := ctrl.ratings.Create(&rating); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rating"})
 		return
 	}
@@ -64,9 +73,10 @@ func CreateRating(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Rating created successfully", "data": rating})
 }
 
-func GetRatingsByCourse(c *gin.Context) {
-	var ratings []models.Rating
-	if err := config.DB.Preload("User").Where("course_id = ?", c.Param("id")).Find(&ratings).Error; err != nil {
+func (ctrl *RatingController) GetRatingsByCourse(c *gin.Context) {
+	courseID, _ := paramID(c, "id")
+	ratings, err := ctrl.ratings.ListByCourse(courseID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ratings"})
 		return
 	}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 角色名只允许小写字母、数字、下划线和连字符
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,29}$`)
+
+type RoleInput struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description" binding:"max=200"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleTwoFactorInput struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// RoleController 处理管理后台的角色和权限管理
+type RoleController struct {
+	roles repository.RoleRepository
+	users repository.UserRepository
+	authz *rbac.Authorizer
+	audit auditor
+}
+
+func NewRoleController(repos *repository.Repositories, authz *rbac.Authorizer) *RoleController {
+	return &RoleController{roles: repos.Roles, users: repos.Users, authz: authz, audit: auditor{logs: repos.Audit}}
+}
+
+// ListPermissions 列出全部可分配的权限
+func (ctrl *RoleController) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": models.Permissions})
+}
+
+// ListRoles 列出全部角色及其权限，admin 的权限按全部权限返回
+func (ctrl *RoleController) ListRoles(c *gin.Context) {
+	roles, err := ctrl.roles.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色失败"})
+		return
+	}
+	for i := range roles {
+		if roles[i].Name == models.RoleAdmin {
+			roles[i].Permissions = ctrl.authz.Permissions(models.RoleAdmin)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": roles})
+}
+
+// CreateRole 新建角色
+func (ctrl *RoleController) CreateRole(c *gin.Context) {
+	var input RoleInput
+	if !bindRoleInput(c, &input) {
+		return
+	}
+	if !roleNamePattern.MatchString(input.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "角色名须以小写字母开头，只包含小写字母、数字、下划线和连字符，长度 2-30"})
+		return
+	}
+	if _, err := ctrl.roles.FindByName(input.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "角色已存在"})
+		return
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查角色失败"})
+		return
+	}
+
+	role := models.Role{Name: input.Name, Description: input.Description, Permissions: input.Permissions}
+	if err := ctrl.roles.Create(&role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建角色失败"})
+		return
+	}
+	ctrl.authz.Invalidate()
+	ctrl.audit.record(c, models.AuditRoleCreate, models.AuditTargetRole, role.ID, nil, &role)
+	c.JSON(http.StatusCreated, gin.H{"message": "角色创建成功", "data": role})
+}
+
+// UpdateRole 修改角色的说明和权限，角色名不能修改
+func (ctrl *RoleController) UpdateRole(c *gin.Context) {
+	role, ok := ctrl.findRole(c)
+	if !ok {
+		return
+	}
+	if role.Name == models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin 角色拥有全部权限，不能修改"})
+		return
+	}
+	var input RoleInput
+	if !bindRoleInput(c, &input) {
+		return
+	}
+	before := *role
+
+	role.Description = input.Description
+	role.Permissions = input.Permissions
+	if err := ctrl.roles.Save(role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新角色失败"})
+		return
+	}
+	ctrl.authz.Invalidate()
+	ctrl.audit.record(c, models.AuditRoleUpdate, models.AuditTargetRole, role.ID, &before, role)
+	c.JSON(http.StatusOK, gin.H{"message": "角色更新成功", "data": role})
+}
+
+// SetTwoFactor 设置角色是否要求两步验证，admin 角色同样可以设置。开启后该角色的用户
+// 必须通过两步验证登录才能使用权限，尚未启用的用户登录后需先完成启用
+func (ctrl *RoleController) SetTwoFactor(c *gin.Context) {
+	role, ok := ctrl.findRole(c)
+	if !ok {
+		return
+	}
+	var input RoleTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	before := *role
+
+	switch {
+	case *input.Required && role.TwoFactorRequiredAt == nil:
+		now := time.Now()
+		role.TwoFactorRequiredAt = &now
+	case !*input.Required:
+		role.TwoFactorRequiredAt = nil
+	}
+	if err := ctrl.roles.Save(role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新角色失败"})
+		return
+	}
+	ctrl.authz.Invalidate()
+	ctrl.audit.record(c, models.AuditRoleUpdate, models.AuditTargetRole, role.ID, &before, role)
+	c.JSON(http.StatusOK, gin.H{"message": "角色更新成功", "data": role})
+}
+
+// DeleteRole 删除自定义角色，内置角色和仍有用户使用的角色不能删除
+func (ctrl *RoleController) DeleteRole(c *gin.Context) {
+	role, ok := ctrl.findRole(c)
+	if !ok {
+		return
+	}
+	if models.IsBuiltinRole(role.Name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能删除内置角色"})
+		return
+	}
+	count, err := ctrl.users.CountByRole(role.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查角色使用情况失败"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "仍有用户使用该角色", "users": count})
+		return
+	}
+
+	if err := ctrl.roles.Delete(role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除角色失败"})
+		return
+	}
+	ctrl.authz.Invalidate()
+	ctrl.audit.record(c, models.AuditRoleDelete, models.AuditTargetRole, role.ID, role, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "角色删除成功"})
+}
+
+// ListRoleChanges 分页列出用户角色的变更记录，可按 userId 筛选
+func (ctrl *RoleController) ListRoleChanges(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	userID, _ := strconv.ParseUint(c.Query("userId"), 10, 64)
+
+	changes, total, err := ctrl.roles.ListChanges(repository.RoleChangeFilter{
+		UserID:   uint(userID),
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色变更记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     changes,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// findRole 按路径参数查询角色，不存在时直接写入 404 响应
+func (ctrl *RoleController) findRole(c *gin.Context) (*models.Role, bool) {
+	role, err := ctrl.roles.FindByName(c.Param("name"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色失败"})
+		return nil, false
+	}
+	return role, true
+}
+
+// bindRoleInput 解析请求并检查权限名，失败时直接写入 400 响应
+func bindRoleInput(c *gin.Context, input *RoleInput) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return false
+	}
+	seen := make(map[string]bool, len(input.Permissions))
+	perms := []string{}
+	for _, p := range input.Permissions {
+		if !models.IsPermission(p) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未知的权限: " + p})
+			return false
+		}
+		if !seen[p] {
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+	input.Permissions = perms
+	return true
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"html"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 搜索参数的限制
+const (
+	searchMaxTerms      = 8
+	searchMaxTermLength = 64 // 单个搜索词的最大字符数
+	searchMaxPageSize   = 50
+)
+
+// 摘要在第一处命中前后保留的字符数
+const searchSnippetContext = 30
+
+// SearchController 处理课程全文搜索
+type SearchController struct {
+	search  repository.SearchRepository
+	courses repository.CourseRepository
+}
+
+func NewSearchController(repos *repository.Repositories) *SearchController {
+	return &SearchController{search: repos.Search, courses: repos.Courses}
+}
+
+// SearchResult 一条搜索结果，Highlights 为命中字段的高亮片段（已转义的 HTML，命中处以 <mark> 标出）
+type SearchResult struct {
+	models.Course
+	AverageRating float64           `json:"averageRating"`
+	TotalRatings  int64             `json:"totalRatings"`
+	Relevance     float64           `json:"relevance"`
+	Score         float64           `json:"score"`
+	Highlights    map[string]string `json:"highlights"`
+}
+
+// Search 按关键词 q 搜索课程名称、简介、授课教师和评价内容，结果按相关度与评分的混合得分排序。
+// fields 以逗号分隔限定搜索的字段，grade、semester、subject 与课程列表的筛选相同
+func (ctrl *SearchController) Search(c *gin.Context) {
+	terms := strings.Fields(c.Query("q"))
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入搜索关键词"})
+		return
+	}
+	if len(terms) > searchMaxTerms {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "搜索关键词最多 " + strconv.Itoa(searchMaxTerms) + " 个"})
+		return
+	}
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) > searchMaxTermLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "搜索关键词过长"})
+			return
+		}
+	}
+
+	var fields []string
+	for _, field := range strings.Split(c.Query("fields"), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || slices.Contains(fields, field) {
+			continue
+		}
+		if !slices.Contains(repository.SearchFields, field) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "不支持的搜索字段: " + field,
+				"fields": repository.SearchFields,
+			})
+			return
+		}
+		fields = append(fields, field)
+	}
+
+	page := 1
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	pageSize := 10
+	if ps, err := strconv.Atoi(c.Query("pageSize")); err == nil && ps > 0 {
+		pageSize = min(ps, searchMaxPageSize)
+	}
+
+	hits, total, err := ctrl.search.Search(repository.SearchQuery{
+		Terms:    terms,
+		Fields:   fields,
+		Grade:    c.Query("grade"),
+		Semester: c.Query("semester"),
+		Subject:  c.Query("subject"),
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+		return
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.CourseID
+	}
+	reviews := map[uint][]string{}
+	if len(fields) == 0 || slices.Contains(fields, repository.SearchFieldReviews) {
+		if reviews, err = ctrl.search.ReviewTexts(ids); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+			return
+		}
+	}
+
+	courses, err := ctrl.courses.FindByIDs(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+		return
+	}
+	byID := make(map[uint]*models.Course, len(courses))
+	for i := range courses {
+		byID[courses[i].ID] = &courses[i]
+	}
+
+	// 按搜索结果的顺序输出
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		course, ok := byID[hit.CourseID]
+		if !ok {
+			// 课程在两次查询之间被删除
+			continue
+		}
+		results = append(results, SearchResult{
+			Course:        *course,
+			AverageRating: hit.AverageRating,
+			TotalRatings:  hit.TotalRatings,
+			Relevance:     hit.Relevance,
+			Score:         hit.Score,
+			Highlights:    searchHighlights(course, reviews[course.ID], terms, fields),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     results,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// searchHighlights 返回各搜索字段中命中的片段：名称和教师为完整内容，简介和评价为命中处附近的摘要，
+// 评价取第一条命中的
+func searchHighlights(course *models.Course, reviews, terms, fields []string) map[string]string {
+	searched := func(field string) bool { return len(fields) == 0 || slices.Contains(fields, field) }
+	highlights := map[string]string{}
+	if searched(repository.SearchFieldName) {
+		if text, ok := highlight(course.Name, terms, false); ok {
+			highlights[repository.SearchFieldName] = text
+		}
+	}
+	if searched(repository.SearchFieldTeacher) {
+		if text, ok := highlight(course.Teacher, terms, false); ok {
+			highlights[repository.SearchFieldTeacher] = text
+		}
+	}
+	if searched(repository.SearchFieldDescription) {
+		if text, ok := highlight(course.Description, terms, true); ok {
+			highlights[repository.SearchFieldDescription] = text
+		}
+	}
+	if searched(repository.SearchFieldReviews) {
+		for _, review := range reviews {
+			if text, ok := highlight(review, terms, true); ok {
+				highlights[repository.SearchFieldReviews] = text
+				break
+			}
+		}
+	}
+	return highlights
+}
+
+// highlight 转义 text 并以 <mark> 标出不区分大小写的命中；snippet 为 true 时只保留第一处命中
+// 前后的内容。没有命中时第二个返回值为 false
+func highlight(text string, terms []string, snippet bool) (string, bool) {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		needle := []rune(strings.ToLower(term))
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if slices.Equal(lower[i:i+len(needle)], needle) {
+				for j := i; j < i+len(needle); j++ {
+					marked[j] = true
+				}
+				if first == -1 || i < first {
+					first = i
+				}
+			}
+		}
+	}
+	if first == -1 {
+		return "", false
+	}
+
+	start, end := 0, len(runes)
+	if snippet {
+		start = max(0, first-searchSnippetContext)
+		end = min(len(runes), first+2*searchSnippetContext)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			segment = "<mark>" + segment + "</mark>"
+		}
+		b.WriteString(segment)
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
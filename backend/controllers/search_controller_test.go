@@ -0,0 +1,33 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"xuan-ke-tong/models"
+)
+
+func TestSearchCapsPageSize(t *testing.T) {
+	s := newTestServer(t, nil)
+	for i := 0; i < 55; i++ {
+		if err := s.app.Repos.Courses.Create(&models.Course{Name: fmt.Sprintf("选修课程%02d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := "/api/v1/search?pageSize=1000&q=" + url.QueryEscape("选修课程")
+	status, body := s.do(http.DefaultClient, http.MethodGet, path, "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("搜索返回 %d: %v", status, body)
+	}
+	data, _ := body["data"].([]interface{})
+	if body["pageSize"] != float64(50) || len(data) != 50 || body["total"] != float64(55) {
+		t.Fatalf("pageSize=%v，返回 %d 条，总数 %v；期望每页最多 50 条、总数 55", body["pageSize"], len(data), body["total"])
+	}
+
+	status, body = s.do(http.DefaultClient, http.MethodGet, path+"&page=2", "", nil)
+	if data, _ := body["data"].([]interface{}); status != http.StatusOK || len(data) != 5 {
+		t.Fatalf("第 2 页返回 %d，%d 条，期望 5 条", status, len(data))
+	}
+}
@@ -0,0 +1,98 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/migrations"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/routes"
+	"xuan-ke-tong/testdb"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testServer 在临时 SQLite 库上运行的完整后端
+type testServer struct {
+	t   *testing.T
+	app *app.Application
+	api *httptest.Server
+}
+
+// newTestServer 以默认配置启动后端，configure 可在启动前修改配置
+func newTestServer(t *testing.T, configure func(cfg *config.Config)) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db := testdb.SQLite(t)
+	if _, err := migrations.Up(db, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.JWT.Secret = "controllers-test-secret-0123456789abcdef"
+	cfg.Mail.OutboxDir = t.TempDir()
+	cfg.Backup.Dir = t.TempDir()
+	if configure != nil {
+		configure(cfg)
+	}
+
+	a, err := app.New(cfg, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(routes.NewRouter(a))
+	t.Cleanup(api.Close)
+	return &testServer{t: t, app: a, api: api}
+}
+
+// do 以 client 发送请求并解析 JSON 响应，body 非空时以 JSON 发送
+func (s *testServer) do(client *http.Client, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.api.URL+path, reader)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	decoded := map[string]interface{}{}
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp.StatusCode, decoded
+}
+
+// createUser 创建一个以 password 登录的用户
+func (s *testServer) createUser(username, email, password string) *models.User {
+	s.t.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	user := &models.User{Username: username, Password: string(hashed), Email: email, Role: models.RoleUser}
+	if err := s.app.Repos.Users.Create(user); err != nil {
+		s.t.Fatal(err)
+	}
+	return user
+}
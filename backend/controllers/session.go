@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionIssuer 为登录成功的用户创建会话并签发令牌，注册、登录和 OAuth2 回调共用
+type sessionIssuer struct {
+	sessions repository.SessionRepository
+	tokens   *utils.TokenManager
+}
+
+// issue 记录客户端信息创建新会话，返回访问令牌和刷新令牌。
+// twoFactor 表示本次登录已通过两步验证
+func (s sessionIssuer) issue(c *gin.Context, user models.User, twoFactor bool) (*AuthResponse, error) {
+	refreshToken, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:     user.ID,
+		TokenHash:  hash,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.tokens.RefreshTTL()),
+	}
+	if twoFactor {
+		session.TwoFactorAt = &now
+	}
+	if err := s.sessions.Create(&session); err != nil {
+		return nil, err
+	}
+	return s.respond(user, session, refreshToken)
+}
+
+// respond 为已有会话签发访问令牌
+func (s sessionIssuer) respond(user models.User, session models.Session, refreshToken string) (*AuthResponse, error) {
+	token, err := s.tokens.GenerateToken(user, session)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.tokens.TTL().Seconds()),
+		User:         user,
+	}, nil
+}
+
+// currentSession 返回 AuthMiddleware 写入的当前用户和会话
+func currentSession(c *gin.Context) (userID, sessionID uint, ok bool) {
+	uid, exists := c.Get("userId")
+	if !exists {
+		return 0, 0, false
+	}
+	sid, _ := c.Get("sessionId")
+	sessionID, _ = sid.(uint)
+	return uid.(uint), sessionID, true
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrashController 处理管理后台回收站的查看和恢复
+type TrashController struct {
+	trash repository.TrashRepository
+	audit auditor
+}
+
+func NewTrashController(repos *repository.Repositories) *TrashController {
+	return &TrashController{trash: repos.Trash, audit: auditor{logs: repos.Audit}}
+}
+
+// ListTrash 列出回收站中指定类型的记录：courses、users、ratings、comments
+func (ctrl *TrashController) ListTrash(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+
+	items, total, err := ctrl.trash.List(c.Param("type"), page, pageSize)
+	if errors.Is(err, repository.ErrUnknownTrashKind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的类型", "types": repository.TrashKinds})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     items,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// RestoreTrash 从回收站恢复记录
+func (ctrl *TrashController) RestoreTrash(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return
+	}
+
+	err := ctrl.trash.Restore(c.Param("type"), id)
+	switch {
+	case errors.Is(err, repository.ErrUnknownTrashKind):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的类型", "types": repository.TrashKinds})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "回收站中没有该记录"})
+	case errors.Is(err, repository.ErrParentDeleted):
+		c.JSON(http.StatusConflict, gin.H{"error": "所属的课程或用户仍在回收站中，请先恢复它们"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复失败"})
+	default:
+		ctrl.audit.record(c, models.AuditTrashRestore, c.Param("type"), id, nil, nil)
+		c.JSON(http.StatusOK, gin.H{"message": "恢复成功"})
+	}
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorChallenge 已启用两步验证的用户通过密码或第三方登录后返回，
+// 凭 challengeToken 和动态验证码调用 /auth/login/2fa 完成登录
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+	ExpiresIn         int64  `json:"expiresIn"` // 挑战令牌的有效秒数
+}
+
+// twoFactorLogin 登录的第一步通过后决定是否需要第二步，密码登录和 OAuth2 回调共用
+type twoFactorLogin struct {
+	issuer     sessionIssuer
+	userTokens repository.UserTokenRepository
+	authz      *rbac.Authorizer
+	ttl        time.Duration
+}
+
+// begin 已启用两步验证的用户返回挑战，其余用户直接创建会话。
+// 角色要求两步验证但用户尚未启用时照常登录，响应中标记 twoFactorSetupRequired，
+// 该会话在启用两步验证之前不能使用任何权限
+func (l twoFactorLogin) begin(c *gin.Context, user models.User) (*AuthResponse, *TwoFactorChallenge, error) {
+	if user.TOTPEnabledAt == nil {
+		resp, err := l.issuer.issue(c, user, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp.TwoFactorSetupRequired = l.authz.RequiresTwoFactor(user.Role)
+		return resp, nil, nil
+	}
+
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	challenge := models.UserToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposeTwoFactorLogin,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(l.ttl),
+	}
+	if err := l.userTokens.Replace(&challenge); err != nil {
+		return nil, nil, err
+	}
+	return nil, &TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int64(l.ttl.Seconds()),
+	}, nil
+}
@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+type VerifyTwoFactorInput struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code"`         // 验证器中的动态验证码
+	RecoveryCode   string `json:"recoveryCode"` // 丢失验证器时使用恢复码
+}
+
+type SetupTwoFactorInput struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorInput struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	Code            string `json:"code"`
+	RecoveryCode    string `json:"recoveryCode"`
+}
+
+// TwoFactorController 处理两步验证的启用、关闭、恢复码和登录的第二步
+type TwoFactorController struct {
+	users      repository.UserRepository
+	sessions   repository.SessionRepository
+	twoFactor  repository.TwoFactorRepository
+	userTokens repository.UserTokenRepository
+	issuer     sessionIssuer
+	guard      loginGuard
+	authz      *rbac.Authorizer
+	cfg        config.TwoFactorConfig
+}
+
+func NewTwoFactorController(repos *repository.Repositories, tokens *utils.TokenManager, authz *rbac.Authorizer, cfg config.AuthConfig) *TwoFactorController {
+	return &TwoFactorController{
+		users:      repos.Users,
+		sessions:   repos.Sessions,
+		twoFactor:  repos.TwoFactor,
+		userTokens: repos.UserTokens,
+		issuer:     sessionIssuer{sessions: repos.Sessions, tokens: tokens},
+		guard:      loginGuard{attempts: repos.LoginAttempts, cfg: cfg.Lockout},
+		authz:      authz,
+		cfg:        cfg.TwoFactor,
+	}
+}
+
+// VerifyLogin 登录的第二步：凭挑战令牌和动态验证码（或恢复码）创建会话。
+// 验证码错误计入账号的登录失败次数，与密码错误共用锁定策略
+func (ctrl *TwoFactorController) VerifyLogin(c *gin.Context) {
+	var input VerifyTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (input.Code == "") == (input.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供验证码或恢复码之一"})
+		return
+	}
+
+	hash := utils.HashOpaqueToken(input.ChallengeToken)
+	challenge, err := ctrl.userTokens.Find(models.TokenPurposeTwoFactorLogin, hash)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已超时，请重新输入密码"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证失败"})
+		return
+	}
+	user, err := ctrl.users.FindByID(challenge.UserID)
+	if err != nil || user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已超时，请重新输入密码"})
+		return
+	}
+
+	wait, err := ctrl.guard.lockedFor(c, user.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证失败"})
+		return
+	}
+	if wait > 0 {
+		ctrl.guard.fail(c, user.Username, models.LoginFailureLocked)
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "登录失败次数过多，请稍后再试", "retryAfter": seconds})
+		return
+	}
+
+	ok, err := ctrl.verify(user, input.Code, input.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证失败"})
+		return
+	}
+	if !ok {
+		ctrl.guard.fail(c, user.Username, models.LoginFailureBadCode)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "验证码错误"})
+		return
+	}
+
+	// 并发提交同一挑战时只有一个请求能创建会话
+	if _, err := ctrl.userTokens.Consume(models.TokenPurposeTwoFactorLogin, hash); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已超时，请重新输入密码"})
+		return
+	}
+	ctrl.guard.succeed(user.Username)
+
+	resp, err := ctrl.issuer.issue(c, *user, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "签发令牌失败"})
+		return
+	}
+	// 使用恢复码登录时提示剩余数量，用完前应重新生成
+	if input.RecoveryCode != "" {
+		remaining, err := ctrl.twoFactor.CountRecoveryCodes(user.ID)
+		if err != nil {
+			log.Printf("统计剩余恢复码失败: %v", err)
+		}
+		resp.RecoveryCodesRemaining = &remaining
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetStatus 返回当前用户的两步验证状态
+func (ctrl *TwoFactorController) GetStatus(c *gin.Context) {
+	user, ok := ctrl.currentUser(c)
+	if !ok {
+		return
+	}
+	remaining, err := ctrl.twoFactor.CountRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取两步验证状态失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                user.TOTPEnabledAt != nil,
+		"enabledAt":              user.TOTPEnabledAt,
+		"required":               ctrl.authz.RequiresTwoFactor(user.Role),
+		"recoveryCodesRemaining": remaining,
+	})
+}
+
+// Setup 生成新的待确认密钥，返回密钥和供前端生成二维码的 otpauth:// 地址。
+// 用验证器中显示的验证码调用 Enable 后才会生效
+func (ctrl *TwoFactorController) Setup(c *gin.Context) {
+	user, ok := ctrl.currentUser(c)
+	if !ok {
+		return
+	}
+	var input SetupTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "两步验证已启用"})
+		return
+	}
+	if !checkPassword(c, user, input.CurrentPassword) {
+		return
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密钥失败"})
+		return
+	}
+	err = ctrl.twoFactor.SetPendingSecret(user.ID, secret)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "两步验证已启用"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密钥失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":     secret,
+		"otpauthURL": utils.TOTPURI(ctrl.cfg.Issuer, user.Username, secret),
+	})
+}
+
+// Enable 用验证码确认待启用的密钥，返回只显示一次的恢复码。
+// 当前会话随即视为已通过两步验证，并返回新的访问令牌
+func (ctrl *TwoFactorController) Enable(c *gin.Context) {
+	user, ok := ctrl.currentUser(c)
+	if !ok {
+		return
+	}
+	var input TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "两步验证已启用"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请先获取密钥"})
+		return
+	}
+	step, valid := utils.VerifyTOTP(user.TOTPSecret, input.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "启用两步验证失败"})
+		return
+	}
+	err = ctrl.twoFactor.Enable(user.ID, step, hashes)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "两步验证已启用"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "启用两步验证失败"})
+		return
+	}
+
+	response := gin.H{
+		"message":       "两步验证已启用，请妥善保存恢复码，之后无法再次查看",
+		"recoveryCodes": codes,
+	}
+	// 当前会话视为已通过两步验证，返回带有该状态的新访问令牌
+	if _, sessionID, _ := currentSession(c); sessionID != 0 {
+		now := time.Now()
+		if err := ctrl.sessions.MarkTwoFactor(sessionID, now); err != nil {
+			log.Printf("记录会话两步验证失败: %v", err)
+		} else if resp, err := ctrl.issuer.respond(*user, models.Session{ID: sessionID, TwoFactorAt: &now}, ""); err == nil {
+			response["token"], response["expiresIn"] = resp.Token, resp.ExpiresIn
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Disable 关闭两步验证，需要当前密码和验证码（或恢复码）。角色要求两步验证时不能关闭
+func (ctrl *TwoFactorController) Disable(c *gin.Context) {
+	user, ok := ctrl.currentUser(c)
+	if !ok {
+		return
+	}
+	var input DisableTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "两步验证未启用"})
+		return
+	}
+	if ctrl.authz.RequiresTwoFactor(user.Role) {
+		c.JSON(http.StatusConflict, gin.H{"error": "当前角色要求启用两步验证，不能关闭"})
+		return
+	}
+	if !checkPassword(c, user, input.CurrentPassword) {
+		return
+	}
+	if !ctrl.checkCode(c, user, input.Code, input.RecoveryCode) {
+		return
+	}
+
+	if err := ctrl.twoFactor.Disable(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "关闭两步验证失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+}
+
+// RegenerateRecoveryCodes 作废全部旧恢复码并生成新的一组，需要验证码
+func (ctrl *TwoFactorController) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := ctrl.currentUser(c)
+	if !ok {
+		return
+	}
+	var input TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "两步验证未启用"})
+		return
+	}
+	if !ctrl.checkCode(c, user, input.Code, "") {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成恢复码失败"})
+		return
+	}
+	if err := ctrl.twoFactor.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成恢复码失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "已生成新的恢复码，旧恢复码全部作废",
+		"recoveryCodes": codes,
+	})
+}
+
+// currentUser 读取当前登录的用户，失败时写入错误响应
+func (ctrl *TwoFactorController) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		return nil, false
+	}
+	user, err := ctrl.users.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return nil, false
+	}
+	return user, true
+}
+
+// checkCode 校验已登录用户的验证码或恢复码，失败时写入错误响应
+func (ctrl *TwoFactorController) checkCode(c *gin.Context, user *models.User, code, recoveryCode string) bool {
+	ok, err := ctrl.verify(user, code, recoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证失败"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "验证码错误"})
+		return false
+	}
+	return true
+}
+
+// verify 校验动态验证码或恢复码，两者都会在通过后作废，不能重复使用
+func (ctrl *TwoFactorController) verify(user *models.User, code, recoveryCode string) (bool, error) {
+	var err error
+	if recoveryCode != "" {
+		err = ctrl.twoFactor.UseRecoveryCode(user.ID, utils.HashRecoveryCode(recoveryCode))
+	} else if step, valid := utils.VerifyTOTP(user.TOTPSecret, code, time.Now()); valid {
+		err = ctrl.twoFactor.UseStep(user.ID, step)
+	} else {
+		return false, nil
+	}
+	if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrCodeReused) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// newRecoveryCodes 生成一组恢复码及其摘要
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, hash, err := utils.NewRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i], hashes[i] = code, hash
+	}
+	return codes, hashes, nil
+}
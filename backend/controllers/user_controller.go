@@ -2,24 +2,32 @@ package controllers
 
 import (
 	"net/http"
-	"xuan-ke-tong/config"
-	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetUser(c *gin.Context) {
-	var user models.User
-	if err := config.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+// UserController 处理用户公开信息的查询
+type UserController struct {
+	users    repository.UserRepository
+	ratings  repository.RatingRepository
+	comments repository.CommentRepository
+}
+
+func NewUserController(users repository.UserRepository, ratings repository.RatingRepository, comments repository.CommentRepository) *UserController {
+	return &UserController{users: users, ratings: ratings, comments: comments}
+}
+
+func (ctrl *UserController) GetUser(c *gin.Context) {
+	id, _ := paramID(c, "id")
+	user, err := ctrl.users.FindByID(id)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	var ratings []models.Rating
-	config.DB.Where("user_id = ?", c.Param("id")).Find(&ratings)
-
-	var comments []models.Comment
-	config.DB.Where("user_id = ?", c.Param("id")).Find(&comments)
+	ratings, _ := ctrl.ratings.ListByUser(user.ID)
+	comments, _ := ctrl.comments.ListByUser(user.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"user":     user,
@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileMailer 把邮件写成 .eml 文件，开发和测试时直接打开查看
+type fileMailer struct {
+	dir  string
+	from *netmail.Address
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg Message) error {
+	data, err := render(m.from, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return fmt.Errorf("创建 outbox 目录失败: %v", err)
+	}
+
+	// 文件名按时间排序，同一纳秒内的冲突交给 O_EXCL 发现
+	name := fmt.Sprintf("%s.eml", time.Now().UTC().Format("20060102T150405.000000000Z"))
+	f, err := os.OpenFile(filepath.Join(m.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
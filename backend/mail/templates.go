@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"fmt"
+	"time"
+)
+
+// PasswordReset 重置密码邮件
+func PasswordReset(to, nickname, username, link string, ttl time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "重置你的选课通密码",
+		Body: fmt.Sprintf(`%s，你好：
+
+我们收到了重置选课通账号 %s 密码的请求。请在 %s 内打开以下链接设置新密码：
+
+%s
+
+链接只能使用一次。如果这不是你本人的操作，请忽略这封邮件，你的密码不会被修改。
+`, nickname, username, formatTTL(ttl), link),
+	}
+}
+
+// EmailVerification 注册后验证邮箱的邮件
+func EmailVerification(to, nickname, username, link string, ttl time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "验证你的选课通邮箱",
+		Body: fmt.Sprintf(`%s，你好：
+
+欢迎注册选课通！你的账号是 %s。请在 %s 内打开以下链接验证邮箱，验证后即可发表课程评分和评论：
+
+%s
+
+如果你没有注册过选课通，请忽略这封邮件。
+`, nickname, username, formatTTL(ttl), link),
+	}
+}
+
+// EmailChange 确认新邮箱的邮件，发送到新地址
+func EmailChange(to, nickname, username, link string, ttl time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "确认你的选课通新邮箱",
+		Body: fmt.Sprintf(`%s，你好：
+
+选课通账号 %s 申请将邮箱修改为 %s。请在 %s 内打开以下链接确认：
+
+%s
+
+确认前账号仍使用原来的邮箱。如果这不是你本人的操作，请忽略这封邮件。
+`, nickname, username, to, formatTTL(ttl), link),
+	}
+}
+
+// EmailChanged 邮箱修改成功后发送到原地址的通知
+func EmailChanged(to, nickname, username, newEmail string) Message {
+	return Message{
+		To:      to,
+		Subject: "你的选课通邮箱已修改",
+		Body: fmt.Sprintf(`%s，你好：
+
+选课通账号 %s 的邮箱已修改为 %s，之后的通知和找回密码邮件将发送到新地址。
+
+如果这不是你本人的操作，请立即联系管理员。
+`, nickname, username, newEmail),
+	}
+}
+
+// Test 检查邮件配置用的测试邮件
+func Test(to string) Message {
+	return Message{
+		To:      to,
+		Subject: "选课通测试邮件",
+		Body:    fmt.Sprintf("这是一封测试邮件，发送于 %s。收到说明邮件配置正确。\n", time.Now().Format(time.DateTime)),
+	}
+}
+
+// formatTTL 将有效期写成邮件中易读的形式
+func formatTTL(ttl time.Duration) string {
+	if ttl%time.Hour == 0 {
+		return fmt.Sprintf("%d 小时", int(ttl.Hours()))
+	}
+	return fmt.Sprintf("%d 分钟", int(ttl.Minutes()))
+}
@@ -1,20 +1,23 @@
 package middleware
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
-	"xuan-ke-tong/config"
+	"time"
 	"xuan-ke-tong/models"
+	"xuan-ke-tong/rbac"
+	"xuan-ke-tong/repository"
 	"xuan-ke-tong/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware 校验登录令牌或个人访问令牌（以 models.AccessTokenPrefix 开头）
+func AuthMiddleware(tokens *utils.TokenManager, sessions repository.SessionRepository, accessTokens repository.AccessTokenRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		log.Printf("Received Authorization Header: %s", authHeader)
 
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -29,10 +32,25 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		log.Printf("Extracted Token: %s", tokenString)
 
-		// Validate token
-		claims, err := utils.ValidateToken(tokenString)
+		if strings.HasPrefix(tokenString, models.AccessTokenPrefix) {
+			token, err := authenticateAccessToken(accessTokens, tokenString)
+			if err != nil {
+				log.Printf("Access token validation error: %v", err)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+			if err := accessTokens.Touch(token.ID, c.ClientIP(), time.Now()); err != nil {
+				log.Printf("记录个人访问令牌使用失败: %v", err)
+			}
+			setAccessToken(c, token)
+			c.Next()
+			return
+		}
+
+		// Validate token and its session
+		claims, err := authenticate(tokens, sessions, tokenString)
 		if err != nil {
 			log.Printf("Token validation error: %v", err)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
@@ -40,16 +58,147 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Set user information in context
-		c.Set("userId", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("email", claims.Email)
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// authenticate 校验访问令牌，并确认其会话未被吊销
+func authenticate(tokens *utils.TokenManager, sessions repository.SessionRepository, tokenString string) (*utils.Claims, error) {
+	claims, err := tokens.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := sessions.IsActive(claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("session revoked or expired")
+	}
+	return claims, nil
+}
+
+// authenticateAccessToken 校验个人访问令牌，并载入其所属用户
+func authenticateAccessToken(accessTokens repository.AccessTokenRepository, tokenString string) (*models.AccessToken, error) {
+	token, err := accessTokens.FindActiveByHash(utils.HashOpaqueToken(tokenString), time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errors.New("access token revoked, expired or unknown")
+	}
+	return token, err
+}
+
+// setAccessToken 将令牌所属用户和权限范围写入上下文。角色取自数据库，
+// 修改用户角色后立即生效；没有会话，sessionId 为 0。只有由通过两步验证的会话创建、
+// 且创建后用户没有重新启用两步验证的令牌视为通过两步验证
+func setAccessToken(c *gin.Context, token *models.AccessToken) {
+	c.Set("userId", token.UserID)
+	c.Set("username", token.User.Username)
+	c.Set("email", token.User.Email)
+	c.Set("sessionId", uint(0))
+	c.Set("role", token.User.Role)
+	c.Set("twoFactor", accessTokenTwoFactor(token))
+	c.Set("accessToken", token)
+}
+
+// accessTokenTwoFactor 令牌是否由通过两步验证的会话创建。两步验证被关闭或重置后重新启用时，
+// 此前创建的令牌不再视为通过
+func accessTokenTwoFactor(token *models.AccessToken) bool {
+	enabledAt := token.User.TOTPEnabledAt
+	return token.TwoFactorAt != nil && enabledAt != nil && !token.TwoFactorAt.Before(*enabledAt)
+}
+
+// currentAccessToken 返回通过个人访问令牌认证时的令牌，登录会话返回 nil
+func currentAccessToken(c *gin.Context) *models.AccessToken {
+	token, _ := c.Get("accessToken")
+	t, _ := token.(*models.AccessToken)
+	return t
+}
+
+// scopeAllows 令牌的权限范围是否覆盖以 method 访问 permission 保护的接口
+func scopeAllows(token *models.AccessToken, permission, method string) bool {
+	readOnly := method == http.MethodGet || method == http.MethodHead
+	for _, name := range token.Scopes {
+		scope, ok := models.FindScope(name)
+		if ok && scope.Permission == permission && (readOnly || !scope.ReadOnly) {
+			return true
+		}
+	}
+	return false
+}
+
+// setClaims 将当前用户和会话写入上下文
+func setClaims(c *gin.Context, claims *utils.Claims) {
+	c.Set("userId", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("email", claims.Email)
+	c.Set("sessionId", claims.SessionID)
+	c.Set("role", claims.Role)
+	c.Set("twoFactor", claims.TwoFactor)
+}
+
+// RequirePermission 只允许角色拥有 permission 的用户继续，需放在 AuthMiddleware 之后。
+// 角色取自访问令牌，修改用户角色时会吊销其会话；角色要求两步验证时会话必须已通过两步验证；
+// 个人访问令牌还需包含对应的权限范围
+func RequirePermission(authz *rbac.Authorizer, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		if !authz.Can(role.(string), permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "permission": permission})
+			c.Abort()
+			return
+		}
+
+		if authz.RequiresTwoFactor(role.(string)) && !c.GetBool("twoFactor") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication required", "twoFactorRequired": true})
+			c.Abort()
+			return
+		}
+
+		if token := currentAccessToken(c); token != nil && !scopeAllows(token, permission, c.Request.Method) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient token scope", "permission": permission})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
 }
 
-func RequireAdminMiddleware() gin.HandlerFunc {
+// RequireScope 通过个人访问令牌访问时要求令牌包含 scope，登录会话不受限制。
+// 用于不需要额外权限、但也不应对所有令牌开放的接口，需放在 AuthMiddleware 之后
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := currentAccessToken(c); token != nil && !token.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient token scope", "scope": scope})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession 只接受登录会话，拒绝个人访问令牌。用于修改密码、邮箱、会话、
+// 令牌和账号绑定等账户安全相关的接口，需放在 AuthMiddleware 之后
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentAccessToken(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Personal access tokens are not accepted for this endpoint"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmailMiddleware 只允许邮箱已验证的用户继续，需放在 AuthMiddleware 之后
+func RequireVerifiedEmailMiddleware(users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId, exists := c.Get("userId")
 		if !exists {
@@ -58,15 +207,15 @@ func RequireAdminMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		var user models.User
-		if err := config.DB.First(&user, userId).Error; err != nil {
+		user, err := users.FindByID(userId.(uint))
+		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			c.Abort()
 			return
 		}
 
-		if user.Role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		if user.EmailVerifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email not verified"})
 			c.Abort()
 			return
 		}
@@ -75,7 +224,7 @@ func RequireAdminMiddleware() gin.HandlerFunc {
 	}
 }
 
-func OptionalAuthMiddleware() gin.HandlerFunc {
+func OptionalAuthMiddleware(tokens *utils.TokenManager, sessions repository.SessionRepository, accessTokens repository.AccessTokenRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 
@@ -85,12 +234,14 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString != authHeader {
+		if tokenString != authHeader && strings.HasPrefix(tokenString, models.AccessTokenPrefix) {
+			if token, err := authenticateAccessToken(accessTokens, tokenString); err == nil {
+				setAccessToken(c, token)
+			}
+		} else if tokenString != authHeader {
 			// Try to validate token, but don't fail if it's invalid
-			if claims, err := utils.ValidateToken(tokenString); err == nil {
-				c.Set("userId", claims.UserID)
-				c.Set("username", claims.Username)
-				c.Set("email", claims.Email)
+			if claims, err := authenticate(tokens, sessions, tokenString); err == nil {
+				setClaims(c, claims)
 			}
 		}
 
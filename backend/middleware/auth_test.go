@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+	"xuan-ke-tong/models"
+)
+
+func TestScopeAllows(t *testing.T) {
+	cases := []struct {
+		name       string
+		scopes     []string
+		permission string
+		method     string
+		want       bool
+	}{
+		{"只读范围可以读取", []string{models.ScopeReadCourses}, models.PermCourseEdit, http.MethodGet, true},
+		{"只读范围可以 HEAD", []string{models.ScopeReadCourses}, models.PermCourseEdit, http.MethodHead, true},
+		{"只读范围不能创建", []string{models.ScopeReadCourses}, models.PermCourseEdit, http.MethodPost, false},
+		{"只读范围不能修改", []string{models.ScopeReadCourses}, models.PermCourseEdit, http.MethodPut, false},
+		{"只读范围不能删除", []string{models.ScopeReadCourses}, models.PermCourseEdit, http.MethodDelete, false},
+		{"写入范围可以读取", []string{models.ScopeWriteCourses}, models.PermCourseEdit, http.MethodGet, true},
+		{"写入范围可以删除", []string{models.ScopeWriteCourses}, models.PermCourseEdit, http.MethodDelete, true},
+		{"多个范围中任一覆盖即可", []string{models.ScopeReadCourses, models.ScopeWriteCourses}, models.PermCourseEdit, http.MethodPost, true},
+		{"其他权限的范围不覆盖", []string{models.ScopeWriteCourses}, models.PermRatingModerate, http.MethodGet, false},
+		{"不需要额外权限的范围不覆盖", []string{models.ScopeReadProfile}, models.PermCourseEdit, http.MethodGet, false},
+		{"未知范围被忽略", []string{"admin:*"}, models.PermCourseEdit, http.MethodGet, false},
+		{"没有范围", nil, models.PermStatsView, http.MethodGet, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			token := &models.AccessToken{Scopes: tc.scopes}
+			if got := scopeAllows(token, tc.permission, tc.method); got != tc.want {
+				t.Fatalf("scopeAllows(%v, %s, %s) = %v，期望 %v", tc.scopes, tc.permission, tc.method, got, tc.want)
+			}
+		})
+	}
+}
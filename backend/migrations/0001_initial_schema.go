@@ -0,0 +1,129 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 初始结构：与原 main.ensureTablesExist 创建的表一致。
+// 对已有数据库使用 IF NOT EXISTS 接管现有表，并补齐旧版 ratings 表缺失的评分维度列
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "initial_schema",
+		Up:      initialSchemaUp,
+		Down:    initialSchemaDown,
+	})
+}
+
+var initialSchemaTables = []struct {
+	Name string
+	SQL  string
+}{
+	{"courses", `
+		CREATE TABLE IF NOT EXISTS courses (
+			id {{pk}},
+			name {{string}} NOT NULL,
+			description {{text}},
+			grade {{string}},
+			semester {{string}},
+			subject {{string}},
+			teacher {{string}},
+			credits {{int}},
+			image_url {{text}},
+			created_at {{timestamp}} DEFAULT {{now}},
+			updated_at {{timestamp}} DEFAULT {{now}}
+		)
+	`},
+	{"users", `
+		CREATE TABLE IF NOT EXISTS users (
+			id {{pk}},
+			username {{string}} NOT NULL UNIQUE,
+			password {{text}} NOT NULL,
+			email {{string}} NOT NULL UNIQUE,
+			nickname {{string}},
+			avatar {{text}},
+			role {{string}} DEFAULT 'user',
+			created_at {{timestamp}} DEFAULT {{now}},
+			updated_at {{timestamp}} DEFAULT {{now}}
+		)
+	`},
+	{"ratings", `
+		CREATE TABLE IF NOT EXISTS ratings (
+			id {{pk}},
+			user_id {{fk}},
+			course_id {{fk}},
+			score {{float}},
+			difficulty {{float}} DEFAULT 0,
+			usefulness {{float}} DEFAULT 0,
+			teaching {{float}} DEFAULT 0,
+			content {{text}},
+			created_at {{timestamp}} DEFAULT {{now}},
+			updated_at {{timestamp}} DEFAULT {{now}}
+		)
+	`},
+	{"comments", `
+		CREATE TABLE IF NOT EXISTS comments (
+			id {{pk}},
+			user_id {{fk}},
+			course_id {{fk}},
+			content {{text}},
+			created_at {{timestamp}} DEFAULT {{now}},
+			updated_at {{timestamp}} DEFAULT {{now}}
+		)
+	`},
+	{"evaluation_requests", `
+		CREATE TABLE IF NOT EXISTS evaluation_requests (
+			id {{pk}},
+			user_id {{fk}} NOT NULL,
+			course_id {{fk}} NOT NULL,
+			status VARCHAR(20) DEFAULT 'pending',
+			created_at {{timestamp}} DEFAULT {{now}},
+			updated_at {{timestamp}} DEFAULT {{now}},
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (course_id) REFERENCES courses(id)
+		)
+	`},
+}
+
+// 旧版数据库中可能缺失的列
+var initialSchemaLegacyColumns = []struct {
+	Table      string
+	Column     string
+	Definition string
+}{
+	{"ratings", "difficulty", "{{float}} DEFAULT 0"},
+	{"ratings", "usefulness", "{{float}} DEFAULT 0"},
+	{"ratings", "teaching", "{{float}} DEFAULT 0"},
+	{"ratings", "content", "{{text}}"},
+}
+
+func initialSchemaUp(tx *gorm.DB) error {
+	for _, table := range initialSchemaTables {
+		if err := tx.Exec(ddl(tx, table.SQL)).Error; err != nil {
+			return fmt.Errorf("failed to create %s table: %v", table.Name, err)
+		}
+	}
+
+	for _, col := range initialSchemaLegacyColumns {
+		if tx.Migrator().HasColumn(col.Table, col.Column) {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.Table, col.Column, col.Definition)
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to add %s.%s: %v", col.Table, col.Column, err)
+		}
+	}
+
+	return nil
+}
+
+func initialSchemaDown(tx *gorm.DB) error {
+	for i := len(initialSchemaTables) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(initialSchemaTables[i].Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
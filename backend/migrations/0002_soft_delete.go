@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 课程、用户、评分和评论改为软删除，删除的记录进入回收站
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "soft_delete",
+		Up:      softDeleteUp,
+		Down:    softDeleteDown,
+	})
+}
+
+var softDeleteTables = []string{"users", "courses", "ratings", "comments"}
+
+func softDeleteUp(tx *gorm.DB) error {
+	for _, table := range softDeleteTables {
+		if !tx.Migrator().HasColumn(table, "deleted_at") {
+			stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN deleted_at {{timestamp}} NULL", table)
+			if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+				return fmt.Errorf("failed to add %s.deleted_at: %v", table, err)
+			}
+		}
+		stmt := fmt.Sprintf("CREATE INDEX idx_%s_deleted_at ON %s (deleted_at)", table, table)
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to index %s.deleted_at: %v", table, err)
+		}
+	}
+	return nil
+}
+
+func softDeleteDown(tx *gorm.DB) error {
+	for _, table := range softDeleteTables {
+		if err := tx.Migrator().DropIndex(table, fmt.Sprintf("idx_%s_deleted_at", table)); err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN deleted_at", table)).Error; err != nil {
+			return fmt.Errorf("failed to drop %s.deleted_at: %v", table, err)
+		}
+	}
+	return nil
+}
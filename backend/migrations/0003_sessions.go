@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 登录会话：保存刷新令牌的摘要，支持刷新、注销和吊销
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "sessions",
+		Up:      sessionsUp,
+		Down:    sessionsDown,
+	})
+}
+
+func sessionsUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE sessions (
+			id {{pk}},
+			user_id {{fk}} NOT NULL,
+			token_hash {{string}} NOT NULL UNIQUE,
+			previous_token_hash {{string}},
+			user_agent {{text}},
+			ip {{string}},
+			created_at {{timestamp}} DEFAULT {{now}},
+			last_used_at {{timestamp}} NULL,
+			expires_at {{timestamp}} NULL,
+			revoked_at {{timestamp}} NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`,
+		"CREATE INDEX idx_sessions_user_id ON sessions (user_id)",
+		"CREATE INDEX idx_sessions_previous_token_hash ON sessions (previous_token_hash)",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create sessions table: %v", err)
+		}
+	}
+	return nil
+}
+
+func sessionsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("sessions")
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 通过邮件发送的一次性令牌，例如重置密码链接
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "user_tokens",
+		Up:      userTokensUp,
+		Down:    userTokensDown,
+	})
+}
+
+func userTokensUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE user_tokens (
+			id {{pk}},
+			user_id {{fk}} NOT NULL,
+			purpose {{string}} NOT NULL,
+			token_hash {{string}} NOT NULL UNIQUE,
+			expires_at {{timestamp}} NOT NULL,
+			used_at {{timestamp}} NULL,
+			created_at {{timestamp}} DEFAULT {{now}},
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`,
+		"CREATE INDEX idx_user_tokens_user_id ON user_tokens (user_id)",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create user_tokens table: %v", err)
+		}
+	}
+	return nil
+}
+
+func userTokensDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("user_tokens")
+}
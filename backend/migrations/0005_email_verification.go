@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 注册需要验证邮箱，并可通过邀请码限制注册
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "email_verification",
+		Up:      emailVerificationUp,
+		Down:    emailVerificationDown,
+	})
+}
+
+func emailVerificationUp(tx *gorm.DB) error {
+	stmts := []string{
+		"ALTER TABLE users ADD COLUMN email_verified_at {{timestamp}} NULL",
+		// 已有账号视为已验证，不影响老用户发表评价
+		"UPDATE users SET email_verified_at = created_at",
+		`CREATE TABLE invites (
+			id {{pk}},
+			code_hash {{string}} NOT NULL UNIQUE,
+			note {{text}},
+			max_uses {{int}} NOT NULL DEFAULT 0,
+			uses {{int}} NOT NULL DEFAULT 0,
+			expires_at {{timestamp}} NULL,
+			created_at {{timestamp}} DEFAULT {{now}}
+		)`,
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to add email verification: %v", err)
+		}
+	}
+	return nil
+}
+
+func emailVerificationDown(tx *gorm.DB) error {
+	if err := tx.Migrator().DropTable("invites"); err != nil {
+		return err
+	}
+	if err := tx.Exec("ALTER TABLE users DROP COLUMN email_verified_at").Error; err != nil {
+		return fmt.Errorf("failed to drop users.email_verified_at: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 一次性令牌记录发送到的邮箱，用于用户自助修改邮箱时确认新地址
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "email_change",
+		Up:      emailChangeUp,
+		Down:    emailChangeDown,
+	})
+}
+
+func emailChangeUp(tx *gorm.DB) error {
+	if err := tx.Exec(ddl(tx, "ALTER TABLE user_tokens ADD COLUMN email {{string}} NULL")).Error; err != nil {
+		return fmt.Errorf("failed to add user_tokens.email: %v", err)
+	}
+	return nil
+}
+
+func emailChangeDown(tx *gorm.DB) error {
+	if err := tx.Exec("ALTER TABLE user_tokens DROP COLUMN email").Error; err != nil {
+		return fmt.Errorf("failed to drop user_tokens.email: %v", err)
+	}
+	return nil
+}
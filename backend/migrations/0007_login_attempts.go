@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 登录失败计数、锁定状态和失败记录
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "login_attempts",
+		Up:      loginAttemptsUp,
+		Down:    loginAttemptsDown,
+	})
+}
+
+func loginAttemptsUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE login_throttles (
+			id {{pk}},
+			scope {{string}} NOT NULL,
+			value {{string}} NOT NULL,
+			failures {{int}} NOT NULL DEFAULT 0,
+			locked_until {{timestamp}} NULL,
+			last_failure_at {{timestamp}} NULL
+		)
+	`,
+		"CREATE UNIQUE INDEX idx_login_throttles_subject ON login_throttles (scope, value)",
+		`
+		CREATE TABLE login_events (
+			id {{pk}},
+			username {{string}},
+			ip {{string}},
+			user_agent {{text}},
+			reason {{string}},
+			created_at {{timestamp}} DEFAULT {{now}}
+		)
+	`,
+		"CREATE INDEX idx_login_events_username ON login_events (username)",
+		"CREATE INDEX idx_login_events_created_at ON login_events (created_at)",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create login attempt tables: %v", err)
+		}
+	}
+	return nil
+}
+
+func loginAttemptsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("login_events", "login_throttles")
+}
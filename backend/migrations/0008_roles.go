@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 角色和权限，用户的 role 列引用角色名
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "roles",
+		Up:      rolesUp,
+		Down:    rolesDown,
+	})
+}
+
+func rolesUp(tx *gorm.DB) error {
+	stmt := `
+		CREATE TABLE roles (
+			id {{pk}},
+			name {{string}} NOT NULL UNIQUE,
+			description {{text}},
+			permissions {{text}},
+			created_at {{timestamp}} DEFAULT {{now}},
+			updated_at {{timestamp}} DEFAULT {{now}}
+		)
+	`
+	if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+		return fmt.Errorf("failed to create roles table: %v", err)
+	}
+
+	// admin 的权限由代码保证，不在表中列出
+	roles := []struct{ name, description, permissions string }{
+		{"admin", "管理员，拥有全部权限", ""},
+		{"moderator", "审核员，管理评分和评论", "rating.moderate,stats.view"},
+		{"teacher", "教师，维护课程信息", "course.edit"},
+		{"user", "普通用户", ""},
+	}
+	for _, r := range roles {
+		err := tx.Exec("INSERT INTO roles (name, description, permissions) VALUES (?, ?, ?)",
+			r.name, r.description, r.permissions).Error
+		if err != nil {
+			return fmt.Errorf("failed to insert role %s: %v", r.name, err)
+		}
+	}
+	return nil
+}
+
+func rolesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("roles")
+}
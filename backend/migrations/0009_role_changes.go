@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 用户角色的变更记录
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "role_changes",
+		Up:      roleChangesUp,
+		Down:    roleChangesDown,
+	})
+}
+
+func roleChangesUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE role_changes (
+			id {{pk}},
+			user_id {{fk}} NOT NULL,
+			username {{string}},
+			actor_id {{fk}} NULL,
+			from_role {{string}},
+			to_role {{string}} NOT NULL,
+			source {{string}} NOT NULL,
+			ip {{string}},
+			created_at {{timestamp}} DEFAULT {{now}}
+		)
+	`,
+		"CREATE INDEX idx_role_changes_user_id ON role_changes (user_id)",
+		"CREATE INDEX idx_role_changes_created_at ON role_changes (created_at)",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create role_changes table: %v", err)
+		}
+	}
+	return nil
+}
+
+func roleChangesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("role_changes")
+}
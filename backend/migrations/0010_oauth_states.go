@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 进行中的 OAuth2 授权，取代进程内的 state 表，多实例部署时共享
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "oauth_states",
+		Up:      oauthStatesUp,
+		Down:    oauthStatesDown,
+	})
+}
+
+func oauthStatesUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE oauth_states (
+			id {{pk}},
+			state {{string}} NOT NULL UNIQUE,
+			code_verifier {{string}},
+			browser_hash {{string}},
+			expires_at {{timestamp}} NOT NULL,
+			created_at {{timestamp}} DEFAULT {{now}}
+		)
+	`,
+		"CREATE INDEX idx_oauth_states_expires_at ON oauth_states (expires_at)",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create oauth_states table: %v", err)
+		}
+	}
+	return nil
+}
+
+func oauthStatesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("oauth_states")
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 外部账号绑定，以及授权 state 中的提供方和待绑定用户
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "external_identities",
+		Up:      externalIdentitiesUp,
+		Down:    externalIdentitiesDown,
+	})
+}
+
+func externalIdentitiesUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE external_identities (
+			id {{pk}},
+			user_id {{fk}} NOT NULL,
+			provider {{string}} NOT NULL,
+			subject {{string}} NOT NULL,
+			email {{string}},
+			created_at {{timestamp}} DEFAULT {{now}},
+			last_login_at {{timestamp}} NULL
+		)
+	`,
+		"CREATE UNIQUE INDEX idx_external_identities_subject ON external_identities (provider, subject)",
+		"CREATE INDEX idx_external_identities_user_id ON external_identities (user_id)",
+		"ALTER TABLE oauth_states ADD COLUMN provider {{string}} NULL",
+		"ALTER TABLE oauth_states ADD COLUMN link_user_id {{fk}} NULL",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create external_identities: %v", err)
+		}
+	}
+	return nil
+}
+
+func externalIdentitiesDown(tx *gorm.DB) error {
+	for _, column := range []string{"link_user_id", "provider"} {
+		if err := tx.Exec("ALTER TABLE oauth_states DROP COLUMN " + column).Error; err != nil {
+			return fmt.Errorf("failed to drop oauth_states.%s: %v", column, err)
+		}
+	}
+	return tx.Migrator().DropTable("external_identities")
+}
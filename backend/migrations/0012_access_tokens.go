@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 个人访问令牌：只保存摘要，记录权限范围、有效期和最近使用情况
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "access_tokens",
+		Up:      accessTokensUp,
+		Down:    accessTokensDown,
+	})
+}
+
+func accessTokensUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE access_tokens (
+			id {{pk}},
+			user_id {{fk}} NOT NULL,
+			name {{string}} NOT NULL,
+			token_hash {{string}} NOT NULL UNIQUE,
+			hint {{string}},
+			scopes {{text}},
+			expires_at {{timestamp}} NOT NULL,
+			last_used_at {{timestamp}} NULL,
+			last_used_ip {{string}},
+			revoked_at {{timestamp}} NULL,
+			created_at {{timestamp}} DEFAULT {{now}},
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`,
+		"CREATE INDEX idx_access_tokens_user_id ON access_tokens (user_id)",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create access_tokens table: %v", err)
+		}
+	}
+	return nil
+}
+
+func accessTokensDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("access_tokens")
+}
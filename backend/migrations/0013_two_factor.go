@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 两步验证：用户的 TOTP 密钥和恢复码、会话的验证时间，以及角色是否要求两步验证
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "two_factor",
+		Up:      twoFactorUp,
+		Down:    twoFactorDown,
+	})
+}
+
+// twoFactorColumns 本迁移新增的列
+var twoFactorColumns = []struct{ table, column, definition string }{
+	{"users", "totp_secret", "{{string}} NULL"},
+	{"users", "totp_enabled_at", "{{timestamp}} NULL"},
+	{"users", "totp_last_step", "{{int}} NOT NULL DEFAULT 0"},
+	{"sessions", "two_factor_at", "{{timestamp}} NULL"},
+	{"roles", "two_factor_required_at", "{{timestamp}} NULL"},
+}
+
+func twoFactorUp(tx *gorm.DB) error {
+	for _, c := range twoFactorColumns {
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to add %s.%s: %v", c.table, c.column, err)
+		}
+	}
+
+	stmts := []string{`
+		CREATE TABLE recovery_codes (
+			id {{pk}},
+			user_id {{fk}} NOT NULL,
+			code_hash {{string}} NOT NULL UNIQUE,
+			used_at {{timestamp}} NULL,
+			created_at {{timestamp}} DEFAULT {{now}},
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`,
+		"CREATE INDEX idx_recovery_codes_user_id ON recovery_codes (user_id)",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create recovery_codes table: %v", err)
+		}
+	}
+	return nil
+}
+
+func twoFactorDown(tx *gorm.DB) error {
+	if err := tx.Migrator().DropTable("recovery_codes"); err != nil {
+		return err
+	}
+	for i := len(twoFactorColumns) - 1; i >= 0; i-- {
+		c := twoFactorColumns[i]
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", c.table, c.column)).Error; err != nil {
+			return fmt.Errorf("failed to drop %s.%s: %v", c.table, c.column, err)
+		}
+	}
+	return nil
+}
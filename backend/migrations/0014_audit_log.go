@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 管理和审核操作的审计日志，只追加不修改
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "audit_log",
+		Up:      auditLogUp,
+		Down:    auditLogDown,
+	})
+}
+
+func auditLogUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE audit_log (
+			id {{pk}},
+			actor_id {{fk}} NULL,
+			actor_name {{string}},
+			action {{string}} NOT NULL,
+			target_type {{string}} NOT NULL,
+			target_id {{fk}} NOT NULL,
+			changes {{text}},
+			ip {{string}},
+			request_id {{string}},
+			created_at {{timestamp}} DEFAULT {{now}}
+		)
+	`,
+		"CREATE INDEX idx_audit_log_actor_id ON audit_log (actor_id)",
+		"CREATE INDEX idx_audit_log_action ON audit_log (action)",
+		"CREATE INDEX idx_audit_log_target ON audit_log (target_type, target_id)",
+		"CREATE INDEX idx_audit_log_created_at ON audit_log (created_at)",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create audit_log table: %v", err)
+		}
+	}
+	return nil
+}
+
+func auditLogDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("audit_log")
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 课程的负责教师
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "course_owners",
+		Up:      courseOwnersUp,
+		Down:    courseOwnersDown,
+	})
+}
+
+func courseOwnersUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE course_owners (
+			id {{pk}},
+			course_id {{fk}} NOT NULL,
+			user_id {{fk}} NOT NULL,
+			assigned_by {{fk}} NULL,
+			created_at {{timestamp}} DEFAULT {{now}},
+			FOREIGN KEY (course_id) REFERENCES courses(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)
+	`,
+		"CREATE UNIQUE INDEX idx_course_owners_course_user ON course_owners (course_id, user_id)",
+		"CREATE INDEX idx_course_owners_user_id ON course_owners (user_id)",
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create course_owners table: %v", err)
+		}
+	}
+	return nil
+}
+
+func courseOwnersDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("course_owners")
+}
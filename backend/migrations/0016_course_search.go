@@ -0,0 +1,93 @@
+package migrations
+
+import (
+	"fmt"
+	"xuan-ke-tong/config"
+
+	"gorm.io/gorm"
+)
+
+// 课程全文索引：SQLite 上建立 FTS5 表，由触发器在课程和评分写入时同步。
+// 其他数据库不建索引，搜索退回 LIKE 匹配
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "course_search",
+		Up:      courseSearchUp,
+		Down:    courseSearchDown,
+	})
+}
+
+// CourseSearchRows 写入索引中每门未删除课程的一行，评论文本为其未删除评分内容的拼接。
+// search-reindex 重建索引时使用同一语句，修改索引的列时两处随之一致
+const CourseSearchRows = `
+	INSERT INTO course_search (rowid, name, description, teacher, reviews)
+	SELECT id, COALESCE(name, ''), COALESCE(description, ''), COALESCE(teacher, ''),
+		COALESCE((SELECT group_concat(content, ' ') FROM ratings
+			WHERE ratings.course_id = courses.id AND ratings.deleted_at IS NULL AND content <> ''), '')
+	FROM courses WHERE deleted_at IS NULL`
+
+// courseSearchRefresh 按课程 ID 重建一行索引；课程不存在或在回收站中时只删除
+const courseSearchRefresh = "DELETE FROM course_search WHERE rowid = %[1]s;" + CourseSearchRows + " AND id = %[1]s;"
+
+var courseSearchTriggers = []struct {
+	name  string
+	event string
+	body  string
+}{
+	{"course_search_courses_insert", "AFTER INSERT ON courses", fmt.Sprintf(courseSearchRefresh, "NEW.id")},
+	{"course_search_courses_update", "AFTER UPDATE OF name, description, teacher, deleted_at ON courses",
+		fmt.Sprintf(courseSearchRefresh, "OLD.id")},
+	{"course_search_courses_delete", "AFTER DELETE ON courses", "DELETE FROM course_search WHERE rowid = OLD.id;"},
+	{"course_search_ratings_insert", "AFTER INSERT ON ratings", fmt.Sprintf(courseSearchRefresh, "NEW.course_id")},
+	{"course_search_ratings_update", "AFTER UPDATE OF content, course_id, deleted_at ON ratings",
+		fmt.Sprintf(courseSearchRefresh, "OLD.course_id") + fmt.Sprintf(courseSearchRefresh, "NEW.course_id")},
+	{"course_search_ratings_delete", "AFTER DELETE ON ratings", fmt.Sprintf(courseSearchRefresh, "OLD.course_id")},
+}
+
+func courseSearchUp(tx *gorm.DB) error {
+	if tx.Dialector.Name() != config.DriverSQLite {
+		return nil
+	}
+
+	// trigram 分词按连续三个字符建立索引，中文无需分词也能匹配任意子串
+	stmt := "CREATE VIRTUAL TABLE course_search USING fts5(name, description, teacher, reviews, tokenize = 'trigram')"
+	if err := tx.Exec(stmt).Error; err != nil {
+		return fmt.Errorf("failed to create course_search index: %v", err)
+	}
+	if err := createCourseSearchTriggers(tx); err != nil {
+		return err
+	}
+	if err := tx.Exec(CourseSearchRows).Error; err != nil {
+		return fmt.Errorf("failed to fill course_search index: %v", err)
+	}
+	return nil
+}
+
+func createCourseSearchTriggers(tx *gorm.DB) error {
+	for _, t := range courseSearchTriggers {
+		if err := tx.Exec("CREATE TRIGGER " + t.name + " " + t.event + " BEGIN " + t.body + " END").Error; err != nil {
+			return fmt.Errorf("failed to create %s trigger: %v", t.name, err)
+		}
+	}
+	return nil
+}
+
+func dropCourseSearchTriggers(tx *gorm.DB) error {
+	for _, t := range courseSearchTriggers {
+		if err := tx.Exec("DROP TRIGGER IF EXISTS " + t.name).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func courseSearchDown(tx *gorm.DB) error {
+	if tx.Dialector.Name() != config.DriverSQLite {
+		return nil
+	}
+	if err := dropCourseSearchTriggers(tx); err != nil {
+		return err
+	}
+	return tx.Exec("DROP TABLE IF EXISTS course_search").Error
+}
@@ -0,0 +1,168 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+	"xuan-ke-tong/config"
+
+	"gorm.io/gorm"
+)
+
+// 评分、评论和外部账号绑定补上引用用户和课程的外键。
+// 添加约束前先彻底删除父记录不存在的行，与 integrity --repair 的处理一致；
+// SQLite 不能为已有表添加约束，按原结构加上外键重建表
+func init() {
+	register(Migration{
+		Version: 17,
+		Name:    "foreign_keys",
+		Up:      foreignKeysUp,
+		Down:    foreignKeysDown,
+	})
+}
+
+type foreignKey struct {
+	Column string
+	Parent string
+}
+
+func (fk foreignKey) constraint(table string) string {
+	return fmt.Sprintf("fk_%s_%s", table, fk.Column)
+}
+
+var foreignKeyTables = []struct {
+	Name    string
+	Columns []string // 列定义，重建时按列名复制数据
+	Keys    []foreignKey
+	Indexes []string
+}{
+	{
+		Name: "ratings",
+		Columns: []string{
+			"id {{pk}}",
+			"user_id {{fk}}",
+			"course_id {{fk}}",
+			"score {{float}}",
+			"difficulty {{float}} DEFAULT 0",
+			"usefulness {{float}} DEFAULT 0",
+			"teaching {{float}} DEFAULT 0",
+			"content {{text}}",
+			"created_at {{timestamp}} DEFAULT {{now}}",
+			"updated_at {{timestamp}} DEFAULT {{now}}",
+			"deleted_at {{timestamp}} NULL",
+		},
+		Keys:    []foreignKey{{"user_id", "users"}, {"course_id", "courses"}},
+		Indexes: []string{"CREATE INDEX idx_ratings_deleted_at ON ratings (deleted_at)"},
+	},
+	{
+		Name: "comments",
+		Columns: []string{
+			"id {{pk}}",
+			"user_id {{fk}}",
+			"course_id {{fk}}",
+			"content {{text}}",
+			"created_at {{timestamp}} DEFAULT {{now}}",
+			"updated_at {{timestamp}} DEFAULT {{now}}",
+			"deleted_at {{timestamp}} NULL",
+		},
+		Keys:    []foreignKey{{"user_id", "users"}, {"course_id", "courses"}},
+		Indexes: []string{"CREATE INDEX idx_comments_deleted_at ON comments (deleted_at)"},
+	},
+	{
+		Name: "external_identities",
+		Columns: []string{
+			"id {{pk}}",
+			"user_id {{fk}} NOT NULL",
+			"provider {{string}} NOT NULL",
+			"subject {{string}} NOT NULL",
+			"email {{string}}",
+			"created_at {{timestamp}} DEFAULT {{now}}",
+			"last_login_at {{timestamp}} NULL",
+		},
+		Keys: []foreignKey{{"user_id", "users"}},
+		Indexes: []string{
+			"CREATE UNIQUE INDEX idx_external_identities_subject ON external_identities (provider, subject)",
+			"CREATE INDEX idx_external_identities_user_id ON external_identities (user_id)",
+		},
+	},
+}
+
+func foreignKeysUp(tx *gorm.DB) error {
+	for _, table := range foreignKeyTables {
+		for _, fk := range table.Keys {
+			stmt := fmt.Sprintf("DELETE FROM %s WHERE %s IS NULL OR %s NOT IN (SELECT id FROM %s)",
+				table.Name, fk.Column, fk.Column, fk.Parent)
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to remove orphaned %s: %v", table.Name, err)
+			}
+		}
+	}
+
+	if tx.Dialector.Name() == config.DriverSQLite {
+		return rebuildForeignKeyTables(tx, true)
+	}
+	for _, table := range foreignKeyTables {
+		for _, fk := range table.Keys {
+			stmt := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(id)",
+				table.Name, fk.constraint(table.Name), fk.Column, fk.Parent)
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to add %s: %v", fk.constraint(table.Name), err)
+			}
+		}
+	}
+	return nil
+}
+
+func foreignKeysDown(tx *gorm.DB) error {
+	if tx.Dialector.Name() == config.DriverSQLite {
+		return rebuildForeignKeyTables(tx, false)
+	}
+	// MySQL 8.0.19 之前不支持 DROP CONSTRAINT
+	drop := "DROP CONSTRAINT"
+	if tx.Dialector.Name() == config.DriverMySQL {
+		drop = "DROP FOREIGN KEY"
+	}
+	for _, table := range foreignKeyTables {
+		for _, fk := range table.Keys {
+			stmt := fmt.Sprintf("ALTER TABLE %s %s %s", table.Name, drop, fk.constraint(table.Name))
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to drop %s: %v", fk.constraint(table.Name), err)
+			}
+		}
+	}
+	return nil
+}
+
+// rebuildForeignKeyTables 在 SQLite 上按 withKeys 重建带或不带外键的表。
+// 课程搜索的触发器引用 ratings，重建期间先删除，完成后重新创建
+func rebuildForeignKeyTables(tx *gorm.DB, withKeys bool) error {
+	if err := dropCourseSearchTriggers(tx); err != nil {
+		return err
+	}
+	for _, table := range foreignKeyTables {
+		defs := append([]string(nil), table.Columns...)
+		names := make([]string, len(table.Columns))
+		for i, column := range table.Columns {
+			names[i] = strings.Fields(column)[0]
+		}
+		if withKeys {
+			for _, fk := range table.Keys {
+				defs = append(defs, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(id)", fk.Column, fk.Parent))
+			}
+		}
+
+		columns := strings.Join(names, ", ")
+		stmts := []string{
+			fmt.Sprintf("CREATE TABLE %s_rebuild (%s)", table.Name, strings.Join(defs, ", ")),
+			fmt.Sprintf("INSERT INTO %s_rebuild (%s) SELECT %s FROM %s", table.Name, columns, columns, table.Name),
+			"DROP TABLE " + table.Name,
+			fmt.Sprintf("ALTER TABLE %s_rebuild RENAME TO %s", table.Name, table.Name),
+		}
+		stmts = append(stmts, table.Indexes...)
+		for _, stmt := range stmts {
+			if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+				return fmt.Errorf("failed to rebuild %s: %v", table.Name, err)
+			}
+		}
+	}
+	return createCourseSearchTriggers(tx)
+}
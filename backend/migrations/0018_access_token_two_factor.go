@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 个人访问令牌记录创建它的会话是否通过了两步验证。
+// 已有令牌视为未通过，角色要求两步验证的用户需重新创建令牌
+func init() {
+	register(Migration{
+		Version: 18,
+		Name:    "access_token_two_factor",
+		Up:      accessTokenTwoFactorUp,
+		Down:    accessTokenTwoFactorDown,
+	})
+}
+
+func accessTokenTwoFactorUp(tx *gorm.DB) error {
+	if err := tx.Exec(ddl(tx, "ALTER TABLE access_tokens ADD COLUMN two_factor_at {{timestamp}} NULL")).Error; err != nil {
+		return fmt.Errorf("failed to add access_tokens.two_factor_at: %v", err)
+	}
+	return nil
+}
+
+func accessTokenTwoFactorDown(tx *gorm.DB) error {
+	if err := tx.Exec("ALTER TABLE access_tokens DROP COLUMN two_factor_at").Error; err != nil {
+		return fmt.Errorf("failed to drop access_tokens.two_factor_at: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 会话保存全部已轮换掉的刷新令牌，而不只是上一个，任何一个被重放都会吊销会话
+func init() {
+	register(Migration{
+		Version: 19,
+		Name:    "used_refresh_tokens",
+		Up:      usedRefreshTokensUp,
+		Down:    usedRefreshTokensDown,
+	})
+}
+
+func usedRefreshTokensUp(tx *gorm.DB) error {
+	stmts := []string{`
+		CREATE TABLE used_refresh_tokens (
+			id {{pk}},
+			session_id {{fk}} NOT NULL,
+			token_hash {{string}} NOT NULL UNIQUE,
+			created_at {{timestamp}} DEFAULT {{now}},
+			FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+		)
+	`,
+		"CREATE INDEX idx_used_refresh_tokens_session_id ON used_refresh_tokens (session_id)",
+		`INSERT INTO used_refresh_tokens (session_id, token_hash)
+			SELECT id, previous_token_hash FROM sessions
+			WHERE previous_token_hash IS NOT NULL AND previous_token_hash <> ''`,
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to create used_refresh_tokens table: %v", err)
+		}
+	}
+
+	if err := tx.Migrator().DropIndex("sessions", "idx_sessions_previous_token_hash"); err != nil {
+		return err
+	}
+	if err := tx.Exec("ALTER TABLE sessions DROP COLUMN previous_token_hash").Error; err != nil {
+		return fmt.Errorf("failed to drop sessions.previous_token_hash: %v", err)
+	}
+	return nil
+}
+
+func usedRefreshTokensDown(tx *gorm.DB) error {
+	stmts := []string{
+		"ALTER TABLE sessions ADD COLUMN previous_token_hash {{string}}",
+		"CREATE INDEX idx_sessions_previous_token_hash ON sessions (previous_token_hash)",
+		// 只能保留每个会话最近轮换掉的一个
+		`UPDATE sessions SET previous_token_hash = (
+			SELECT token_hash FROM used_refresh_tokens u WHERE u.id = (
+				SELECT MAX(id) FROM used_refresh_tokens m WHERE m.session_id = sessions.id))`,
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(ddl(tx, stmt)).Error; err != nil {
+			return fmt.Errorf("failed to restore sessions.previous_token_hash: %v", err)
+		}
+	}
+	return tx.Migrator().DropTable("used_refresh_tokens")
+}
@@ -0,0 +1,115 @@
+package migrations_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"xuan-ke-tong/migrations"
+	"xuan-ke-tong/testdb"
+
+	"gorm.io/gorm"
+)
+
+// 每个迁移都能执行、回滚后再次执行，说明 Down 完整撤销了 Up
+func TestEachMigrationRoundTrips(t *testing.T) {
+	testdb.Each(t, func(t *testing.T, db *gorm.DB) {
+		for _, m := range migrations.All() {
+			if done, err := migrations.Up(db, m.Version); err != nil || len(done) != 1 {
+				t.Fatalf("执行 %04d_%s: done=%d err=%v", m.Version, m.Name, len(done), err)
+			}
+			if done, err := migrations.Down(db, 1); err != nil || len(done) != 1 || done[0].Version != m.Version {
+				t.Fatalf("回滚 %04d_%s: done=%v err=%v", m.Version, m.Name, done, err)
+			}
+			if _, err := migrations.Up(db, m.Version); err != nil {
+				t.Fatalf("回滚后再次执行 %04d_%s: %v", m.Version, m.Name, err)
+			}
+		}
+
+		if pending, err := migrations.Pending(db); err != nil || pending != 0 {
+			t.Fatalf("Pending = %d, %v，期望 0", pending, err)
+		}
+	})
+}
+
+// 全部回滚后只剩迁移记录表，再次执行得到同样的表
+func TestDownAllLeavesNoTables(t *testing.T) {
+	testdb.Each(t, func(t *testing.T, db *gorm.DB) {
+		all := migrations.All()
+		if _, err := migrations.Up(db, 0); err != nil {
+			t.Fatal(err)
+		}
+		migrated := tables(t, db)
+
+		done, err := migrations.Down(db, len(all))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(done) != len(all) {
+			t.Fatalf("回滚了 %d 个迁移，期望 %d", len(done), len(all))
+		}
+		if left := tables(t, db); !slices.Equal(left, []string{"schema_migrations"}) {
+			t.Fatalf("全部回滚后仍有表: %v", left)
+		}
+
+		if _, err := migrations.Up(db, 0); err != nil {
+			t.Fatal(err)
+		}
+		if again := tables(t, db); !slices.Equal(again, migrated) {
+			t.Fatalf("再次执行后的表 %v 与首次 %v 不同", again, migrated)
+		}
+	})
+}
+
+func tables(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+	names, err := db.Migrator().GetTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SQLite 的内部表和 FTS5 索引的影子表不单独比较
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return strings.HasPrefix(name, "sqlite_") || strings.HasPrefix(name, "course_search_")
+	})
+	slices.Sort(names)
+	return names
+}
+
+// 外键迁移清除已有的孤立评分，之后引用不存在用户或课程的评分无法写入
+func TestForeignKeysRejectOrphans(t *testing.T) {
+	testdb.Each(t, func(t *testing.T, db *gorm.DB) {
+		if _, err := migrations.Up(db, 16); err != nil {
+			t.Fatal(err)
+		}
+		stmts := []string{
+			"INSERT INTO users (id, username, password, email) VALUES (1, 'alice', 'x', 'alice@example.com')",
+			"INSERT INTO courses (id, name) VALUES (1, '高等数学')",
+			"INSERT INTO ratings (user_id, course_id, score) VALUES (1, 1, 5)",
+			"INSERT INTO ratings (user_id, course_id, score) VALUES (2, 1, 1)",
+			"INSERT INTO comments (user_id, course_id, content) VALUES (1, 2, '孤立的评论')",
+		}
+		for _, stmt := range stmts {
+			if err := db.Exec(stmt).Error; err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+
+		if _, err := migrations.Up(db, 0); err != nil {
+			t.Fatal(err)
+		}
+		var ratings, comments int64
+		db.Table("ratings").Count(&ratings)
+		db.Table("comments").Count(&comments)
+		if ratings != 1 || comments != 0 {
+			t.Fatalf("迁移后有 %d 条评分、%d 条评论，期望孤立记录被清除", ratings, comments)
+		}
+
+		for _, stmt := range []string{
+			"INSERT INTO ratings (user_id, course_id, score) VALUES (2, 1, 1)",
+			"INSERT INTO ratings (user_id, course_id, score) VALUES (1, 2, 1)",
+		} {
+			if err := db.Exec(stmt).Error; err == nil {
+				t.Fatalf("%s 应违反外键约束", stmt)
+			}
+		}
+	})
+}
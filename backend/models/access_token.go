@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// AccessTokenPrefix 个人访问令牌的前缀，用于与登录令牌区分，也便于扫描代码和日志中泄露的令牌
+const AccessTokenPrefix = "xkt_"
+
+// 个人访问令牌的权限范围
+const (
+	ScopeReadProfile  = "read:profile"
+	ScopeReadCourses  = "read:courses"
+	ScopeWriteCourses = "write:courses"
+	ScopeWriteReviews = "write:reviews"
+	ScopeModerate     = "moderate"
+	ScopeReadStats    = "read:stats"
+)
+
+// ScopeInfo 权限范围及其对应的权限。Permission 为空表示不需要额外权限；
+// ReadOnly 为 true 时只允许读取该权限保护的接口
+type ScopeInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Permission  string `json:"permission,omitempty"`
+	ReadOnly    bool   `json:"readOnly,omitempty"`
+}
+
+// Scopes 全部权限范围
+var Scopes = []ScopeInfo{
+	{ScopeReadProfile, "读取当前用户的资料和权限", "", false},
+	{ScopeReadCourses, "读取课程管理接口", PermCourseEdit, true},
+	{ScopeWriteCourses, "创建、修改和删除课程", PermCourseEdit, false},
+	{ScopeWriteReviews, "发表评分、评论和求评价请求", "", false},
+	{ScopeModerate, "查看和管理全部评分与评论", PermRatingModerate, false},
+	{ScopeReadStats, "查看统计数据", PermStatsView, true},
+}
+
+// FindScope 按名称查找权限范围
+func FindScope(name string) (ScopeInfo, bool) {
+	for _, s := range Scopes {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return ScopeInfo{}, false
+}
+
+// AccessToken 用户为脚本和集成创建的个人访问令牌，只保存摘要，明文仅在创建时返回一次。
+// 令牌以所属用户的身份访问接口，权限同时受用户角色和令牌的权限范围限制
+type AccessToken struct {
+	ID         uint          `gorm:"primaryKey" json:"id"`
+	UserID     uint          `gorm:"not null;index" json:"userId"`
+	User       User          `gorm:"foreignKey:UserID" json:"-"`
+	Name       string        `gorm:"not null" json:"name"`
+	TokenHash  string        `gorm:"unique;not null" json:"-"`
+	Hint       string        `json:"hint"`                    // 明文的末尾几位，便于用户辨认
+	Scopes     PermissionSet `gorm:"type:text" json:"scopes"` // 与角色权限相同，以逗号分隔保存
+	ExpiresAt  time.Time     `gorm:"not null" json:"expiresAt"`
+	LastUsedAt *time.Time    `json:"lastUsedAt"`
+	LastUsedIP string        `gorm:"column:last_used_ip" json:"lastUsedIp"`
+	RevokedAt  *time.Time    `json:"revokedAt,omitempty"`
+	// TwoFactorAt 创建令牌的会话已通过两步验证时为创建时间，角色要求两步验证时只接受这类令牌
+	TwoFactorAt *time.Time `json:"-"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+func (AccessToken) TableName() string {
+	return "access_tokens"
+}
+
+// HasScope 令牌是否包含权限范围
+func (t AccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
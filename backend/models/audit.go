@@ -0,0 +1,149 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// 审计日志记录的操作
+const (
+	AuditUserUpdate         = "user.update"
+	AuditUserDelete         = "user.delete"
+	AuditUserResetTwoFactor = "user.reset_two_factor"
+	AuditCourseCreate       = "course.create"
+	AuditCourseUpdate       = "course.update"
+	AuditCourseDelete       = "course.delete"
+	AuditCourseAddOwner     = "course.add_owner"
+	AuditCourseRemoveOwner  = "course.remove_owner"
+	AuditRatingDelete       = "rating.delete"
+	AuditCommentDelete      = "comment.delete"
+	AuditTrashRestore       = "trash.restore"
+	AuditRoleCreate         = "role.create"
+	AuditRoleUpdate         = "role.update"
+	AuditRoleDelete         = "role.delete"
+)
+
+// 审计日志的操作对象类型，与回收站的记录类型一致
+const (
+	AuditTargetUser    = "users"
+	AuditTargetCourse  = "courses"
+	AuditTargetRating  = "ratings"
+	AuditTargetComment = "comments"
+	AuditTargetRole    = "roles"
+)
+
+// AuditChange 一个字段修改前后的值，新建时 Before 为空，删除时 After 为空
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges 按字段名（JSON 名称）记录的修改，数据库中以 JSON 保存
+type AuditChanges map[string]AuditChange
+
+func (a AuditChanges) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(a)
+	return string(data), err
+}
+
+func (a *AuditChanges) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unsupported changes value %T", src)
+	}
+	*a = AuditChanges{}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, a)
+}
+
+// Fields 按字母顺序返回修改过的字段名
+func (a AuditChanges) Fields() []string {
+	fields := make([]string, 0, len(a))
+	for name := range a {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// 不计入修改的字段，每次保存都会变化
+var auditIgnoredFields = map[string]bool{"updatedAt": true}
+
+// DiffAudit 比较对象修改前后的 JSON 表示，返回值不同的字段。before 或 after 为 nil
+// 时分别表示新建和删除，记录全部字段。json:"-" 的字段（密码、密钥等）和关联对象不会出现在结果中
+func DiffAudit(before, after interface{}) (AuditChanges, error) {
+	from, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := AuditChanges{}
+	for name, value := range from {
+		if !auditIgnoredFields[name] && !reflect.DeepEqual(value, to[name]) {
+			changes[name] = AuditChange{Before: value, After: to[name]}
+		}
+	}
+	for name, value := range to {
+		if _, ok := from[name]; !ok && !auditIgnoredFields[name] {
+			changes[name] = AuditChange{After: value}
+		}
+	}
+	return changes, nil
+}
+
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if rv := reflect.ValueOf(v); !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range fields {
+		if _, nested := value.(map[string]interface{}); nested {
+			delete(fields, name)
+		}
+	}
+	return fields, nil
+}
+
+// AuditLog 一次管理或审核操作的记录，只追加不修改。操作者或对象被彻底删除后记录仍然保留
+type AuditLog struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	ActorID    *uint        `gorm:"index" json:"actorId"` // 操作者，服务器上的命令行操作为空
+	ActorName  string       `json:"actorName"`
+	Action     string       `gorm:"index" json:"action"`
+	TargetType string       `json:"targetType"`
+	TargetID   uint         `json:"targetId"`
+	Changes    AuditChanges `gorm:"type:text" json:"changes"`
+	IP         string       `json:"ip"`
+	RequestID  string       `json:"requestId"`
+	CreatedAt  time.Time    `gorm:"index" json:"createdAt"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
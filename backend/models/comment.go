@@ -1,15 +1,20 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Comment struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `json:"userId"`
-	User      User      `gorm:"foreignKey:UserID" json:"user"`
-	CourseID  uint      `json:"courseId"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	UserID    uint           `json:"userId"`
+	User      User           `gorm:"foreignKey:UserID" json:"user"`
+	CourseID  uint           `json:"courseId"`
+	Content   string         `json:"content"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt,omitzero"`
 }
 
 func (Comment) TableName() string {
@@ -1,21 +1,40 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Course struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Grade       string    `json:"grade"`
-	Semester    string    `json:"semester"`
-	Subject     string    `json:"subject"`
-	Teacher     string    `json:"teacher"`
-	Credits     int       `json:"credits"`
-	ImageURL    string    `json:"imageURL"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Grade       string         `json:"grade"`
+	Semester    string         `json:"semester"`
+	Subject     string         `json:"subject"`
+	Teacher     string         `json:"teacher"`
+	Credits     int            `json:"credits"`
+	ImageURL    string         `json:"imageURL"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deletedAt,omitzero"`
 }
 
 func (Course) TableName() string {
 	return "courses"
 }
+
+// CourseOwner 课程的负责教师，拥有 course.edit 的用户只能修改和删除自己负责的课程
+type CourseOwner struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CourseID   uint      `json:"courseId"`
+	UserID     uint      `json:"userId"`
+	User       User      `gorm:"foreignKey:UserID" json:"user"`
+	AssignedBy *uint     `json:"assignedBy"` // 指派者，自己创建课程时为空
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (CourseOwner) TableName() string {
+	return "course_owners"
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Invite 管理员发放的注册邀请码，只保存摘要
+type Invite struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CodeHash  string     `gorm:"unique;not null" json:"-"`
+	Note      string     `json:"note"`    // 发放对象等备注
+	MaxUses   int        `json:"maxUses"` // 可注册的账号数，0 表示不限
+	Uses      int        `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (Invite) TableName() string {
+	return "invites"
+}
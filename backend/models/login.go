@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// 登录失败的计数维度
+const (
+	LoginScopeUser = "user" // 按用户名计数，不区分大小写
+	LoginScopeIP   = "ip"
+)
+
+// 找回密码请求的计数维度，与登录失败共用计数表
+const (
+	LoginScopeResetEmail = "password_reset_email" // 按邮箱计数，不区分大小写
+	LoginScopeResetIP    = "password_reset_ip"
+)
+
+// 登录失败的原因
+const (
+	LoginFailureUnknownUser = "unknown_user"
+	LoginFailureBadPassword = "bad_password"
+	LoginFailureBadCode     = "bad_code" // 两步验证的动态验证码或恢复码错误
+	LoginFailureLocked      = "locked"   // 锁定期间的尝试，不计入失败次数
+)
+
+// LoginThrottle 一个账号或 IP 的连续登录失败次数（或找回密码的请求次数）和锁定状态
+type LoginThrottle struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Scope         string     `gorm:"not null;uniqueIndex:idx_login_throttles_subject" json:"scope"`
+	Value         string     `gorm:"not null;uniqueIndex:idx_login_throttles_subject" json:"value"` // 小写的用户名或 IP 地址
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LockedUntil   *time.Time `json:"lockedUntil"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
+
+// LoginEvent 一次失败的登录，供管理员排查撞库和暴力破解
+type LoginEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Username  string    `gorm:"index" json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
+
+func (LoginEvent) TableName() string {
+	return "login_events"
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// OAuthState 一次进行中的 OAuth2 授权，回调时取出并删除
+type OAuthState struct {
+	ID           uint      `gorm:"primaryKey"`
+	State        string    `gorm:"unique;not null"`
+	Provider     string    // 发起授权的提供方名称
+	LinkUserID   *uint     // 不为空时回调将外部账号绑定到该用户，而不是登录
+	CodeVerifier string    // PKCE code_verifier，换取令牌时提交
+	BrowserHash  string    // 发起授权的浏览器 Cookie 的摘要，回调必须来自同一浏览器
+	ExpiresAt    time.Time `gorm:"index"`
+	CreatedAt    time.Time
+}
+
+func (OAuthState) TableName() string {
+	return "oauth_states"
+}
+
+// ExternalIdentity 外部提供方的账号与本地用户的绑定，按 (provider, subject) 唯一
+type ExternalIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"userId"`
+	Provider    string     `gorm:"not null;uniqueIndex:idx_external_identities_subject" json:"provider"`
+	Subject     string     `gorm:"not null;uniqueIndex:idx_external_identities_subject" json:"subject"`
+	Email       string     `json:"email"` // 提供方最近一次返回的邮箱，仅供展示
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+}
+
+func (ExternalIdentity) TableName() string {
+	return "external_identities"
+}
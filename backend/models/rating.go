@@ -7,17 +7,18 @@ import (
 )
 
 type Rating struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	UserID     uint      `json:"userId"`
-	User       User      `gorm:"foreignKey:UserID" json:"user"`
-	CourseID   uint      `json:"courseId"`
-	Score      float64   `json:"score"`
-	Difficulty float64   `json:"difficulty" gorm:"default:0"`
-	Usefulness float64   `json:"usefulness" gorm:"default:0"`
-	Teaching   float64   `json:"teaching" gorm:"default:0"`
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	UserID     uint           `json:"userId"`
+	User       User           `gorm:"foreignKey:UserID" json:"user"`
+	CourseID   uint           `json:"courseId"`
+	Score      float64        `json:"score"`
+	Difficulty float64        `json:"difficulty" gorm:"default:0"`
+	Usefulness float64        `json:"usefulness" gorm:"default:0"`
+	Teaching   float64        `json:"teaching" gorm:"default:0"`
+	Content    string         `json:"content"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deletedAt,omitzero"`
 }
 
 func (Rating) TableName() string {
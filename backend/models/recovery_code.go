@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RecoveryCode 两步验证的恢复码，丢失验证器时代替动态验证码登录，只保存摘要，每个只能使用一次
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"unique;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 内置角色，不能删除；admin 拥有全部权限且不能修改
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleTeacher   = "teacher"
+	RoleUser      = "user"
+)
+
+// BuiltinRoles 内置角色的名称
+var BuiltinRoles = []string{RoleAdmin, RoleModerator, RoleTeacher, RoleUser}
+
+// IsBuiltinRole 角色是否为内置角色
+func IsBuiltinRole(name string) bool {
+	for _, r := range BuiltinRoles {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 权限
+const (
+	PermStatsView      = "stats.view"
+	PermCourseEdit     = "course.edit"
+	PermCourseManage   = "course.manage"
+	PermRatingModerate = "rating.moderate"
+	PermUserManage     = "user.manage"
+	PermTrashManage    = "trash.manage"
+	PermBackupManage   = "backup.manage"
+	PermRoleManage     = "role.manage"
+	PermAuditView      = "audit.view"
+)
+
+// PermissionInfo 权限及其说明
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions 全部权限
+var Permissions = []PermissionInfo{
+	{PermStatsView, "查看统计数据"},
+	{PermCourseEdit, "创建课程，修改和删除自己负责的课程"},
+	{PermCourseManage, "修改和删除全部课程，指派课程的负责教师"},
+	{PermRatingModerate, "查看和管理全部评分与评论"},
+	{PermUserManage, "管理用户、邀请码和登录锁定"},
+	{PermTrashManage, "查看和恢复回收站"},
+	{PermBackupManage, "生成和下载数据库快照"},
+	{PermRoleManage, "管理角色并为用户分配角色"},
+	{PermAuditView, "查看和导出审计日志"},
+}
+
+// IsPermission 是否为已定义的权限
+func IsPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionSet 角色拥有的权限，数据库中以逗号分隔保存
+type PermissionSet []string
+
+func (p PermissionSet) Value() (driver.Value, error) {
+	return strings.Join(p, ","), nil
+}
+
+func (p *PermissionSet) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("unsupported permissions value %T", src)
+	}
+	*p = PermissionSet{}
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*p = append(*p, name)
+		}
+	}
+	return nil
+}
+
+// Role 角色及其权限，用户的 Role 字段保存角色名
+type Role struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	Name        string        `gorm:"unique;not null" json:"name"`
+	Description string        `json:"description"`
+	Permissions PermissionSet `gorm:"type:text" json:"permissions"`
+	// TwoFactorRequiredAt 不为空时该角色的用户必须启用两步验证，未通过两步验证的会话不能使用任何权限
+	TwoFactorRequiredAt *time.Time `json:"twoFactorRequiredAt"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// 角色变更的来源
+const (
+	RoleChangeAdmin     = "admin"     // 管理员在后台修改
+	RoleChangeCLI       = "cli"       // 服务器上执行 create-admin
+	RoleChangeBootstrap = "bootstrap" // 使用一次性引导令牌创建首位管理员
+	RoleChangeOAuth     = "oauth"     // 首次第三方登录时按提供方给出的身份创建
+)
+
+// RoleChange 一次用户角色变更，只追加不修改。用户被彻底删除后记录仍然保留
+type RoleChange struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index" json:"userId"`
+	Username  string    `json:"username"`
+	ActorID   *uint     `json:"actorId"` // 操作者，CLI 和引导创建时为空
+	FromRole  string    `json:"fromRole"`
+	ToRole    string    `json:"toRole"`
+	Source    string    `json:"source"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
+
+func (RoleChange) TableName() string {
+	return "role_changes"
+}
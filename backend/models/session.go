@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Session 一次登录产生的会话，对应一个可轮换的刷新令牌。
+// 访问令牌携带会话 ID，会话被吊销后其访问令牌立即失效
+type Session struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"userId"`
+	TokenHash   string     `gorm:"unique;not null" json:"-"` // 当前刷新令牌的 SHA-256
+	UserAgent   string     `json:"userAgent"`
+	IP          string     `gorm:"column:ip" json:"ip"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastUsedAt  time.Time  `json:"lastUsedAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	TwoFactorAt *time.Time `json:"twoFactorAt,omitempty"` // 会话通过两步验证的时间
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// UsedRefreshToken 会话中已被轮换掉的刷新令牌，任何一个被再次使用都说明令牌已泄露
+type UsedRefreshToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	SessionID uint   `gorm:"not null;index"`
+	TokenHash string `gorm:"unique;not null"`
+	CreatedAt time.Time
+}
+
+func (UsedRefreshToken) TableName() string {
+	return "used_refresh_tokens"
+}
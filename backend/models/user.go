@@ -2,18 +2,28 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Username  string    `gorm:"unique;not null" json:"username"`
-	Password  string    `gorm:"not null" json:"-"`
-	Email     string    `gorm:"unique;not null" json:"email"`
-	Nickname  string    `json:"nickname"`
-	Avatar    string    `json:"avatar"`
-	Role      string    `gorm:"default:'user'" json:"role"` // user, admin
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Username string `gorm:"unique;not null" json:"username"`
+	Password string `gorm:"not null" json:"-"`
+	Email    string `gorm:"unique;not null" json:"email"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+	Role     string `gorm:"default:'user'" json:"role"` // user, admin
+	// EmailVerifiedAt 邮箱验证通过的时间，未验证的用户不能发表评分和评论
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	// TOTPSecret 两步验证密钥，TOTPEnabledAt 为空时是尚未确认的待启用密钥
+	TOTPSecret    string     `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at" json:"totpEnabledAt"`
+	// TOTPLastStep 最近一次通过校验的时间步，同一验证码不能使用两次
+	TOTPLastStep int64          `gorm:"column:totp_last_step" json:"-"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deletedAt,omitzero"`
 }
 
 // TableName overrides the table name used by User to `users`
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// 一次性令牌的用途
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeEmailChange       = "email_change"
+	TokenPurposeTwoFactorLogin    = "two_factor_login" // 密码正确后等待输入动态验证码，不通过邮件发送
+)
+
+// UserToken 通过邮件发给用户的一次性令牌，只保存摘要，使用后立即失效
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	Purpose   string     `gorm:"not null"`
+	TokenHash string     `gorm:"unique;not null"`
+	Email     string     // 令牌发送到的邮箱；修改邮箱时即为待确认的新地址
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // 已使用或被新令牌取代的时间
+	CreatedAt time.Time
+}
+
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
@@ -0,0 +1,54 @@
+package oauth
+
+import (
+	"errors"
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// DBStateStore 将 state 保存在数据库中，多个实例共享
+type DBStateStore struct {
+	db *gorm.DB
+}
+
+func NewDBStateStore(db *gorm.DB) *DBStateStore {
+	return &DBStateStore{db: db}
+}
+
+func (s *DBStateStore) Save(state *models.OAuthState) error {
+	return s.db.Create(state).Error
+}
+
+func (s *DBStateStore) Take(state string, now time.Time) (*models.OAuthState, error) {
+	var stored models.OAuthState
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state = ?", state).Take(&stored).Error; err != nil {
+			return err
+		}
+		// 以删除成功作为取出成功，并发的两次回调只有一次能拿到 state
+		result := tx.Delete(&models.OAuthState{}, stored.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStateNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !now.Before(stored.ExpiresAt) {
+		return nil, ErrStateExpired
+	}
+	return &stored, nil
+}
+
+func (s *DBStateStore) Sweep(now time.Time) (int64, error) {
+	result := s.db.Where("expires_at <= ?", now).Delete(&models.OAuthState{})
+	return result.RowsAffected, result.Error
+}
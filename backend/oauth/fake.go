@@ -0,0 +1,160 @@
+package oauth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"xuan-ke-tong/utils"
+)
+
+// FakeProvider 本地开发和联调用的 OpenID Connect 提供方。授权端点不需要登录，
+// 直接以 login_hint 指定的邮箱（默认 fake@example.com）同意授权；外部账号 ID 为 fake|邮箱。
+// 令牌端点会校验客户端凭据、redirect_uri 和 PKCE，与真实提供方的要求一致
+type FakeProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// UnverifiedEmails 这些邮箱在 UserInfo 中的 email_verified 为 false，用于联调未验证邮箱的处理
+	UnverifiedEmails []string
+
+	mu     sync.Mutex
+	codes  map[string]fakeGrant
+	tokens map[string]string // 访问令牌 -> 邮箱
+}
+
+type fakeGrant struct {
+	email         string
+	redirectURI   string
+	codeChallenge string
+}
+
+func NewFakeProvider(issuer, clientID, clientSecret string) *FakeProvider {
+	return &FakeProvider{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        make(map[string]fakeGrant),
+		tokens:       make(map[string]string),
+	}
+}
+
+func (f *FakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, oidcDiscovery{
+			Issuer:                f.Issuer,
+			AuthorizationEndpoint: f.Issuer + "/authorize",
+			TokenEndpoint:         f.Issuer + "/token",
+			UserInfoEndpoint:      f.Issuer + "/userinfo",
+		})
+	case "/authorize":
+		f.authorize(w, r)
+	case "/token":
+		f.token(w, r)
+	case "/userinfo":
+		f.userInfo(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *FakeProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if q.Get("client_id") != f.ClientID || err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid client_id or redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "response_type=code and S256 PKCE are required", http.StatusBadRequest)
+		return
+	}
+	email := q.Get("login_hint")
+	if email == "" {
+		email = "fake@example.com"
+	}
+
+	code, _, err := utils.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	f.mu.Lock()
+	f.codes[code] = fakeGrant{email: email, redirectURI: q.Get("redirect_uri"), codeChallenge: q.Get("code_challenge")}
+	f.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (f *FakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	if clientID, err := url.QueryUnescape(id); err == nil {
+		id = clientID
+	}
+	if clientSecret, err := url.QueryUnescape(secret); err == nil {
+		secret = clientSecret
+	}
+	if id != f.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(f.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	f.mu.Lock()
+	code := r.PostFormValue("code")
+	grant, ok := f.codes[code]
+	delete(f.codes, code)
+	f.mu.Unlock()
+
+	switch {
+	case r.PostFormValue("grant_type") != "authorization_code" || !ok:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case r.PostFormValue("redirect_uri") != grant.redirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "redirect_uri mismatch"})
+		return
+	case CodeChallenge(r.PostFormValue("code_verifier")) != grant.codeChallenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	token, _, err := utils.NewOpaqueToken()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	f.mu.Lock()
+	f.tokens[token] = grant.email
+	f.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": token, "token_type": "Bearer", "expires_in": 3600})
+}
+
+func (f *FakeProvider) userInfo(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	email, ok := f.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	f.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	name, _, _ := strings.Cut(email, "@")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sub":            "fake|" + email,
+		"email":          email,
+		"email_verified": !slices.Contains(f.UnverifiedEmails, email),
+		"name":           name,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
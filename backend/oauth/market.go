@@ -0,0 +1,137 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/models"
+)
+
+// 集市授权的用户信息结构
+type SSEUserInfo struct {
+	UserID    int    `json:"user_id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+	Intro     string `json:"intro"`
+	Identity  string `json:"identity"`
+}
+
+type SSEUserInfoResponse struct {
+	Code int         `json:"code"`
+	Msg  string      `json:"msg"`
+	Data SSEUserInfo `json:"data"`
+}
+
+// 集市授权的Token响应结构
+type SSETokenResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+		Scope       string `json:"scope"`
+	} `json:"data"`
+}
+
+// marketProvider 集市 OAuth2，授权地址由前端拼接
+type marketProvider struct {
+	cfg    config.OAuth2Config
+	client *http.Client
+}
+
+func (p *marketProvider) Name() string        { return config.MarketProviderName }
+func (p *marketProvider) DisplayName() string { return "集市" }
+func (p *marketProvider) TrustEmail() bool    { return p.cfg.MarketTrustEmail }
+
+func (p *marketProvider) AuthCodeURL(ctx context.Context, state, codeChallenge string) (string, error) {
+	return "", nil
+}
+
+func (p *marketProvider) Exchange(ctx context.Context, code, codeVerifier string) (*Identity, error) {
+	accessToken, err := p.accessToken(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("获取access_token出错: %v", err)
+	}
+	info, err := p.userInfo(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("获取用户信息失败: %v", err)
+	}
+
+	identity := &Identity{
+		Subject:       strconv.Itoa(info.UserID),
+		Email:         info.Email,
+		EmailVerified: info.Email != "", // 邮箱由集市提供，视为已验证
+		Name:          info.Name,
+		AvatarURL:     info.AvatarURL,
+	}
+	// 根据identity设置角色
+	if info.Identity == "teacher" {
+		identity.Role = models.RoleTeacher
+	}
+	return identity, nil
+}
+
+// accessToken 从SSE集市获取access_token，同时提交 PKCE code_verifier
+func (p *marketProvider) accessToken(ctx context.Context, code, codeVerifier string) (string, error) {
+	// 构建form-data请求参数
+	form := url.Values{
+		"code":          {code},
+		"app_id":        {p.cfg.AppID},
+		"app_secret":    {p.cfg.AppSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var tokenResp SSETokenResponse
+	if err := doJSON(p.client, req, &tokenResp); err != nil {
+		return "", err
+	}
+	// 检查响应状态
+	if tokenResp.Code != 200 {
+		return "", fmt.Errorf("%s", tokenResp.Msg)
+	}
+	return tokenResp.Data.AccessToken, nil
+}
+
+// userInfo 从SSE集市获取用户信息
+func (p *marketProvider) userInfo(ctx context.Context, accessToken string) (*SSEUserInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	var userInfoResp SSEUserInfoResponse
+	if err := doJSON(p.client, req, &userInfoResp); err != nil {
+		return nil, err
+	}
+	if userInfoResp.Code != 200 {
+		return nil, fmt.Errorf("%s", userInfoResp.Msg)
+	}
+	return &userInfoResp.Data, nil
+}
+
+// doJSON 发送请求并解析 JSON 响应
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("%s 返回 %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
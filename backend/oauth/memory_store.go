@@ -0,0 +1,52 @@
+package oauth
+
+import (
+	"sync"
+	"time"
+	"xuan-ke-tong/models"
+)
+
+// MemoryStateStore 将 state 保存在进程内存中，仅适用于单实例部署，重启后进行中的授权失效
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	states map[string]models.OAuthState
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{states: make(map[string]models.OAuthState)}
+}
+
+func (s *MemoryStateStore) Save(state *models.OAuthState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state.State] = *state
+	return nil
+}
+
+func (s *MemoryStateStore) Take(state string, now time.Time) (*models.OAuthState, error) {
+	s.mu.Lock()
+	stored, ok := s.states[state]
+	delete(s.states, state)
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrStateNotFound
+	}
+	if !now.Before(stored.ExpiresAt) {
+		return nil, ErrStateExpired
+	}
+	return &stored, nil
+}
+
+func (s *MemoryStateStore) Sweep(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for key, state := range s.states {
+		if !now.Before(state.ExpiresAt) {
+			delete(s.states, key)
+			n++
+		}
+	}
+	return n, nil
+}
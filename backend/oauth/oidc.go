@@ -0,0 +1,177 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"xuan-ke-tong/config"
+)
+
+// oidcDiscovery issuer/.well-known/openid-configuration 中用到的字段
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// oidcTokenResponse 令牌端点的响应，失败时带有 error 字段
+type oidcTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// oidcUserInfo UserInfo 端点返回的标准声明
+type oidcUserInfo struct {
+	Subject           string      `json:"sub"`
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"` // 部分提供方返回字符串 "true"
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+	Picture           string      `json:"picture"`
+}
+
+// oidcProvider 按 OpenID Connect 授权码流程登录。外部账号取自 UserInfo 端点：
+// 访问令牌由服务端凭 client_secret 直接从令牌端点换取，其返回的声明可信
+type oidcProvider struct {
+	cfg         config.OAuth2Provider
+	redirectURL string
+	client      *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery // 首次使用时读取，失败时下次重试
+}
+
+func newOIDCProvider(cfg config.OAuth2Provider, redirectURL string, client *http.Client) *oidcProvider {
+	return &oidcProvider{cfg: cfg, redirectURL: redirectURL, client: client}
+}
+
+func (p *oidcProvider) Name() string { return p.cfg.Name }
+
+func (p *oidcProvider) DisplayName() string {
+	if p.cfg.DisplayName != "" {
+		return p.cfg.DisplayName
+	}
+	return p.cfg.Name
+}
+
+func (p *oidcProvider) TrustEmail() bool { return p.cfg.TrustEmail }
+
+// discover 读取并缓存提供方的端点，要求返回的 issuer 与配置一致
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	issuer := strings.TrimRight(p.cfg.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var d oidcDiscovery
+	if err := doJSON(p.client, req, &d); err != nil {
+		return nil, fmt.Errorf("读取 %s 的 OpenID 配置失败: %v", p.cfg.Name, err)
+	}
+	if strings.TrimRight(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("%s 的 issuer 不匹配: %s", p.cfg.Name, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.UserInfoEndpoint == "" {
+		return nil, fmt.Errorf("%s 的 OpenID 配置缺少授权、令牌或 UserInfo 端点", p.cfg.Name)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *oidcProvider) scopes() string {
+	if len(p.cfg.Scopes) == 0 {
+		return "openid email profile"
+	}
+	return strings.Join(p.cfg.Scopes, " ")
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, codeChallenge string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {p.scopes()},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, codeVerifier string) (*Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	var token oidcTokenResponse
+	if err := doJSON(p.client, req, &token); err != nil {
+		return nil, fmt.Errorf("换取令牌失败: %v", err)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("换取令牌失败: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("换取令牌失败: 响应中没有 access_token")
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, d.UserInfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	var info oidcUserInfo
+	if err := doJSON(p.client, req, &info); err != nil {
+		return nil, fmt.Errorf("获取用户信息失败: %v", err)
+	}
+	if info.Subject == "" {
+		return nil, errors.New("获取用户信息失败: 缺少 sub")
+	}
+
+	name := info.Name
+	if name == "" {
+		name = info.PreferredUsername
+	}
+	return &Identity{
+		Subject:       info.Subject,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified == true || info.EmailVerified == "true",
+		Name:          name,
+		AvatarURL:     info.Picture,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"xuan-ke-tong/config"
+)
+
+// Identity 提供方返回的外部账号
+type Identity struct {
+	Subject       string // 提供方内唯一且不变的账号 ID
+	Email         string
+	EmailVerified bool
+	Name          string
+	AvatarURL     string
+	Role          string // 提供方建议的角色，仅在首次登录创建用户时使用；为空表示普通用户
+}
+
+// Provider 一个第三方登录提供方
+type Provider interface {
+	Name() string
+	DisplayName() string
+	// TrustEmail 首次登录时能否按已验证的邮箱绑定已有用户
+	TrustEmail() bool
+	// AuthCodeURL 返回带有 state 和 PKCE 参数的授权地址；返回空字符串时由前端自行拼接
+	AuthCodeURL(ctx context.Context, state, codeChallenge string) (string, error)
+	// Exchange 用授权码和 code_verifier 换取令牌并读取外部账号
+	Exchange(ctx context.Context, code, codeVerifier string) (*Identity, error)
+}
+
+// 请求提供方的超时时间
+const providerTimeout = 10 * time.Second
+
+// Registry 按名称管理已配置的提供方
+type Registry struct {
+	providers []Provider
+}
+
+// NewRegistry 注册集市 OAuth2（已配置时）和 oauth2.providers 中的 OpenID Connect 提供方
+func NewRegistry(cfg *config.Config) *Registry {
+	client := &http.Client{Timeout: providerTimeout}
+	r := &Registry{}
+	if cfg.OAuth2.Enabled() {
+		r.providers = append(r.providers, &marketProvider{cfg: cfg.OAuth2, client: client})
+	}
+	for _, pc := range cfg.OAuth2.Providers {
+		redirectURL := pc.RedirectURL
+		if redirectURL == "" {
+			redirectURL = strings.TrimRight(cfg.PublicURL, "/") + "/auth/oauth2/callback"
+		}
+		r.providers = append(r.providers, newOIDCProvider(pc, redirectURL, client))
+	}
+	return r
+}
+
+// Get 按名称查找提供方
+func (r *Registry) Get(name string) (Provider, bool) {
+	for _, p := range r.providers {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// List 按配置顺序返回全部提供方
+func (r *Registry) List() []Provider {
+	return r.providers
+}
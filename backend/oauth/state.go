@@ -0,0 +1,109 @@
+// Package oauth 保存进行中的 OAuth2 授权，并生成 PKCE 参数
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrStateNotFound state 不存在或已被使用
+	ErrStateNotFound = errors.New("oauth state not found")
+	// ErrStateExpired state 已过期
+	ErrStateExpired = errors.New("oauth state expired")
+)
+
+// StateStore 保存进行中的授权。每个 state 只能取出一次
+type StateStore interface {
+	Save(state *models.OAuthState) error
+	// Take 取出并删除 state，不存在时返回 ErrStateNotFound，过期时返回 ErrStateExpired
+	Take(state string, now time.Time) (*models.OAuthState, error)
+	// Sweep 删除 now 之前过期的 state，返回删除的数量
+	Sweep(now time.Time) (int64, error)
+}
+
+// NewStateStore 按配置创建 state 存储
+func NewStateStore(cfg config.OAuth2Config, db *gorm.DB) StateStore {
+	if cfg.StateStore == config.OAuth2StateStoreMemory {
+		return NewMemoryStateStore()
+	}
+	return NewDBStateStore(db)
+}
+
+// Authorization 新发起的授权
+type Authorization struct {
+	State         *models.OAuthState
+	CodeChallenge string // S256 方式的 PKCE code_challenge，拼接在授权地址中
+	Binding       string // 写入浏览器 Cookie 的随机值，只保存其摘要
+}
+
+// NewAuthorization 生成 state 和 PKCE 参数，有效期为 ttl。binding 为浏览器已有的绑定值，
+// 为空时生成新的；同一浏览器同时发起多次授权时共用一个绑定值
+func NewAuthorization(ttl time.Duration, binding string) (*Authorization, error) {
+	stateBytes := make([]byte, 16)
+	verifierBytes := make([]byte, 32)
+	for _, buf := range [][]byte{stateBytes, verifierBytes} {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+	}
+	if binding == "" {
+		var err error
+		if binding, _, err = utils.NewOpaqueToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	verifier := base64.RawURLEncoding.EncodeToString(verifierBytes)
+	return &Authorization{
+		State: &models.OAuthState{
+			State:        hex.EncodeToString(stateBytes),
+			CodeVerifier: verifier,
+			BrowserHash:  utils.HashOpaqueToken(binding),
+			ExpiresAt:    time.Now().Add(ttl),
+		},
+		CodeChallenge: CodeChallenge(verifier),
+		Binding:       binding,
+	}, nil
+}
+
+// BoundTo state 是否由持有 binding 的浏览器发起
+func BoundTo(state *models.OAuthState, binding string) bool {
+	return binding != "" &&
+		subtle.ConstantTimeCompare([]byte(state.BrowserHash), []byte(utils.HashOpaqueToken(binding))) == 1
+}
+
+// CodeChallenge 按 RFC 7636 的 S256 方式计算 code_challenge
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RunSweeper 每隔 interval 清理一次过期的 state，直到 ctx 结束
+func RunSweeper(ctx context.Context, store StateStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := store.Sweep(time.Now()); err != nil {
+			log.Printf("清理过期的 OAuth2 state 失败: %v", err)
+		}
+	}
+}
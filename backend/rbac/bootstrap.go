@@ -0,0 +1,52 @@
+package rbac
+
+import (
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"xuan-ke-tong/utils"
+)
+
+// ErrInvalidBootstrapToken 引导令牌错误、已使用或未签发
+var ErrInvalidBootstrapToken = errors.New("invalid bootstrap token")
+
+// Bootstrap 用于创建首位管理员的一次性令牌。令牌只保存在内存中，
+// 服务启动时发现没有管理员才会签发，使用一次或服务重启后失效
+type Bootstrap struct {
+	mu   sync.Mutex
+	hash string
+}
+
+// Issue 签发新的引导令牌，之前的令牌随之失效
+func (b *Bootstrap) Issue() (string, error) {
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	b.mu.Lock()
+	b.hash = hash
+	b.mu.Unlock()
+	return token, nil
+}
+
+// Pending 是否有尚未使用的引导令牌
+func (b *Bootstrap) Pending() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.hash != ""
+}
+
+// Redeem 校验令牌后调用 create 创建管理员，create 成功时令牌作废。
+// 并发的请求依次执行，同一令牌最多成功一次
+func (b *Bootstrap) Redeem(token string, create func() error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hash == "" || subtle.ConstantTimeCompare([]byte(b.hash), []byte(utils.HashOpaqueToken(token))) != 1 {
+		return ErrInvalidBootstrapToken
+	}
+	if err := create(); err != nil {
+		return err
+	}
+	b.hash = ""
+	return nil
+}
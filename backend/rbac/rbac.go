@@ -0,0 +1,98 @@
+// Package rbac 根据角色判断权限。角色和权限的对应关系缓存在内存中，
+// 权限检查不访问数据库
+package rbac
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+)
+
+// 缓存的有效期，多实例部署时其他实例修改的角色最迟在该时间后生效
+const cacheTTL = time.Minute
+
+// Authorizer 按角色判断权限
+type Authorizer struct {
+	roles repository.RoleRepository
+
+	mu        sync.RWMutex
+	perms     map[string]map[string]bool // 角色名 -> 权限集合
+	twoFactor map[string]bool            // 要求两步验证的角色
+	loadedAt  time.Time
+}
+
+func NewAuthorizer(roles repository.RoleRepository) *Authorizer {
+	return &Authorizer{roles: roles}
+}
+
+// Can 角色是否拥有权限。admin 拥有全部权限，未知角色没有任何权限
+func (a *Authorizer) Can(role, permission string) bool {
+	if role == models.RoleAdmin {
+		return true
+	}
+	perms, _ := a.load()
+	return perms[role][permission]
+}
+
+// RequiresTwoFactor 角色是否要求两步验证
+func (a *Authorizer) RequiresTwoFactor(role string) bool {
+	_, twoFactor := a.load()
+	return twoFactor[role]
+}
+
+// Permissions 返回角色拥有的全部权限，按名称排序
+func (a *Authorizer) Permissions(role string) []string {
+	perms := []string{}
+	for _, p := range models.Permissions {
+		if a.Can(role, p.Name) {
+			perms = append(perms, p.Name)
+		}
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// Invalidate 使缓存失效，修改角色后调用，下次检查时重新加载
+func (a *Authorizer) Invalidate() {
+	a.mu.Lock()
+	a.loadedAt = time.Time{}
+	a.mu.Unlock()
+}
+
+// load 返回缓存的权限表和要求两步验证的角色，过期时从数据库重新加载；加载失败时沿用旧数据
+func (a *Authorizer) load() (map[string]map[string]bool, map[string]bool) {
+	a.mu.RLock()
+	perms, twoFactor, fresh := a.perms, a.twoFactor, time.Since(a.loadedAt) < cacheTTL
+	a.mu.RUnlock()
+	if fresh {
+		return perms, twoFactor
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if time.Since(a.loadedAt) < cacheTTL {
+		return a.perms, a.twoFactor
+	}
+	roles, err := a.roles.List()
+	if err != nil {
+		log.Printf("加载角色权限失败: %v", err)
+		return a.perms, a.twoFactor
+	}
+	a.perms = make(map[string]map[string]bool, len(roles))
+	a.twoFactor = make(map[string]bool)
+	for _, role := range roles {
+		set := make(map[string]bool, len(role.Permissions))
+		for _, p := range role.Permissions {
+			set[p] = true
+		}
+		a.perms[role.Name] = set
+		if role.TwoFactorRequiredAt != nil {
+			a.twoFactor[role.Name] = true
+		}
+	}
+	a.loadedAt = time.Now()
+	return a.perms, a.twoFactor
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// accessTokenTouchInterval 最近使用时间的更新间隔，避免每个请求都写数据库
+const accessTokenTouchInterval = time.Minute
+
+// AccessTokenRepository 管理个人访问令牌
+type AccessTokenRepository interface {
+	Create(token *models.AccessToken) error
+	// FindActiveByHash 按摘要查找未吊销、未过期且所属用户未被删除的令牌，并载入用户
+	FindActiveByHash(hash string, now time.Time) (*models.AccessToken, error)
+	// ListByUser 按创建时间倒序列出用户未吊销的令牌，包括已过期的
+	ListByUser(userID uint) ([]models.AccessToken, error)
+	// CountActiveByUser 统计用户未吊销且未过期的令牌
+	CountActiveByUser(userID uint, now time.Time) (int64, error)
+	// Touch 记录令牌的最近使用时间和 IP，距上次记录不足一分钟时跳过
+	Touch(id uint, ip string, now time.Time) error
+	// Revoke 吊销属于 userID 的令牌，不存在或已吊销时返回 ErrNotFound
+	Revoke(userID, id uint) error
+}
+
+type gormAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewAccessTokenRepository(db *gorm.DB) AccessTokenRepository {
+	return &gormAccessTokenRepository{db: db}
+}
+
+func (r *gormAccessTokenRepository) Create(token *models.AccessToken) error {
+	return r.db.Omit("User").Create(token).Error
+}
+
+func (r *gormAccessTokenRepository) FindActiveByHash(hash string, now time.Time) (*models.AccessToken, error) {
+	var token models.AccessToken
+	err := r.db.Preload("User").
+		Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hash, now).
+		Take(&token).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	// 用户已进入回收站时不会载入
+	if token.User.ID == 0 {
+		return nil, ErrNotFound
+	}
+	return &token, nil
+}
+
+func (r *gormAccessTokenRepository) ListByUser(userID uint) ([]models.AccessToken, error) {
+	var tokens []models.AccessToken
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC, id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *gormAccessTokenRepository) CountActiveByUser(userID uint, now time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.AccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormAccessTokenRepository) Touch(id uint, ip string, now time.Time) error {
+	return r.db.Model(&models.AccessToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-accessTokenTouchInterval)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+}
+
+func (r *gormAccessTokenRepository) Revoke(userID, id uint) error {
+	result := r.db.Model(&models.AccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
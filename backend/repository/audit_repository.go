@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// 导出审计日志时每批读取的行数
+const auditExportBatch = 500
+
+// AuditFilter 审计日志的筛选和分页条件，零值表示不限
+type AuditFilter struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   uint
+	From       time.Time
+	To         time.Time
+	Page       int
+	PageSize   int
+}
+
+// AuditRepository 审计日志，只提供追加和查询，不能修改或删除
+type AuditRepository interface {
+	Record(entry *models.AuditLog) error
+	// List 按时间倒序分页列出审计日志
+	List(filter AuditFilter) ([]models.AuditLog, int64, error)
+	// Each 从最新的记录开始分批读取满足条件的全部审计日志（忽略分页），用于导出
+	Each(filter AuditFilter, fn func(entry models.AuditLog) error) error
+}
+
+type gormAuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &gormAuditRepository{db: db}
+}
+
+func (r *gormAuditRepository) Record(entry *models.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *gormAuditRepository) filtered(filter AuditFilter) *gorm.DB {
+	query := r.db.Model(&models.AuditLog{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	return query
+}
+
+func (r *gormAuditRepository) List(filter AuditFilter) ([]models.AuditLog, int64, error) {
+	query := r.filtered(filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.AuditLog
+	err := query.Order("created_at DESC, id DESC").Scopes(paginate(filter.Page, filter.PageSize)).Find(&entries).Error
+	return entries, total, err
+}
+
+func (r *gormAuditRepository) Each(filter AuditFilter, fn func(entry models.AuditLog) error) error {
+	// 按 id 倒序分批，导出期间新追加的记录不会打乱已读取的批次
+	var lastID uint
+	for {
+		query := r.filtered(filter)
+		if lastID != 0 {
+			query = query.Where("id < ?", lastID)
+		}
+		var batch []models.AuditLog
+		if err := query.Order("id DESC").Limit(auditExportBatch).Find(&batch).Error; err != nil {
+			return err
+		}
+		for _, entry := range batch {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if len(batch) < auditExportBatch {
+			return nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
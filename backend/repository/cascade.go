@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 删除课程或用户时的级联规则。
+// 软删除父记录：评分和评论随之进入回收站，仍在等待的求评价请求被关闭，
+// 用户的登录会话和个人访问令牌被吊销、未使用的一次性令牌作废；
+// 课程的负责教师、两步验证的恢复码和外部账号绑定保持不变；
+// 彻底删除父记录：引用它的全部子记录彻底删除
+type cascadeAction int
+
+const (
+	cascadeTrash  cascadeAction = iota // 与父记录使用同一删除时间进入回收站，恢复时一并恢复
+	cascadeClose                       // 关闭仍在等待的求评价请求，恢复父记录时不会重新打开
+	cascadeRevoke                      // 吊销登录会话或令牌，恢复用户后需重新登录或重新创建
+	cascadeKeep                        // 软删除时保持不变，恢复父记录后仍然有效；只随彻底删除一并删除
+)
+
+// cascadeParent 可被删除并拥有子记录的表
+type cascadeParent struct {
+	table  string
+	column string // 子表中引用该表的列
+	model  func() interface{}
+}
+
+// cascadeChild 引用课程和用户的子表
+type cascadeChild struct {
+	table  string
+	action cascadeAction
+	model  func() interface{}
+	parent string // 只引用该父表；为空时同时引用课程和用户
+	stamp  string // cascadeRevoke 记录作废时间的列
+}
+
+// references 子表是否引用 parent
+func (c cascadeChild) references(parent cascadeParent) bool {
+	return c.parent == "" || c.parent == parent.table
+}
+
+// softDeletes 父记录进入回收站时是否需要处理该子表
+func (c cascadeChild) softDeletes() bool {
+	return c.action != cascadeKeep
+}
+
+// pendingAction 返回父记录进入回收站后仍待处理的子记录条件，以及处理时要更新的列和值；
+// cascadeTrash 的条件由软删除作用域提供
+func (c cascadeChild) pendingAction(now interface{}) (string, string, interface{}) {
+	switch c.action {
+	case cascadeClose:
+		return "status = 'pending'", "status", "closed"
+	case cascadeRevoke:
+		return c.stamp + " IS NULL", c.stamp, now
+	default:
+		return "", "deleted_at", now
+	}
+}
+
+var cascadeParents = []cascadeParent{
+	{"courses", "course_id", func() interface{} { return &models.Course{} }},
+	{"users", "user_id", func() interface{} { return &models.User{} }},
+}
+
+var cascadeChildren = []cascadeChild{
+	{"ratings", cascadeTrash, func() interface{} { return &models.Rating{} }, "", ""},
+	{"comments", cascadeTrash, func() interface{} { return &models.Comment{} }, "", ""},
+	{"evaluation_requests", cascadeClose, func() interface{} { return &models.EvaluationRequest{} }, "", ""},
+	{"course_owners", cascadeKeep, func() interface{} { return &models.CourseOwner{} }, "", ""},
+	{"sessions", cascadeRevoke, func() interface{} { return &models.Session{} }, "users", "revoked_at"},
+	{"user_tokens", cascadeRevoke, func() interface{} { return &models.UserToken{} }, "users", "used_at"},
+	{"access_tokens", cascadeRevoke, func() interface{} { return &models.AccessToken{} }, "users", "revoked_at"},
+	{"recovery_codes", cascadeKeep, func() interface{} { return &models.RecoveryCode{} }, "users", ""},
+	{"external_identities", cascadeKeep, func() interface{} { return &models.ExternalIdentity{} }, "users", ""},
+}
+
+func findCascadeParent(table string) cascadeParent {
+	for _, p := range cascadeParents {
+		if p.table == table {
+			return p
+		}
+	}
+	panic("unknown cascade parent: " + table)
+}
+
+// softDeleteCascade 在一个事务中软删除课程或用户，并按级联规则处理子记录。
+// 所有行使用同一删除时间，恢复时据此找回一起删除的关联记录
+func softDeleteCascade(db *gorm.DB, table string, id uint) error {
+	parent := findCascadeParent(table)
+	// 截断到微秒，与各数据库时间列的精度一致
+	now := time.Now().Truncate(time.Microsecond)
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, child := range cascadeChildren {
+			if !child.references(parent) || !child.softDeletes() {
+				continue
+			}
+			query := tx.Model(child.model()).Where(parent.column+" = ?", id)
+			cond, column, value := child.pendingAction(now)
+			if cond != "" {
+				query = query.Where(cond)
+			}
+			if err := query.Update(column, value).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(parent.model()).Where("id = ?", id).Update("deleted_at", now).Error
+	})
+}
+
+// restoreCascade 恢复课程或用户，以及与其在同一时刻被删除的评分和评论
+func restoreCascade(db *gorm.DB, table string, id uint) error {
+	parent := findCascadeParent(table)
+	return db.Transaction(func(tx *gorm.DB) error {
+		var deletedAt sql.NullTime
+		err := tx.Unscoped().Model(parent.model()).Where("id = ?", id).Select("deleted_at").Row().Scan(&deletedAt)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !deletedAt.Valid) {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+
+		for _, child := range cascadeChildren {
+			if child.action != cascadeTrash {
+				continue
+			}
+			together := func() *gorm.DB {
+				return tx.Unscoped().Model(child.model()).
+					Where(parent.column+" = ? AND deleted_at = ?", id, deletedAt.Time)
+			}
+			// 另一方父记录仍在回收站的行保持删除，并改用其删除时间，随它一起恢复
+			for _, other := range cascadeParents {
+				if other.table == parent.table || !child.references(other) {
+					continue
+				}
+				err := together().Where(other.column+" IN (?)", trashed(tx, other)).
+					Update("deleted_at", inheritDeletedAt(other, child)).Error
+				if err != nil {
+					return err
+				}
+			}
+			if err := together().Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Model(parent.model()).Where("id = ?", id).Update("deleted_at", nil).Error
+	})
+}
+
+// trashed 选出父表中已在回收站的记录
+func trashed(tx *gorm.DB, parent cascadeParent) *gorm.DB {
+	return tx.Unscoped().Model(parent.model()).Select("id").Where("deleted_at IS NOT NULL")
+}
+
+// inheritDeletedAt 返回子记录所引用父记录的删除时间，用于让子记录随父记录一起恢复
+func inheritDeletedAt(parent cascadeParent, child cascadeChild) clause.Expr {
+	return gorm.Expr(fmt.Sprintf("(SELECT deleted_at FROM %s WHERE %s.id = %s.%s)",
+		parent.table, parent.table, child.table, parent.column))
+}
+
+// purgeCascade 彻底删除满足条件的父记录及引用它们的全部子记录，
+// 将各表删除的行数累加到 counts
+func purgeCascade(tx *gorm.DB, table string, counts map[string]int64, query string, args ...interface{}) error {
+	parent := findCascadeParent(table)
+	ids := tx.Unscoped().Model(parent.model()).Select("id").Where(query, args...)
+	for _, child := range cascadeChildren {
+		if !child.references(parent) {
+			continue
+		}
+		result := tx.Unscoped().Where(parent.column+" IN (?)", ids).Delete(child.model())
+		if result.Error != nil {
+			return result.Error
+		}
+		counts[child.table] += result.RowsAffected
+	}
+	// MySQL 不允许 DELETE 的子查询引用同一张表，父表直接按条件删除
+	result := tx.Unscoped().Where(query, args...).Delete(parent.model())
+	if result.Error != nil {
+		return result.Error
+	}
+	counts[parent.table] += result.RowsAffected
+	return nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+type CommentRepository interface {
+	Create(comment *models.Comment) error
+	FindByID(id uint) (*models.Comment, error)
+	// Delete 将评论移入回收站
+	Delete(comment *models.Comment) error
+	ListByCourse(courseID uint) ([]models.Comment, error)
+	ListByUser(userID uint) ([]models.Comment, error)
+	ListAll() ([]models.Comment, error)
+	Count() (int64, error)
+	CountCreatedBetween(from, to time.Time) (int64, error)
+	// CountDistinctUsers 返回发表过评论的用户数
+	CountDistinctUsers() (int64, error)
+}
+
+type gormCommentRepository struct {
+	db *gorm.DB
+}
+
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return &gormCommentRepository{db: db}
+}
+
+func (r *gormCommentRepository) Create(comment *models.Comment) error {
+	return r.db.Create(comment).Error
+}
+
+func (r *gormCommentRepository) FindByID(id uint) (*models.Comment, error) {
+	var comment models.Comment
+	if err := r.db.First(&comment, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &comment, nil
+}
+
+func (r *gormCommentRepository) Delete(comment *models.Comment) error {
+	return r.db.Delete(comment).Error
+}
+
+func (r *gormCommentRepository) ListByCourse(courseID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.Preload("User").Where("course_id = ?", courseID).Find(&comments).Error
+	return comments, err
+}
+
+func (r *gormCommentRepository) ListByUser(userID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.Where("user_id = ?", userID).Find(&comments).Error
+	return comments, err
+}
+
+func (r *gormCommentRepository) ListAll() ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.Find(&comments).Error
+	return comments, err
+}
+
+func (r *gormCommentRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Comment{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormCommentRepository) CountCreatedBetween(from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Comment{}).Where("created_at BETWEEN ? AND ?", from, to).Count(&count).Error
+	return count, err
+}
+
+func (r *gormCommentRepository) CountDistinctUsers() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Comment{}).Distinct("user_id").Count(&count).Error
+	return count, err
+}
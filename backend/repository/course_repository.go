@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrAlreadyOwner 用户已是该课程的负责教师
+var ErrAlreadyOwner = errors.New("already a course owner")
+
+// CourseFilter 课程列表的筛选条件
+type CourseFilter struct {
+	Grade    string
+	Semester string
+	Subject  string
+	OwnerID  uint // 不为 0 时只列出该用户负责的课程
+}
+
+type CourseRepository interface {
+	Create(course *models.Course) error
+	// CreateOwned 在一个事务中创建课程并将 ownerID 设为负责教师
+	CreateOwned(course *models.Course, ownerID uint) error
+	FindByID(id uint) (*models.Course, error)
+	// FindByIDs 按 ID 批量查找未删除的课程，不保证顺序，不存在的 ID 被忽略
+	FindByIDs(ids []uint) ([]models.Course, error)
+	FindByName(name string) (*models.Course, error)
+	List(filter CourseFilter) ([]models.Course, error)
+	Update(course *models.Course, fields map[string]interface{}) error
+	// Delete 将课程连同其评分和评论移入回收站，并关闭其等待中的求评价请求
+	Delete(course *models.Course) error
+	Count() (int64, error)
+	CountCreatedBetween(from, to time.Time) (int64, error)
+	// CountGroupedBy 按 subject、grade 或 semester 分组统计课程数
+	CountGroupedBy(column string) (map[string]int64, error)
+	// ListOwners 列出课程的负责教师
+	ListOwners(courseID uint) ([]models.CourseOwner, error)
+	// IsOwner 用户是否负责该课程
+	IsOwner(courseID, userID uint) (bool, error)
+	// AddOwner 指派负责教师，已指派时返回 ErrAlreadyOwner
+	AddOwner(owner *models.CourseOwner) error
+	// RemoveOwner 取消指派，返回被删除的记录；未指派时返回 ErrNotFound
+	RemoveOwner(courseID, userID uint) (*models.CourseOwner, error)
+}
+
+type gormCourseRepository struct {
+	db *gorm.DB
+}
+
+func NewCourseRepository(db *gorm.DB) CourseRepository {
+	return &gormCourseRepository{db: db}
+}
+
+func (r *gormCourseRepository) Create(course *models.Course) error {
+	return r.db.Create(course).Error
+}
+
+func (r *gormCourseRepository) CreateOwned(course *models.Course, ownerID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(course).Error; err != nil {
+			return err
+		}
+		return tx.Omit("User").Create(&models.CourseOwner{CourseID: course.ID, UserID: ownerID}).Error
+	})
+}
+
+func (r *gormCourseRepository) FindByID(id uint) (*models.Course, error) {
+	var course models.Course
+	if err := r.db.First(&course, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &course, nil
+}
+
+func (r *gormCourseRepository) FindByIDs(ids []uint) ([]models.Course, error) {
+	var courses []models.Course
+	err := r.db.Where("id IN ?", ids).Find(&courses).Error
+	return courses, err
+}
+
+func (r *gormCourseRepository) FindByName(name string) (*models.Course, error) {
+	var course models.Course
+	if err := r.db.Where("name = ?", name).First(&course).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &course, nil
+}
+
+func (r *gormCourseRepository) List(filter CourseFilter) ([]models.Course, error) {
+	query := r.db
+
+	if filter.Grade != "" {
+		query = query.Where("grade = ?", filter.Grade)
+	}
+	if filter.Semester != "" {
+		query = query.Where("semester = ?", filter.Semester)
+	}
+	if filter.Subject != "" {
+		query = query.Where("subject = ?", filter.Subject)
+	}
+	if filter.OwnerID != 0 {
+		query = query.Where("id IN (?)", r.db.Model(&models.CourseOwner{}).Select("course_id").Where("user_id = ?", filter.OwnerID))
+	}
+
+	var courses []models.Course
+	if err := query.Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	return courses, nil
+}
+
+func (r *gormCourseRepository) Update(course *models.Course, fields map[string]interface{}) error {
+	return r.db.Model(course).Updates(fields).Error
+}
+
+func (r *gormCourseRepository) Delete(course *models.Course) error {
+	return softDeleteCascade(r.db, TrashCourses, course.ID)
+}
+
+func (r *gormCourseRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Course{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormCourseRepository) CountCreatedBetween(from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Course{}).Where("created_at BETWEEN ? AND ?", from, to).Count(&count).Error
+	return count, err
+}
+
+func (r *gormCourseRepository) CountGroupedBy(column string) (map[string]int64, error) {
+	switch column {
+	case "subject", "grade", "semester":
+	default:
+		return nil, fmt.Errorf("不支持按 %s 分组", column)
+	}
+
+	rows, err := r.db.Model(&models.Course{}).
+		Select(column + ", COUNT(*) as count").
+		Group(column).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var count int64
+		if err := rows.Scan(&key, &count); err == nil {
+			result[key] = count
+		}
+	}
+	return result, rows.Err()
+}
+
+func (r *gormCourseRepository) ListOwners(courseID uint) ([]models.CourseOwner, error) {
+	var owners []models.CourseOwner
+	err := r.db.Preload("User").Where("course_id = ?", courseID).Order("id").Find(&owners).Error
+	return owners, err
+}
+
+func (r *gormCourseRepository) IsOwner(courseID, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.CourseOwner{}).Where("course_id = ? AND user_id = ?", courseID, userID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormCourseRepository) AddOwner(owner *models.CourseOwner) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&models.CourseOwner{}).
+			Where("course_id = ? AND user_id = ?", owner.CourseID, owner.UserID).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyOwner
+		}
+		return tx.Omit("User").Create(owner).Error
+	})
+}
+
+func (r *gormCourseRepository) RemoveOwner(courseID, userID uint) (*models.CourseOwner, error) {
+	var owner models.CourseOwner
+	if err := r.db.Where("course_id = ? AND user_id = ?", courseID, userID).Take(&owner).Error; err != nil {
+		return nil, translate(err)
+	}
+	if err := r.db.Delete(&owner).Error; err != nil {
+		return nil, err
+	}
+	return &owner, nil
+}
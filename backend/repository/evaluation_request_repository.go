@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+type EvaluationRequestRepository interface {
+	Create(request *models.EvaluationRequest) error
+	// FindByID 查询求评价请求，并加载关联的用户和课程
+	FindByID(id uint) (*models.EvaluationRequest, error)
+	FindByUserCourseStatus(userID, courseID uint, status string) (*models.EvaluationRequest, error)
+	// ListByStatus 分页查询指定状态的求评价请求，返回当页数据和总数
+	ListByStatus(status string, page, pageSize int) ([]models.EvaluationRequest, int64, error)
+}
+
+type gormEvaluationRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewEvaluationRequestRepository(db *gorm.DB) EvaluationRequestRepository {
+	return &gormEvaluationRequestRepository{db: db}
+}
+
+func (r *gormEvaluationRequestRepository) Create(request *models.EvaluationRequest) error {
+	return r.db.Create(request).Error
+}
+
+func (r *gormEvaluationRequestRepository) FindByID(id uint) (*models.EvaluationRequest, error) {
+	var request models.EvaluationRequest
+	if err := r.db.Preload("User").Preload("Course").First(&request, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &request, nil
+}
+
+func (r *gormEvaluationRequestRepository) FindByUserCourseStatus(userID, courseID uint, status string) (*models.EvaluationRequest, error) {
+	var request models.EvaluationRequest
+	err := r.db.Where("user_id = ? AND course_id = ? AND status = ?", userID, courseID, status).First(&request).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &request, nil
+}
+
+func (r *gormEvaluationRequestRepository) ListByStatus(status string, page, pageSize int) ([]models.EvaluationRequest, int64, error) {
+	var total int64
+	if err := r.db.Model(&models.EvaluationRequest{}).Where("status = ?", status).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var requests []models.EvaluationRequest
+	err := r.db.Preload("User").Preload("Course").
+		Where("status = ?", status).
+		Scopes(paginate(page, pageSize)).
+		Find(&requests).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return requests, total, nil
+}
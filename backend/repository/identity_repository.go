@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// IdentityRepository 管理外部账号与本地用户的绑定
+type IdentityRepository interface {
+	// FindBySubject 按提供方和外部账号 ID 查找绑定，不存在时返回 ErrNotFound
+	FindBySubject(provider, subject string) (*models.ExternalIdentity, error)
+	ListByUser(userID uint) ([]models.ExternalIdentity, error)
+	Create(identity *models.ExternalIdentity) error
+	// RecordLogin 记录一次登录，并更新提供方返回的邮箱
+	RecordLogin(identity *models.ExternalIdentity, email string, now time.Time) error
+	// Delete 解除属于 userID 的绑定，不存在时返回 ErrNotFound
+	Delete(userID, id uint) error
+}
+
+type gormIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &gormIdentityRepository{db: db}
+}
+
+func (r *gormIdentityRepository) FindBySubject(provider, subject string) (*models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+	if err := r.db.Where("provider = ? AND subject = ?", provider, subject).Take(&identity).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &identity, nil
+}
+
+func (r *gormIdentityRepository) ListByUser(userID uint) ([]models.ExternalIdentity, error) {
+	var identities []models.ExternalIdentity
+	err := r.db.Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+func (r *gormIdentityRepository) Create(identity *models.ExternalIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+func (r *gormIdentityRepository) RecordLogin(identity *models.ExternalIdentity, email string, now time.Time) error {
+	identity.Email, identity.LastLoginAt = email, &now
+	return r.db.Model(identity).Updates(map[string]interface{}{
+		"email":         email,
+		"last_login_at": now,
+	}).Error
+}
+
+func (r *gormIdentityRepository) Delete(userID, id uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.ExternalIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
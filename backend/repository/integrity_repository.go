@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IntegrityIssue 一组子表到父表的引用在完整性检查中的结果
+type IntegrityIssue struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Parent string `json:"parent"`
+	// Missing 引用的父记录不存在（含引用为空）的行数
+	Missing int64 `json:"missing"`
+	// Stale 父记录已在回收站，自身却未按级联规则处理的行数：
+	// 评分和评论未进入回收站、求评价请求仍在等待、登录会话或个人访问令牌未吊销、一次性令牌未作废
+	Stale int64 `json:"stale"`
+}
+
+// IntegrityRepository 检查并修复评分、评论、求评价请求、课程负责教师、登录会话、个人访问令牌、一次性令牌、恢复码和外部账号绑定的孤立记录
+type IntegrityRepository interface {
+	// Check 按级联规则统计每组引用的问题行数
+	Check() ([]IntegrityIssue, error)
+	// Repair 在一个事务中彻底删除父记录不存在的行，并对父记录在回收站中的行
+	// 补做级联处理，返回各组修复的行数
+	Repair() ([]IntegrityIssue, error)
+}
+
+type gormIntegrityRepository struct {
+	db *gorm.DB
+}
+
+func NewIntegrityRepository(db *gorm.DB) IntegrityRepository {
+	return &gormIntegrityRepository{db: db}
+}
+
+// missing 父表中（含回收站）不存在的引用
+func missing(tx *gorm.DB, parent cascadeParent) (string, *gorm.DB) {
+	return "(" + parent.column + " IS NULL OR " + parent.column + " NOT IN (?))",
+		tx.Unscoped().Model(parent.model()).Select("id")
+}
+
+// stale 未按级联规则处理的子记录；软删除作用域会排除已在回收站的评分和评论
+func stale(tx *gorm.DB, parent cascadeParent, child cascadeChild) *gorm.DB {
+	query := tx.Model(child.model()).Where(parent.column+" IN (?)", trashed(tx, parent))
+	if cond, _, _ := child.pendingAction(nil); cond != "" {
+		query = query.Where(cond)
+	}
+	return query
+}
+
+func (r *gormIntegrityRepository) Check() ([]IntegrityIssue, error) {
+	var issues []IntegrityIssue
+	for _, child := range cascadeChildren {
+		for _, parent := range cascadeParents {
+			if !child.references(parent) {
+				continue
+			}
+			issue := IntegrityIssue{Table: child.table, Column: parent.column, Parent: parent.table}
+
+			cond, ids := missing(r.db, parent)
+			if err := r.db.Unscoped().Model(child.model()).Where(cond, ids).Count(&issue.Missing).Error; err != nil {
+				return nil, fmt.Errorf("检查 %s.%s 失败: %v", child.table, parent.column, err)
+			}
+			if child.softDeletes() {
+				if err := stale(r.db, parent, child).Count(&issue.Stale).Error; err != nil {
+					return nil, fmt.Errorf("检查 %s.%s 失败: %v", child.table, parent.column, err)
+				}
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+func (r *gormIntegrityRepository) Repair() ([]IntegrityIssue, error) {
+	var issues []IntegrityIssue
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, child := range cascadeChildren {
+			for _, parent := range cascadeParents {
+				if !child.references(parent) {
+					continue
+				}
+				issue := IntegrityIssue{Table: child.table, Column: parent.column, Parent: parent.table}
+
+				cond, ids := missing(tx, parent)
+				result := tx.Unscoped().Where(cond, ids).Delete(child.model())
+				if result.Error != nil {
+					return fmt.Errorf("清除 %s.%s 孤立记录失败: %v", child.table, parent.column, result.Error)
+				}
+				issue.Missing = result.RowsAffected
+
+				if !child.softDeletes() {
+					issues = append(issues, issue)
+					continue
+				}
+
+				_, column, value := child.pendingAction(time.Now())
+				if child.action == cascadeTrash {
+					// 沿用父记录的删除时间，恢复父记录时这些行会一并恢复
+					value = inheritDeletedAt(parent, child)
+				}
+				result = stale(tx, parent, child).Update(column, value)
+				if result.Error != nil {
+					return fmt.Errorf("修复 %s.%s 失败: %v", child.table, parent.column, result.Error)
+				}
+				issue.Stale = result.RowsAffected
+
+				issues = append(issues, issue)
+			}
+		}
+		return nil
+	})
+	return issues, err
+}
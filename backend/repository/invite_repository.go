@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// InviteRepository 管理注册邀请码
+type InviteRepository interface {
+	Create(invite *models.Invite) error
+	// List 按创建时间倒序列出全部邀请码
+	List() ([]models.Invite, error)
+	// Delete 删除邀请码，已用它注册的账号不受影响
+	Delete(id uint) error
+	// RedeemFor 在一个事务中按摘要占用一次未过期且未用完的邀请码并创建用户，并发请求不会超出
+	// 次数上限，创建失败时邀请码不被占用；邀请码无效时返回 ErrNotFound
+	RedeemFor(hash string, user *models.User) error
+}
+
+type gormInviteRepository struct {
+	db *gorm.DB
+}
+
+func NewInviteRepository(db *gorm.DB) InviteRepository {
+	return &gormInviteRepository{db: db}
+}
+
+func (r *gormInviteRepository) Create(invite *models.Invite) error {
+	return r.db.Create(invite).Error
+}
+
+func (r *gormInviteRepository) List() ([]models.Invite, error) {
+	var invites []models.Invite
+	err := r.db.Order("created_at DESC, id DESC").Find(&invites).Error
+	return invites, err
+}
+
+func (r *gormInviteRepository) Delete(id uint) error {
+	result := r.db.Delete(&models.Invite{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormInviteRepository) RedeemFor(hash string, user *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Invite{}).
+			Where("code_hash = ? AND (max_uses = 0 OR uses < max_uses)", hash).
+			Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+			Update("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Create(user).Error
+	})
+}
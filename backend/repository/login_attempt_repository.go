@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginEventFilter 登录失败记录的筛选和分页条件
+type LoginEventFilter struct {
+	Username string
+	IP       string
+	Page     int
+	PageSize int
+}
+
+// LoginAttemptRepository 记录登录失败次数、锁定状态和失败事件
+type LoginAttemptRepository interface {
+	// FindLocked 返回 now 时仍处于锁定的计数中解锁时间最晚的一个，都未锁定时返回 ErrNotFound
+	FindLocked(now time.Time, subjects ...models.LoginThrottle) (*models.LoginThrottle, error)
+	// RecordFailure 累加一次失败并返回最新计数；距上次失败超过 window 时从 1 重新计数。
+	// lockFor 根据失败次数给出锁定时长，大于 0 时从 now 起锁定
+	RecordFailure(scope, value string, now time.Time, window time.Duration, lockFor func(failures int) time.Duration) (*models.LoginThrottle, error)
+	// Reset 清除计数和锁定，例如登录成功或重置密码后
+	Reset(scope, value string) error
+	// Delete 按 ID 清除计数和锁定，不存在时返回 ErrNotFound
+	Delete(id uint) error
+	// ListThrottles 按最近失败时间倒序列出 since 之后仍有失败的计数
+	ListThrottles(since time.Time) ([]models.LoginThrottle, error)
+	LogEvent(event *models.LoginEvent) error
+	// ListEvents 按时间倒序分页列出登录失败记录
+	ListEvents(filter LoginEventFilter) ([]models.LoginEvent, int64, error)
+	// Prune 删除 before 之前的失败记录，以及此后没有新失败且已解锁的计数
+	Prune(before, now time.Time) (int64, error)
+}
+
+type gormLoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &gormLoginAttemptRepository{db: db}
+}
+
+func (r *gormLoginAttemptRepository) FindLocked(now time.Time, subjects ...models.LoginThrottle) (*models.LoginThrottle, error) {
+	if len(subjects) == 0 {
+		return nil, ErrNotFound
+	}
+	cond := r.db.Where("scope = ? AND value = ?", subjects[0].Scope, subjects[0].Value)
+	for _, s := range subjects[1:] {
+		cond = cond.Or("scope = ? AND value = ?", s.Scope, s.Value)
+	}
+	var throttle models.LoginThrottle
+	err := r.db.Where("locked_until > ?", now).Where(cond).Order("locked_until DESC").Take(&throttle).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &throttle, nil
+}
+
+func (r *gormLoginAttemptRepository) RecordFailure(scope, value string, now time.Time, window time.Duration, lockFor func(failures int) time.Duration) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Scope: scope, Value: value, LastFailureAt: now}).Error
+		if err != nil {
+			return err
+		}
+
+		// 在数据库中累加，并发的失败请求不会互相覆盖
+		err = tx.Model(&models.LoginThrottle{}).
+			Where("scope = ? AND value = ?", scope, value).
+			Updates(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END", now.Add(-window)),
+				"last_failure_at": now,
+			}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("scope = ? AND value = ?", scope, value).Take(&throttle).Error; err != nil {
+			return err
+		}
+
+		if d := lockFor(throttle.Failures); d > 0 {
+			until := now.Add(d)
+			throttle.LockedUntil = &until
+			return tx.Model(&throttle).Update("locked_until", until).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *gormLoginAttemptRepository) Reset(scope, value string) error {
+	return r.db.Where("scope = ? AND value = ?", scope, value).Delete(&models.LoginThrottle{}).Error
+}
+
+func (r *gormLoginAttemptRepository) Delete(id uint) error {
+	result := r.db.Delete(&models.LoginThrottle{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormLoginAttemptRepository) ListThrottles(since time.Time) ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	err := r.db.Where("last_failure_at > ?", since).Order("last_failure_at DESC").Find(&throttles).Error
+	return throttles, err
+}
+
+func (r *gormLoginAttemptRepository) LogEvent(event *models.LoginEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *gormLoginAttemptRepository) ListEvents(filter LoginEventFilter) ([]models.LoginEvent, int64, error) {
+	query := r.db.Model(&models.LoginEvent{})
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []models.LoginEvent
+	err := query.Order("created_at DESC, id DESC").Scopes(paginate(filter.Page, filter.PageSize)).Find(&events).Error
+	return events, total, err
+}
+
+func (r *gormLoginAttemptRepository) Prune(before, now time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Delete(&models.LoginEvent{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	err := r.db.Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, now).
+		Delete(&models.LoginThrottle{}).Error
+	return result.RowsAffected, err
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+type RatingRepository interface {
+	Create(rating *models.Rating) error
+	FindByID(id uint) (*models.Rating, error)
+	// Delete 将评分移入回收站
+	Delete(rating *models.Rating) error
+	FindByUserAndCourse(userID, courseID uint) (*models.Rating, error)
+	ListByCourse(courseID uint) ([]models.Rating, error)
+	ListByUser(userID uint) ([]models.Rating, error)
+	ListAll() ([]models.Rating, error)
+	Count() (int64, error)
+	CountCreatedBetween(from, to time.Time) (int64, error)
+	SumScoreBetween(from, to time.Time) (float64, error)
+	AverageScore() (float64, error)
+	// CourseSummary 返回课程的平均评分和评分数量
+	CourseSummary(courseID uint) (float64, int64, error)
+	// ScoreDistribution 返回课程 1-5 星的评分分布
+	ScoreDistribution(courseID uint) (map[int]int, error)
+}
+
+type gormRatingRepository struct {
+	db *gorm.DB
+}
+
+func NewRatingRepository(db *gorm.DB) RatingRepository {
+	return &gormRatingRepository{db: db}
+}
+
+func (r *gormRatingRepository) Create(rating *models.Rating) error {
+	return r.db.Create(rating).Error
+}
+
+func (r *gormRatingRepository) FindByID(id uint) (*models.Rating, error) {
+	var rating models.Rating
+	if err := r.db.First(&rating, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &rating, nil
+}
+
+func (r *gormRatingRepository) Delete(rating *models.Rating) error {
+	return r.db.Delete(rating).Error
+}
+
+func (r *gormRatingRepository) FindByUserAndCourse(userID, courseID uint) (*models.Rating, error) {
+	var rating models.Rating
+	if err := r.db.Where("user_id = ? AND course_id = ?", userID, courseID).First(&rating).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &rating, nil
+}
+
+func (r *gormRatingRepository) ListByCourse(courseID uint) ([]models.Rating, error) {
+	var ratings []models.Rating
+	err := r.db.Preload("User").Where("course_id = ?", courseID).Find(&ratings).Error
+	return ratings, err
+}
+
+func (r *gormRatingRepository) ListByUser(userID uint) ([]models.Rating, error) {
+	var ratings []models.Rating
+	err := r.db.Where("user_id = ?", userID).Find(&ratings).Error
+	return ratings, err
+}
+
+func (r *gormRatingRepository) ListAll() ([]models.Rating, error) {
+	var ratings []models.Rating
+	err := r.db.Find(&ratings).Error
+	return ratings, err
+}
+
+func (r *gormRatingRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Rating{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormRatingRepository) CountCreatedBetween(from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Rating{}).Where("created_at BETWEEN ? AND ?", from, to).Count(&count).Error
+	return count, err
+}
+
+func (r *gormRatingRepository) SumScoreBetween(from, to time.Time) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.Rating{}).
+		Where("created_at BETWEEN ? AND ?", from, to).
+		Select("COALESCE(SUM(score), 0)").Row().Scan(&total)
+	return total, err
+}
+
+func (r *gormRatingRepository) AverageScore() (float64, error) {
+	var avg float64
+	err := r.db.Model(&models.Rating{}).Select("COALESCE(AVG(score), 0)").Row().Scan(&avg)
+	return avg, err
+}
+
+func (r *gormRatingRepository) CourseSummary(courseID uint) (float64, int64, error) {
+	var avg float64
+	var total int64
+	err := r.db.Model(&models.Rating{}).
+		Where("course_id = ?", courseID).
+		Select("COALESCE(AVG(score), 0) as avg_rating, COUNT(*) as total").
+		Row().
+		Scan(&avg, &total)
+	return avg, total, err
+}
+
+func (r *gormRatingRepository) ScoreDistribution(courseID uint) (map[int]int, error) {
+	distribution := make(map[int]int)
+	for i := 1; i <= 5; i++ {
+		var count int64
+		err := r.db.Model(&models.Rating{}).
+			Where("course_id = ? AND score >= ? AND score < ?", courseID, float64(i)-0.5, float64(i)+0.5).
+			Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+		distribution[i] = int(count)
+	}
+	return distribution, nil
+}
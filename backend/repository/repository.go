@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound 查询的记录不存在
+var ErrNotFound = errors.New("record not found")
+
+// Repositories 汇总所有数据访问接口，供处理器按需注入
+type Repositories struct {
+	Users              UserRepository
+	Courses            CourseRepository
+	Ratings            RatingRepository
+	Comments           CommentRepository
+	EvaluationRequests EvaluationRequestRepository
+	Stats              StatsRepository
+	Sessions           SessionRepository
+	UserTokens         UserTokenRepository
+	Invites            InviteRepository
+	LoginAttempts      LoginAttemptRepository
+	Roles              RoleRepository
+	Identities         IdentityRepository
+	AccessTokens       AccessTokenRepository
+	TwoFactor          TwoFactorRepository
+	Audit              AuditRepository
+	Search             SearchRepository
+	Trash              TrashRepository
+	Integrity          IntegrityRepository
+}
+
+// New 基于 GORM 连接创建全部仓储实现
+func New(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:              NewUserRepository(db),
+		Courses:            NewCourseRepository(db),
+		Ratings:            NewRatingRepository(db),
+		Comments:           NewCommentRepository(db),
+		EvaluationRequests: NewEvaluationRequestRepository(db),
+		Stats:              NewStatsRepository(db),
+		Sessions:           NewSessionRepository(db),
+		UserTokens:         NewUserTokenRepository(db),
+		Invites:            NewInviteRepository(db),
+		LoginAttempts:      NewLoginAttemptRepository(db),
+		Roles:              NewRoleRepository(db),
+		Identities:         NewIdentityRepository(db),
+		AccessTokens:       NewAccessTokenRepository(db),
+		TwoFactor:          NewTwoFactorRepository(db),
+		Audit:              NewAuditRepository(db),
+		Search:             NewSearchRepository(db),
+		Trash:              NewTrashRepository(db),
+		Integrity:          NewIntegrityRepository(db),
+	}
+}
+
+// translate 将 GORM 的错误转换为仓储层错误
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// paginate 返回分页查询的作用域，page 从 1 开始
+func paginate(page, pageSize int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if page < 1 {
+			page = 1
+		}
+		if pageSize < 1 {
+			return db
+		}
+		return db.Offset((page - 1) * pageSize).Limit(pageSize)
+	}
+}
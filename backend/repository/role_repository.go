@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"errors"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrLastAdmin 操作会使系统中没有管理员
+var ErrLastAdmin = errors.New("last admin")
+
+// ErrAdminExists 已有管理员，不能再使用引导令牌
+var ErrAdminExists = errors.New("admin already exists")
+
+// RoleChangeFilter 角色变更记录的筛选和分页条件
+type RoleChangeFilter struct {
+	UserID   uint
+	Page     int
+	PageSize int
+}
+
+type RoleRepository interface {
+	List() ([]models.Role, error)
+	FindByName(name string) (*models.Role, error)
+	Create(role *models.Role) error
+	Save(role *models.Role) error
+	// Delete 删除角色，仍有用户使用时由调用方检查
+	Delete(role *models.Role) error
+	// ChangeUserRole 在一个事务中将用户角色从 change.FromRole 改为 change.ToRole 并记录变更。
+	// 撤销最后一位管理员时返回 ErrLastAdmin，用户不存在或角色已被并发修改时返回 ErrNotFound
+	ChangeUserRole(change *models.RoleChange) error
+	// SaveUserWithRole 在同一事务中按 change 修改角色并保存 user 的其余字段，错误与 ChangeUserRole 相同
+	SaveUserWithRole(user *models.User, change *models.RoleChange) error
+	// CreateUser 以 user.Role 创建用户并记录变更，用于直接创建管理员等特权账号
+	CreateUser(user *models.User, change *models.RoleChange) error
+	// CreateBootstrapAdmin 在尚无管理员时创建管理员并记录变更，否则返回 ErrAdminExists
+	CreateBootstrapAdmin(user *models.User, change *models.RoleChange) error
+	// ListChanges 按时间倒序分页列出角色变更记录
+	ListChanges(filter RoleChangeFilter) ([]models.RoleChange, int64, error)
+}
+
+type gormRoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &gormRoleRepository{db: db}
+}
+
+func (r *gormRoleRepository) List() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Order("id").Find(&roles).Error
+	return roles, err
+}
+
+func (r *gormRoleRepository) FindByName(name string) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.Where("name = ?", name).Take(&role).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &role, nil
+}
+
+func (r *gormRoleRepository) Create(role *models.Role) error {
+	return r.db.Create(role).Error
+}
+
+func (r *gormRoleRepository) Save(role *models.Role) error {
+	return r.db.Save(role).Error
+}
+
+func (r *gormRoleRepository) Delete(role *models.Role) error {
+	return r.db.Delete(role).Error
+}
+
+func (r *gormRoleRepository) ChangeUserRole(change *models.RoleChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return changeUserRole(tx, change)
+	})
+}
+
+func (r *gormRoleRepository) SaveUserWithRole(user *models.User, change *models.RoleChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := changeUserRole(tx, change); err != nil {
+			return err
+		}
+		user.Role = change.ToRole
+		return tx.Save(user).Error
+	})
+}
+
+// changeUserRole 有条件地修改角色并记录变更
+func changeUserRole(tx *gorm.DB, change *models.RoleChange) error {
+	query := tx.Model(&models.User{}).Where("id = ? AND role = ?", change.UserID, change.FromRole)
+	demoting := change.FromRole == models.RoleAdmin && change.ToRole != models.RoleAdmin
+	if demoting {
+		// 条件与更新在同一语句中，并发撤销两位管理员时至少保留一位；
+		// MySQL 不允许 UPDATE 的子查询直接引用同一张表，多包一层派生表
+		query = query.Where("EXISTS (SELECT 1 FROM (SELECT id FROM users WHERE role = ? AND deleted_at IS NULL AND id <> ?) AS others)",
+			models.RoleAdmin, change.UserID)
+	}
+	result := query.Update("role", change.ToRole)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if demoting {
+			var count int64
+			if err := tx.Model(&models.User{}).Where("id = ? AND role = ?", change.UserID, change.FromRole).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrLastAdmin
+			}
+		}
+		return ErrNotFound
+	}
+	return tx.Create(change).Error
+}
+
+func (r *gormRoleRepository) CreateUser(user *models.User, change *models.RoleChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return createWithChange(tx, user, change)
+	})
+}
+
+func (r *gormRoleRepository) CreateBootstrapAdmin(user *models.User, change *models.RoleChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var admins int64
+		if err := tx.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if admins > 0 {
+			return ErrAdminExists
+		}
+		user.Role = models.RoleAdmin
+		return createWithChange(tx, user, change)
+	})
+}
+
+// createWithChange 创建用户并记录其初始角色
+func createWithChange(tx *gorm.DB, user *models.User, change *models.RoleChange) error {
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+	change.UserID, change.Username, change.ToRole = user.ID, user.Username, user.Role
+	return tx.Create(change).Error
+}
+
+func (r *gormRoleRepository) ListChanges(filter RoleChangeFilter) ([]models.RoleChange, int64, error) {
+	query := r.db.Model(&models.RoleChange{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var changes []models.RoleChange
+	err := query.Order("created_at DESC, id DESC").Scopes(paginate(filter.Page, filter.PageSize)).Find(&changes).Error
+	return changes, total, err
+}
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/migrations"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// 可搜索的字段，顺序与全文索引的列一致
+const (
+	SearchFieldName        = "name"
+	SearchFieldDescription = "description"
+	SearchFieldTeacher     = "teacher"
+	SearchFieldReviews     = "reviews"
+)
+
+// SearchFields 全部可搜索的字段
+var SearchFields = []string{SearchFieldName, SearchFieldDescription, SearchFieldTeacher, SearchFieldReviews}
+
+// searchFieldWeights 各字段命中时的权重，用于 bm25 和 LIKE 匹配的相关度
+var searchFieldWeights = map[string]float64{
+	SearchFieldName:        10,
+	SearchFieldDescription: 2,
+	SearchFieldTeacher:     5,
+	SearchFieldReviews:     1,
+}
+
+// 排序得分 = 相关度权重 × 相关度（除以本次结果中的最大值）+ 评分权重 × 归一化的贝叶斯平均评分。
+// 贝叶斯平均以 3 分、5 条评分为先验，评分很少的课程不会因一两个高分排到前面
+const (
+	searchRelevanceWeight = 0.7
+	searchRatingWeight    = 0.3
+	searchPriorScore      = 3.0
+	searchPriorCount      = 5
+)
+
+// trigram 索引只能匹配不少于 3 个字符的词，更短的词改用 LIKE 匹配
+const searchMinTrigram = 3
+
+// SearchQuery 搜索条件
+type SearchQuery struct {
+	Terms    []string // 搜索词，课程须匹配全部搜索词
+	Fields   []string // 搜索的字段，为空时搜索全部字段
+	Grade    string
+	Semester string
+	Subject  string
+	Page     int
+	PageSize int
+}
+
+// SearchResult 一门命中的课程及其排序依据
+type SearchResult struct {
+	CourseID      uint
+	Relevance     float64
+	AverageRating float64
+	TotalRatings  int64
+	Score         float64
+}
+
+// SearchRepository 课程全文搜索。SQLite 使用 FTS5 索引（由触发器随课程和评分的写入同步），
+// 其他数据库退回 LIKE 匹配
+type SearchRepository interface {
+	// Search 按排序得分倒序返回一页结果和命中总数
+	Search(query SearchQuery) ([]SearchResult, int64, error)
+	// ReviewTexts 按课程返回未删除评分的内容，用于生成摘要
+	ReviewTexts(courseIDs []uint) (map[uint][]string, error)
+	// Rebuild 重建全文索引并返回索引的课程数，非 SQLite 数据库没有索引，返回 0
+	Rebuild() (int64, error)
+}
+
+type gormSearchRepository struct {
+	db *gorm.DB
+}
+
+func NewSearchRepository(db *gorm.DB) SearchRepository {
+	return &gormSearchRepository{db: db}
+}
+
+func (r *gormSearchRepository) fullText() bool {
+	return r.db.Dialector.Name() == config.DriverSQLite
+}
+
+// hits 返回命中课程的子查询，列为 course_id 和 relevance，以及查询参数
+func (r *gormSearchRepository) hits(query SearchQuery) (string, []interface{}) {
+	fields := query.Fields
+	if len(fields) == 0 {
+		fields = SearchFields
+	}
+
+	from := "courses c"
+	column := func(field string) string { return "LOWER(c." + field + ")" }
+	if r.fullText() {
+		from = "course_search JOIN courses c ON c.id = course_search.rowid"
+		column = func(field string) string { return "course_search." + field }
+	}
+	// like 返回字段包含 pattern 的条件；没有索引时评论内容在 ratings 表中
+	like := func(field string) string {
+		if field == SearchFieldReviews && !r.fullText() {
+			return "EXISTS (SELECT 1 FROM ratings r WHERE r.course_id = c.id AND r.deleted_at IS NULL" +
+				" AND LOWER(r.content) LIKE ? ESCAPE '!')"
+		}
+		return column(field) + " LIKE ? ESCAPE '!'"
+	}
+
+	var matches, likes []string
+	for _, term := range query.Terms {
+		if r.fullText() && utf8.RuneCountInString(term) >= searchMinTrigram {
+			matches = append(matches, matchPhrase(term, fields, len(query.Fields) > 0))
+		} else {
+			likes = append(likes, likePattern(term))
+		}
+	}
+
+	conds := []string{"c.deleted_at IS NULL"}
+	var condArgs []interface{}
+	if len(matches) > 0 {
+		conds = append(conds, "course_search MATCH ?")
+		condArgs = append(condArgs, strings.Join(matches, " AND "))
+	}
+	for _, pattern := range likes {
+		var alternatives []string
+		for _, field := range fields {
+			alternatives = append(alternatives, like(field))
+			condArgs = append(condArgs, pattern)
+		}
+		conds = append(conds, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	for _, filter := range []struct{ column, value string }{
+		{"grade", query.Grade}, {"semester", query.Semester}, {"subject", query.Subject},
+	} {
+		if filter.value != "" {
+			conds = append(conds, "c."+filter.column+" = ?")
+			condArgs = append(condArgs, filter.value)
+		}
+	}
+
+	// 有全文匹配时用 bm25 计算相关度，否则按命中字段的权重累加
+	var relevance string
+	var relevanceArgs []interface{}
+	if len(matches) > 0 {
+		weights := make([]string, len(SearchFields))
+		for i, field := range SearchFields {
+			weights[i] = fmt.Sprint(searchFieldWeights[field])
+		}
+		relevance = "-bm25(course_search, " + strings.Join(weights, ", ") + ")"
+	} else {
+		var parts []string
+		for _, pattern := range likes {
+			for _, field := range fields {
+				// 权重写成小数，PostgreSQL 上相关度不会按整数相除
+				parts = append(parts, fmt.Sprintf("CASE WHEN %s THEN %.1f ELSE 0 END", like(field), searchFieldWeights[field]))
+				relevanceArgs = append(relevanceArgs, pattern)
+			}
+		}
+		relevance = "(" + strings.Join(parts, " + ") + ")"
+	}
+
+	sql := "SELECT c.id AS course_id, " + relevance + " AS relevance FROM " + from +
+		" WHERE " + strings.Join(conds, " AND ")
+	return sql, append(relevanceArgs, condArgs...)
+}
+
+// matchPhrase 将搜索词转为 FTS5 短语，restrict 为 true 时限定在 fields 中匹配
+func matchPhrase(term string, fields []string, restrict bool) string {
+	phrase := `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	if !restrict {
+		return phrase
+	}
+	return "{" + strings.Join(fields, " ") + "} : " + phrase
+}
+
+// likePattern 返回包含 term 的 LIKE 模式，以 ! 转义通配符
+func likePattern(term string) string {
+	escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.ToLower(term))
+	return "%" + escaped + "%"
+}
+
+func (r *gormSearchRepository) Search(query SearchQuery) ([]SearchResult, int64, error) {
+	hits, args := r.hits(query)
+
+	var total int64
+	if err := r.db.Raw("SELECT COUNT(*) FROM ("+hits+") hits", args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return []SearchResult{}, 0, nil
+	}
+
+	page, pageSize := query.Page, query.PageSize
+	if page < 1 {
+		page = 1
+	}
+	// SQLite 不能在展开后的子查询中计算 bm25，须先物化命中结果
+	materialized := ""
+	if r.fullText() {
+		materialized = "MATERIALIZED "
+	}
+	sql := `
+		WITH hits AS ` + materialized + `(` + hits + `),
+		ranked AS (
+			SELECT hits.course_id, hits.relevance,
+				COALESCE(AVG(r.score), 0) AS average_rating,
+				COUNT(r.id) AS total_ratings,
+				COALESCE(SUM(r.score), 0) AS score_sum
+			FROM hits LEFT JOIN ratings r ON r.course_id = hits.course_id AND r.deleted_at IS NULL
+			GROUP BY hits.course_id, hits.relevance
+		)
+		SELECT course_id, relevance, average_rating, total_ratings,
+			? * COALESCE(relevance / NULLIF(MAX(relevance) OVER (), 0), 0)
+			+ ? * ((score_sum + ?) / (total_ratings + ?) - 1) / 4 AS score
+		FROM ranked
+		ORDER BY score DESC, course_id DESC
+		LIMIT ? OFFSET ?`
+	args = append(args,
+		searchRelevanceWeight, searchRatingWeight, searchPriorScore*searchPriorCount, searchPriorCount,
+		pageSize, (page-1)*pageSize)
+
+	results := []SearchResult{}
+	err := r.db.Raw(sql, args...).Scan(&results).Error
+	return results, total, err
+}
+
+func (r *gormSearchRepository) ReviewTexts(courseIDs []uint) (map[uint][]string, error) {
+	var ratings []models.Rating
+	err := r.db.Select("course_id", "content").
+		Where("course_id IN ? AND content <> ''", courseIDs).
+		Order("id").Find(&ratings).Error
+	if err != nil {
+		return nil, err
+	}
+	texts := make(map[uint][]string, len(courseIDs))
+	for _, rating := range ratings {
+		texts[rating.CourseID] = append(texts[rating.CourseID], rating.Content)
+	}
+	return texts, nil
+}
+
+func (r *gormSearchRepository) Rebuild() (int64, error) {
+	if !r.fullText() {
+		return 0, nil
+	}
+	var count int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM course_search").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(migrations.CourseSearchRows).Error; err != nil {
+			return err
+		}
+		return tx.Raw("SELECT COUNT(*) FROM course_search").Scan(&count).Error
+	})
+	return count, err
+}
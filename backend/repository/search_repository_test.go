@@ -0,0 +1,175 @@
+package repository_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/testdb"
+
+	"gorm.io/gorm"
+)
+
+// search 以 terms 搜索，返回命中课程的 ID（按排序）
+func search(t *testing.T, db *gorm.DB, terms ...string) []uint {
+	t.Helper()
+	results, total, err := repository.NewSearchRepository(db).Search(repository.SearchQuery{Terms: terms, PageSize: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(total) != len(results) {
+		t.Fatalf("命中 %d 门课程，返回了 %d 条", total, len(results))
+	}
+	ids := make([]uint, len(results))
+	for i, result := range results {
+		ids[i] = result.CourseID
+	}
+	return ids
+}
+
+func TestSearchFollowsCourseChanges(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		repos := repository.New(db)
+		users := createUsers(t, db, 1)
+		course := createCourse(t, db, "线性代数")
+		rating := models.Rating{UserID: users[0].ID, CourseID: course.ID, Score: 5, Content: "板书非常清晰"}
+		if err := db.Omit("User").Create(&rating).Error; err != nil {
+			t.Fatal(err)
+		}
+		want := []uint{course.ID}
+
+		if got := search(t, db, "线性代数"); !slices.Equal(got, want) {
+			t.Fatalf("新建课程后搜索名称得到 %v", got)
+		}
+		if got := search(t, db, "板书非常"); !slices.Equal(got, want) {
+			t.Fatalf("搜索评价内容得到 %v", got)
+		}
+
+		if err := repos.Courses.Update(&course, map[string]interface{}{"name": "概率统计"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := search(t, db, "线性代数"); len(got) != 0 {
+			t.Fatalf("改名后仍能以旧名称搜到: %v", got)
+		}
+		if got := search(t, db, "概率统计"); !slices.Equal(got, want) {
+			t.Fatalf("改名后搜索新名称得到 %v", got)
+		}
+
+		// 课程进入回收站时评分随之删除，两者都搜不到
+		if err := repos.Courses.Delete(&course); err != nil {
+			t.Fatal(err)
+		}
+		if got := search(t, db, "概率统计"); len(got) != 0 {
+			t.Fatalf("删除后仍能搜到课程: %v", got)
+		}
+		if got := search(t, db, "板书非常"); len(got) != 0 {
+			t.Fatalf("删除后仍能搜到评价: %v", got)
+		}
+
+		if err := repos.Trash.Restore(repository.TrashCourses, course.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got := search(t, db, "概率统计"); !slices.Equal(got, want) {
+			t.Fatalf("恢复后搜索名称得到 %v", got)
+		}
+		if got := search(t, db, "板书非常"); !slices.Equal(got, want) {
+			t.Fatalf("恢复后搜索评价内容得到 %v", got)
+		}
+	})
+}
+
+func TestSearchRanking(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		users := createUsers(t, db, 10)
+		now := time.Now()
+
+		// 名称命中的权重高于简介命中
+		inName := createCourse(t, db, "微积分入门")
+		inDescription := createCourse(t, db, "数学基础")
+		if err := db.Model(&inDescription).Update("description", "包含微积分入门内容").Error; err != nil {
+			t.Fatal(err)
+		}
+		if got := search(t, db, "微积分入门"); !slices.Equal(got, []uint{inName.ID, inDescription.ID}) {
+			t.Fatalf("排序为 %v，期望名称命中的 %d 在前", got, inName.ID)
+		}
+
+		// 相关度相同时按贝叶斯平均评分排序：一条 5 分为 (5 + 15) / 6 ≈ 3.33，
+		// 十条 4 分为 (40 + 15) / 15 ≈ 3.67，评分多的课程在前
+		single := createCourse(t, db, "大学物理甲")
+		createRating(t, db, users[0], single, 5, now)
+		many := createCourse(t, db, "大学物理乙")
+		for _, user := range users {
+			createRating(t, db, user, many, 4, now)
+		}
+		if got := search(t, db, "大学物理"); !slices.Equal(got, []uint{many.ID, single.ID}) {
+			t.Fatalf("排序为 %v，期望评分多的 %d 在前", got, many.ID)
+		}
+	})
+}
+
+func TestSearchShortTermsEscapeWildcards(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		percent := createCourse(t, db, "100%出勤")
+		underscore := createCourse(t, db, "C_1 程序设计")
+		bang := createCourse(t, db, "Hi! 英语")
+		createCourse(t, db, "C11 程序设计")
+
+		cases := []struct {
+			term string
+			want []uint
+		}{
+			{"%", []uint{percent.ID}},
+			{"0%", []uint{percent.ID}},
+			{"_1", []uint{underscore.ID}},
+			{"!", []uint{bang.ID}},
+			{"hi", []uint{bang.ID}}, // 不区分大小写
+		}
+		for _, tc := range cases {
+			if got := search(t, db, tc.term); !slices.Equal(got, tc.want) {
+				t.Errorf("搜索 %q 得到 %v，期望 %v", tc.term, got, tc.want)
+			}
+		}
+	})
+}
+
+func TestSearchPagination(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		for i := 0; i < 12; i++ {
+			createCourse(t, db, fmt.Sprintf("选修课程%02d", i))
+		}
+
+		repo := repository.NewSearchRepository(db)
+		seen := map[uint]bool{}
+		for page, want := range []int{5, 5, 2, 0} {
+			results, total, err := repo.Search(repository.SearchQuery{
+				Terms: []string{"选修课程"}, Page: page + 1, PageSize: 5,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 12 || len(results) != want {
+				t.Fatalf("第 %d 页返回 %d 条、总数 %d，期望 %d 条、总数 12", page+1, len(results), total, want)
+			}
+			for _, result := range results {
+				if seen[result.CourseID] {
+					t.Fatalf("课程 %d 出现在多页中", result.CourseID)
+				}
+				seen[result.CourseID] = true
+			}
+		}
+	})
+}
+
+// 搜索词只有 1 到 2 个字符时不走全文索引，与更长的词组合时仍须同时满足
+func TestSearchMixedTermLengths(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		match := createCourse(t, db, "AI 人工智能导论")
+		createCourse(t, db, "人工智能伦理")
+
+		if got := search(t, db, "ai", "人工智能"); !slices.Equal(got, []uint{match.ID}) {
+			t.Fatalf("搜索 \"ai 人工智能\" 得到 %v", got)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrTokenReused 已轮换掉的刷新令牌被再次使用，对应的会话已被吊销
+var ErrTokenReused = errors.New("refresh token reused")
+
+type SessionRepository interface {
+	Create(session *models.Session) error
+	// FindByTokenHash 按当前刷新令牌的摘要查找会话；若摘要属于该会话任何一个已轮换掉的令牌，
+	// 吊销该会话并返回 ErrTokenReused
+	FindByTokenHash(hash string) (*models.Session, error)
+	// Rotate 将会话的刷新令牌从 oldHash 换为 newHash 并顺延有效期，oldHash 记为已使用。
+	// oldHash 已被并发请求换掉时返回 ErrTokenReused
+	Rotate(session *models.Session, oldHash, newHash string, expiresAt time.Time) error
+	// IsActive 会话存在、未吊销且未过期
+	IsActive(id uint) (bool, error)
+	// ListActiveByUser 按最近使用时间倒序列出用户的有效会话
+	ListActiveByUser(userID uint) ([]models.Session, error)
+	// Revoke 吊销属于 userID 的会话，不存在或已失效时返回 ErrNotFound
+	Revoke(userID, id uint) error
+	// RevokeAllByUser 吊销用户除 exceptID 外的全部会话，返回吊销的数量
+	RevokeAllByUser(userID, exceptID uint) (int64, error)
+	// MarkTwoFactor 记录会话通过两步验证的时间
+	MarkTwoFactor(id uint, at time.Time) error
+}
+
+type gormSessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &gormSessionRepository{db: db}
+}
+
+// active 未吊销且未过期的会话
+func active(db *gorm.DB) *gorm.DB {
+	return db.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+}
+
+func (r *gormSessionRepository) Create(session *models.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *gormSessionRepository) FindByTokenHash(hash string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Where("token_hash = ?", hash).Take(&session).Error
+	if err == nil {
+		return &session, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var used models.UsedRefreshToken
+	if err := r.db.Where("token_hash = ?", hash).Take(&used).Error; err != nil {
+		return nil, translate(err)
+	}
+	err = r.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", used.SessionID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return nil, err
+	}
+	return nil, ErrTokenReused
+}
+
+func (r *gormSessionRepository) Rotate(session *models.Session, oldHash, newHash string, expiresAt time.Time) error {
+	now := time.Now()
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND token_hash = ?", session.ID, oldHash).
+			Updates(map[string]interface{}{
+				"token_hash":   newHash,
+				"last_used_at": now,
+				"expires_at":   expiresAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenReused
+		}
+		return tx.Create(&models.UsedRefreshToken{SessionID: session.ID, TokenHash: oldHash}).Error
+	})
+	if err != nil {
+		return err
+	}
+	session.TokenHash = newHash
+	session.LastUsedAt, session.ExpiresAt = now, expiresAt
+	return nil
+}
+
+func (r *gormSessionRepository) IsActive(id uint) (bool, error) {
+	var count int64
+	err := active(r.db.Model(&models.Session{})).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormSessionRepository) ListActiveByUser(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := active(r.db).Where("user_id = ?", userID).Order("last_used_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *gormSessionRepository) Revoke(userID, id uint) error {
+	result := active(r.db.Model(&models.Session{})).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormSessionRepository) RevokeAllByUser(userID, exceptID uint) (int64, error) {
+	result := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+func (r *gormSessionRepository) MarkTwoFactor(id uint, at time.Time) error {
+	return r.db.Model(&models.Session{}).Where("id = ?", id).Update("two_factor_at", at).Error
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/config"
+
+	"gorm.io/gorm"
+)
+
+// StatsRepository 提供跨表的统计查询。
+// 查询结果直接扫描到调用方提供的结构体切片中
+type StatsRepository interface {
+	// CountActiveUsersSince 统计指定时间之后有评分或评论的用户数
+	CountActiveUsersSince(since time.Time) (int64, error)
+	TopRatedCourses(dest interface{}, limit int) error
+	RecentCourses(dest interface{}, limit int) error
+	PopularCourses(dest interface{}, limit int) error
+}
+
+type gormStatsRepository struct {
+	db *gorm.DB
+}
+
+func NewStatsRepository(db *gorm.DB) StatsRepository {
+	return &gormStatsRepository{db: db}
+}
+
+// 以下查询直接写表名，不经过 GORM 的软删除作用域，需自行排除已删除的行。
+// UNION 派生表必须带别名，否则 PostgreSQL 和 MySQL 会拒绝执行
+func (r *gormStatsRepository) CountActiveUsersSince(since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Raw(`
+		SELECT COUNT(DISTINCT user_id) FROM (
+			SELECT user_id FROM ratings WHERE created_at > ? AND deleted_at IS NULL
+			UNION
+			SELECT user_id FROM comments WHERE created_at > ? AND deleted_at IS NULL
+		) AS active_users
+	`, since, since).Row().Scan(&count)
+	return count, err
+}
+
+func (r *gormStatsRepository) TopRatedCourses(dest interface{}, limit int) error {
+	return r.db.Table("courses").
+		Select("courses.id, courses.name, courses.teacher, courses.image_url, courses.subject, courses.grade, AVG(ratings.score) as average_rating, COUNT(ratings.id) as total_ratings").
+		Joins("LEFT JOIN ratings ON courses.id = ratings.course_id AND ratings.deleted_at IS NULL").
+		Where("courses.deleted_at IS NULL").
+		Group("courses.id").
+		Having("COUNT(ratings.id) > 0").
+		Order("average_rating DESC").
+		Limit(limit).
+		Scan(dest).Error
+}
+
+func (r *gormStatsRepository) RecentCourses(dest interface{}, limit int) error {
+	return r.db.Table("courses").
+		Select("courses.id, courses.name, courses.teacher, courses.description, courses.image_url, courses.subject, courses.grade, courses.created_at").
+		Where("courses.deleted_at IS NULL").
+		Order("courses.created_at DESC").
+		Limit(limit).
+		Scan(dest).Error
+}
+
+// PopularCourses 基于评分数量和平均评分的综合指标排序
+func (r *gormStatsRepository) PopularCourses(dest interface{}, limit int) error {
+	engagement := config.CastInteger(r.db.Dialector.Name(), "COUNT(ratings.id) * AVG(ratings.score) + 10")
+	return r.db.Table("courses").
+		Select(`
+			courses.id, 
+			courses.name, 
+			courses.teacher, 
+			courses.image_url, 
+			courses.subject, 
+			courses.grade, 
+			AVG(ratings.score) as average_rating, 
+			COUNT(ratings.id) as total_ratings,
+			` + engagement + ` as student_engagement
+		`).
+		Joins("LEFT JOIN ratings ON courses.id = ratings.course_id AND ratings.deleted_at IS NULL").
+		Where("courses.deleted_at IS NULL").
+		Group("courses.id").
+		Having("COUNT(ratings.id) > 0").
+		Order("student_engagement DESC, average_rating DESC").
+		Limit(limit).
+		Scan(dest).Error
+}
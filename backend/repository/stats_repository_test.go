@@ -0,0 +1,132 @@
+package repository_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/testdb"
+
+	"gorm.io/gorm"
+)
+
+func TestCountActiveUsersSince(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		since := time.Now().Add(-time.Hour)
+		recent, old := time.Now(), since.Add(-time.Hour)
+		users := createUsers(t, db, 4)
+		course := createCourse(t, db, "高等数学")
+
+		// 同时评分和评论的用户只计一次
+		createRating(t, db, users[0], course, 5, recent)
+		createComment(t, db, users[0], course, recent)
+		// 只评论的用户
+		createComment(t, db, users[1], course, recent)
+		// 评分早于统计起点
+		createRating(t, db, users[2], course, 4, old)
+		// 评分已删除
+		deleted := createRating(t, db, users[3], course, 3, recent)
+		if err := db.Delete(&deleted).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		count, err := repository.NewStatsRepository(db).CountActiveUsersSince(since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Fatalf("活跃用户数为 %d，期望 2", count)
+		}
+	})
+}
+
+func TestPopularCourses(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		users := createUsers(t, db, 2)
+		now := time.Now()
+
+		popular := createCourse(t, db, "热门课程")
+		createRating(t, db, users[0], popular, 5, now)
+		createRating(t, db, users[1], popular, 4, now)
+
+		other := createCourse(t, db, "普通课程")
+		createRating(t, db, users[0], other, 3, now)
+
+		// 只有已删除评分的课程不出现
+		unrated := createCourse(t, db, "无有效评分")
+		deleted := createRating(t, db, users[0], unrated, 5, now)
+		if err := db.Delete(&deleted).Error; err != nil {
+			t.Fatal(err)
+		}
+		// 已删除的课程不出现
+		removed := createCourse(t, db, "已删除课程")
+		createRating(t, db, users[0], removed, 5, now)
+		if err := db.Delete(&removed).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		var got []struct {
+			ID                uint
+			Name              string
+			AverageRating     float64
+			TotalRatings      int64
+			StudentEngagement int64
+		}
+		if err := repository.NewStatsRepository(db).PopularCourses(&got, 6); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("返回了 %d 门课程，期望 2: %+v", len(got), got)
+		}
+		// 2 × 4.5 + 10 = 19，1 × 3 + 10 = 13
+		if got[0].ID != popular.ID || got[0].TotalRatings != 2 || got[0].AverageRating != 4.5 || got[0].StudentEngagement != 19 {
+			t.Errorf("第一门课程为 %+v", got[0])
+		}
+		if got[1].ID != other.ID || got[1].TotalRatings != 1 || got[1].StudentEngagement != 13 {
+			t.Errorf("第二门课程为 %+v", got[1])
+		}
+	})
+}
+
+func createUsers(t *testing.T, db *gorm.DB, n int) []models.User {
+	t.Helper()
+	users := make([]models.User, n)
+	for i := range users {
+		users[i] = models.User{
+			Username: fmt.Sprintf("user%d", i),
+			Password: "x",
+			Email:    fmt.Sprintf("user%d@example.com", i),
+		}
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return users
+}
+
+func createCourse(t *testing.T, db *gorm.DB, name string) models.Course {
+	t.Helper()
+	course := models.Course{Name: name, Teacher: "张老师", Subject: "数学", Grade: "高一", Semester: "上"}
+	if err := db.Create(&course).Error; err != nil {
+		t.Fatal(err)
+	}
+	return course
+}
+
+func createRating(t *testing.T, db *gorm.DB, user models.User, course models.Course, score float64, at time.Time) models.Rating {
+	t.Helper()
+	rating := models.Rating{UserID: user.ID, CourseID: course.ID, Score: score, CreatedAt: at}
+	if err := db.Omit("User").Create(&rating).Error; err != nil {
+		t.Fatal(err)
+	}
+	return rating
+}
+
+func createComment(t *testing.T, db *gorm.DB, user models.User, course models.Course, at time.Time) {
+	t.Helper()
+	comment := models.Comment{UserID: user.ID, CourseID: course.ID, Content: "不错", CreatedAt: at}
+	if err := db.Omit("User").Create(&comment).Error; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// 回收站中的记录类型
+const (
+	TrashCourses  = "courses"
+	TrashUsers    = "users"
+	TrashRatings  = "ratings"
+	TrashComments = "comments"
+)
+
+// TrashKinds 全部可进入回收站的记录类型
+var TrashKinds = []string{TrashCourses, TrashUsers, TrashRatings, TrashComments}
+
+// ErrUnknownTrashKind 不支持的回收站记录类型
+var ErrUnknownTrashKind = errors.New("unknown trash kind")
+
+// ErrParentDeleted 评分或评论所属的课程或用户仍在回收站中
+var ErrParentDeleted = errors.New("parent record is deleted")
+
+// TrashRepository 回收站：列出、恢复和彻底清除软删除的记录
+type TrashRepository interface {
+	// List 按删除时间倒序列出指定类型的已删除记录
+	List(kind string, page, pageSize int) (interface{}, int64, error)
+	// Restore 恢复记录；课程和用户会连同与其一起删除的评分、评论一并恢复
+	Restore(kind string, id uint) error
+	// Purge 彻底删除在 before 之前进入回收站的记录及其全部子记录，返回各表删除的行数
+	Purge(before time.Time) (map[string]int64, error)
+}
+
+type gormTrashRepository struct {
+	db *gorm.DB
+}
+
+func NewTrashRepository(db *gorm.DB) TrashRepository {
+	return &gormTrashRepository{db: db}
+}
+
+func trashModel(kind string) (interface{}, error) {
+	switch kind {
+	case TrashCourses:
+		return &[]models.Course{}, nil
+	case TrashUsers:
+		return &[]models.User{}, nil
+	case TrashRatings:
+		return &[]models.Rating{}, nil
+	case TrashComments:
+		return &[]models.Comment{}, nil
+	default:
+		return nil, ErrUnknownTrashKind
+	}
+}
+
+func (r *gormTrashRepository) List(kind string, page, pageSize int) (interface{}, int64, error) {
+	dest, err := trashModel(kind)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := r.db.Unscoped().Model(dest).Where("deleted_at IS NOT NULL")
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("deleted_at DESC").Scopes(paginate(page, pageSize))
+	if kind == TrashRatings || kind == TrashComments {
+		// 关联的用户可能同样已被删除
+		query = query.Preload("User", func(db *gorm.DB) *gorm.DB { return db.Unscoped() })
+	}
+	if err := query.Find(dest).Error; err != nil {
+		return nil, 0, err
+	}
+	return dest, total, nil
+}
+
+func (r *gormTrashRepository) Restore(kind string, id uint) error {
+	switch kind {
+	case TrashCourses:
+		return restoreCascade(r.db, TrashCourses, id)
+	case TrashUsers:
+		return restoreCascade(r.db, TrashUsers, id)
+	case TrashRatings:
+		return r.restoreChild(&models.Rating{}, id)
+	case TrashComments:
+		return r.restoreChild(&models.Comment{}, id)
+	default:
+		return ErrUnknownTrashKind
+	}
+}
+
+// restoreChild 恢复单条评分或评论，所属课程和用户必须未被删除
+func (r *gormTrashRepository) restoreChild(model interface{}, id uint) error {
+	var ref struct {
+		UserID   uint
+		CourseID uint
+	}
+	result := r.db.Unscoped().Model(model).Where("id = ? AND deleted_at IS NOT NULL", id).Take(&ref)
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+
+	var live int64
+	if err := r.db.Model(&models.User{}).Where("id = ?", ref.UserID).Count(&live).Error; err != nil {
+		return err
+	}
+	if live == 0 {
+		return ErrParentDeleted
+	}
+	if err := r.db.Model(&models.Course{}).Where("id = ?", ref.CourseID).Count(&live).Error; err != nil {
+		return err
+	}
+	if live == 0 {
+		return ErrParentDeleted
+	}
+
+	return r.db.Unscoped().Model(model).Where("id = ?", id).Update("deleted_at", nil).Error
+}
+
+func (r *gormTrashRepository) Purge(before time.Time) (map[string]int64, error) {
+	purged := make(map[string]int64)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		const expired = "deleted_at IS NOT NULL AND deleted_at < ?"
+		// 先清除单独删除的评分和评论，再连同子记录清除课程和用户
+		for _, item := range []struct {
+			kind  string
+			model interface{}
+		}{
+			{TrashRatings, &models.Rating{}},
+			{TrashComments, &models.Comment{}},
+		} {
+			result := tx.Unscoped().Where(expired, before).Delete(item.model)
+			if result.Error != nil {
+				return fmt.Errorf("清除 %s 失败: %v", item.kind, result.Error)
+			}
+			purged[item.kind] += result.RowsAffected
+		}
+		for _, kind := range []string{TrashCourses, TrashUsers} {
+			if err := purgeCascade(tx, kind, purged, expired, before); err != nil {
+				return fmt.Errorf("清除 %s 失败: %v", kind, err)
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrCodeReused 动态验证码所在的时间步已经使用过
+var ErrCodeReused = errors.New("two-factor code already used")
+
+// TwoFactorRepository 管理用户的 TOTP 密钥和恢复码
+type TwoFactorRepository interface {
+	// SetPendingSecret 保存待确认的密钥，已启用两步验证时返回 ErrNotFound
+	SetPendingSecret(userID uint, secret string) error
+	// Enable 确认待启用的密钥并替换恢复码，step 为确认时使用的时间步；
+	// 已启用或没有待确认的密钥时返回 ErrNotFound
+	Enable(userID uint, step int64, codeHashes []string) error
+	// Disable 清除密钥和全部恢复码
+	Disable(userID uint) error
+	// UseStep 记录一次通过校验的时间步，不晚于上次记录的时间步时返回 ErrCodeReused
+	UseStep(userID uint, step int64) error
+	// ReplaceRecoveryCodes 作废全部旧恢复码，保存新的一组
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	// UseRecoveryCode 标记一个未使用的恢复码为已使用，无效时返回 ErrNotFound
+	UseRecoveryCode(userID uint, codeHash string) error
+	// CountRecoveryCodes 统计未使用的恢复码
+	CountRecoveryCodes(userID uint) (int64, error)
+}
+
+type gormTwoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) TwoFactorRepository {
+	return &gormTwoFactorRepository{db: db}
+}
+
+func (r *gormTwoFactorRepository) SetPendingSecret(userID uint, secret string) error {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_enabled_at IS NULL", userID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormTwoFactorRepository) Enable(userID uint, step int64, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND totp_enabled_at IS NULL AND totp_secret IS NOT NULL AND totp_secret <> ''", userID).
+			Updates(map[string]interface{}{"totp_enabled_at": time.Now(), "totp_last_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func (r *gormTwoFactorRepository) Disable(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"totp_secret": "", "totp_enabled_at": nil, "totp_last_step": 0}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+func (r *gormTwoFactorRepository) UseStep(userID uint, step int64) error {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCodeReused
+	}
+	return nil
+}
+
+func (r *gormTwoFactorRepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// replaceRecoveryCodes 删除用户的全部恢复码并保存新的一组
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if len(codeHashes) == 0 {
+		return nil
+	}
+	codes := make([]models.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	return tx.Create(&codes).Error
+}
+
+func (r *gormTwoFactorRepository) UseRecoveryCode(userID uint, codeHash string) error {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormTwoFactorRepository) CountRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,86 @@
+package repository_test
+
+import (
+	"errors"
+	"testing"
+	"xuan-ke-tong/repository"
+	"xuan-ke-tong/testdb"
+	"xuan-ke-tong/utils"
+
+	"gorm.io/gorm"
+)
+
+// 同一时间步的验证码只能使用一次，更早的时间步也不再接受
+func TestTwoFactorStepUsedOnce(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		user := createUsers(t, db, 1)[0]
+		repo := repository.NewTwoFactorRepository(db)
+		if err := repo.SetPendingSecret(user.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Enable(user.ID, 100, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, step := range []int64{100, 99} {
+			if err := repo.UseStep(user.ID, step); !errors.Is(err, repository.ErrCodeReused) {
+				t.Fatalf("时间步 %d: %v，期望 ErrCodeReused", step, err)
+			}
+		}
+		if err := repo.UseStep(user.ID, 101); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.UseStep(user.ID, 101); !errors.Is(err, repository.ErrCodeReused) {
+			t.Fatalf("重复使用时间步 101: %v，期望 ErrCodeReused", err)
+		}
+	})
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		users := createUsers(t, db, 2)
+		repo := repository.NewTwoFactorRepository(db)
+
+		codes := make([]string, 3)
+		hashes := make([]string, 3)
+		for i := range codes {
+			code, hash, err := utils.NewRecoveryCode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			codes[i], hashes[i] = code, hash
+		}
+		if err := repo.ReplaceRecoveryCodes(users[0].ID, hashes); err != nil {
+			t.Fatal(err)
+		}
+
+		// 其他用户不能使用
+		if err := repo.UseRecoveryCode(users[1].ID, hashes[0]); !errors.Is(err, repository.ErrNotFound) {
+			t.Fatalf("其他用户使用恢复码: %v，期望 ErrNotFound", err)
+		}
+		if err := repo.UseRecoveryCode(users[0].ID, utils.HashRecoveryCode(codes[0])); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.UseRecoveryCode(users[0].ID, utils.HashRecoveryCode(codes[0])); !errors.Is(err, repository.ErrNotFound) {
+			t.Fatalf("再次使用恢复码: %v，期望 ErrNotFound", err)
+		}
+		if count, err := repo.CountRecoveryCodes(users[0].ID); err != nil || count != 2 {
+			t.Fatalf("剩余恢复码 %d, %v，期望 2", count, err)
+		}
+
+		// 重新生成后旧恢复码全部作废
+		code, hash, err := utils.NewRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.ReplaceRecoveryCodes(users[0].ID, []string{hash}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.UseRecoveryCode(users[0].ID, hashes[1]); !errors.Is(err, repository.ErrNotFound) {
+			t.Fatalf("使用已作废的恢复码: %v，期望 ErrNotFound", err)
+		}
+		if err := repo.UseRecoveryCode(users[0].ID, utils.HashRecoveryCode(code)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
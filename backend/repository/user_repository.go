@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// UserFilter 用户列表的筛选和分页条件
+type UserFilter struct {
+	Keyword  string // 匹配用户名、昵称或邮箱
+	Role     string
+	Page     int
+	PageSize int
+}
+
+type UserRepository interface {
+	FindByID(id uint) (*models.User, error)
+	FindByUsername(username string) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+	Create(user *models.User) error
+	Save(user *models.User) error
+	// Delete 将用户连同其评分和评论移入回收站，并关闭其等待中的求评价请求
+	Delete(user *models.User) error
+	// UsernameTaken 和 EmailTaken 检查唯一字段是否已被占用，回收站中的用户同样占用
+	UsernameTaken(username string) (bool, error)
+	EmailTaken(email string) (bool, error)
+	List(filter UserFilter) ([]models.User, int64, error)
+	Count() (int64, error)
+	CountByRole(role string) (int64, error)
+	CountCreatedSince(since time.Time) (int64, error)
+	CountCreatedBetween(from, to time.Time) (int64, error)
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+func (r *gormUserRepository) FindByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByUsername(username string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) Create(user *models.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *gormUserRepository) Save(user *models.User) error {
+	return r.db.Save(user).Error
+}
+
+func (r *gormUserRepository) Delete(user *models.User) error {
+	return softDeleteCascade(r.db, TrashUsers, user.ID)
+}
+
+func (r *gormUserRepository) UsernameTaken(username string) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormUserRepository) EmailTaken(email string) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormUserRepository) List(filter UserFilter) ([]models.User, int64, error) {
+	query := r.db.Model(&models.User{})
+
+	// 搜索功能
+	if filter.Keyword != "" {
+		like := "%" + filter.Keyword + "%"
+		query = query.Where("username LIKE ? OR nickname LIKE ? OR email LIKE ?", like, like, like)
+	}
+
+	// 角色筛选
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	if err := query.Scopes(paginate(filter.Page, filter.PageSize)).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *gormUserRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormUserRepository) CountByRole(role string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("role = ?", role).Count(&count).Error
+	return count, err
+}
+
+func (r *gormUserRepository) CountCreatedSince(since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("created_at > ?", since).Count(&count).Error
+	return count, err
+}
+
+func (r *gormUserRepository) CountCreatedBetween(from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("created_at BETWEEN ? AND ?", from, to).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"time"
+	"xuan-ke-tong/models"
+
+	"gorm.io/gorm"
+)
+
+// UserTokenRepository 管理通过邮件发送的一次性令牌
+type UserTokenRepository interface {
+	// Replace 作废用户同一用途的未使用令牌，再保存新令牌，保证同一时间只有最新的链接有效
+	Replace(token *models.UserToken) error
+	// Consume 按摘要取出未使用且未过期的令牌并标记为已使用，并发请求中只有一个能成功；
+	// 令牌无效时返回 ErrNotFound
+	Consume(purpose, hash string) (*models.UserToken, error)
+	// Find 按摘要查找未使用且未过期的令牌，不标记为已使用；令牌无效时返回 ErrNotFound
+	Find(purpose, hash string) (*models.UserToken, error)
+	// IssuedSince 用户在 since 之后是否签发过该用途的令牌（无论是否已使用）
+	IssuedSince(userID uint, purpose string, since time.Time) (bool, error)
+}
+
+type gormUserTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &gormUserTokenRepository{db: db}
+}
+
+func (r *gormUserTokenRepository) Replace(token *models.UserToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (r *gormUserTokenRepository) Find(purpose, hash string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, hash, time.Now()).
+		Take(&token).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r *gormUserTokenRepository) Consume(purpose, hash string) (*models.UserToken, error) {
+	token, err := r.Find(purpose, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := r.db.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	token.UsedAt = &now
+	return token, nil
+}
+
+func (r *gormUserTokenRepository) IssuedSince(userID uint, purpose string, since time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, since).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -1,45 +1,118 @@
 package routes
 
 import (
+	"xuan-ke-tong/app"
 	"xuan-ke-tong/controllers"
 	"xuan-ke-tong/middleware"
+	"xuan-ke-tong/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AdminRoutes(router *gin.Engine) {
+func AdminRoutes(router *gin.Engine, a *app.Application) {
+	adminCtrl := controllers.NewAdminController(a.Repos, a.Authz)
+	stats := controllers.NewHomeStatsController(a.Repos)
+	courses := controllers.NewCourseController(a.Repos, a.Authz)
+	backups := controllers.NewBackupController(a.Backups)
+	trash := controllers.NewTrashController(a.Repos)
+	invites := controllers.NewInviteController(a.Repos.Invites)
+	logins := controllers.NewLoginAttemptController(a.Repos, a.Config.Auth.Lockout)
+	roles := controllers.NewRoleController(a.Repos, a.Authz)
+	audit := controllers.NewAuditController(a.Repos.Audit)
+
 	admin := router.Group("/api/v1/admin")
-	admin.Use(middleware.AuthMiddleware())
-	admin.Use(middleware.RequireAdminMiddleware())
-	{
-		admin.GET("/stats", controllers.GetStats)
-		admin.GET("/stats/enhanced", controllers.GetEnhancedHomeStats) // 增强版首页统计
-		admin.GET("/user-stats", controllers.GetUserStats)
-
-		// 用户管理路由
-		admin.GET("/users", controllers.GetAllUsers)
-		admin.GET("/users/:id", controllers.GetUserByID)
-		admin.PUT("/users/:id", controllers.UpdateUser)
-		admin.DELETE("/users/:id", controllers.DeleteUser)
-
-		admin.GET("/ratings", controllers.GetAllRatings)
-		admin.GET("/comments", controllers.GetAllComments)
-
-		// 课程管理路由
-		admin.POST("/courses", controllers.CreateCourse)
-		admin.GET("/courses", controllers.GetCourses)
-		admin.GET("/courses/:id", controllers.GetCourse)
-		admin.PUT("/courses/:id", controllers.UpdateCourse)
-		admin.DELETE("/courses/:id", controllers.DeleteCourse)
-
-		// 测试路由
-		admin.GET("/test", func(c *gin.Context) {
-			c.JSON(200, gin.H{"message": "Admin routes are working"})
-		})
-	}
-
-	// 添加根级别的测试路由
-	router.GET("/admin-test", func(c *gin.Context) {
-		c.JSON(200, gin.H{"message": "AdminRoutes function was called"})
-	})
+	admin.Use(middleware.AuthMiddleware(a.Tokens, a.Repos.Sessions, a.Repos.AccessTokens))
+
+	// 按权限分组，每组只检查令牌中的角色，不查询数据库
+	can := func(permission string) *gin.RouterGroup {
+		return admin.Group("", middleware.RequirePermission(a.Authz, permission))
+	}
+
+	statsGroup := can(models.PermStatsView)
+	{
+		statsGroup.GET("/stats", adminCtrl.GetStats)
+		statsGroup.GET("/stats/enhanced", stats.GetEnhancedHomeStats) // 增强版首页统计
+		statsGroup.GET("/user-stats", adminCtrl.GetUserStats)
+	}
+
+	// 用户管理路由
+	userGroup := can(models.PermUserManage)
+	{
+		userGroup.GET("/users", adminCtrl.GetAllUsers)
+		userGroup.GET("/users/:id", adminCtrl.GetUserByID)
+		userGroup.PUT("/users/:id", adminCtrl.UpdateUser)
+		userGroup.DELETE("/users/:id", adminCtrl.DeleteUser)
+		userGroup.POST("/users/:id/unlock", logins.UnlockUser)
+		userGroup.DELETE("/users/:id/two-factor", adminCtrl.ResetTwoFactor)
+
+		// 注册邀请码
+		userGroup.GET("/invites", invites.ListInvites)
+		userGroup.POST("/invites", invites.CreateInvite)
+		userGroup.DELETE("/invites/:id", invites.DeleteInvite)
+
+		// 登录失败记录和锁定
+		userGroup.GET("/login-events", logins.ListLoginEvents)
+		userGroup.GET("/lockouts", logins.ListLockouts)
+		userGroup.DELETE("/lockouts/:id", logins.DeleteLockout)
+	}
+
+	moderateGroup := can(models.PermRatingModerate)
+	{
+		moderateGroup.GET("/ratings", adminCtrl.GetAllRatings)
+		moderateGroup.GET("/comments", adminCtrl.GetAllComments)
+		moderateGroup.DELETE("/ratings/:id", adminCtrl.DeleteRating)
+		moderateGroup.DELETE("/comments/:id", adminCtrl.DeleteComment)
+	}
+
+	// 课程管理路由
+	courseGroup := can(models.PermCourseEdit)
+	{
+		courseGroup.POST("/courses", courses.CreateCourse)
+		courseGroup.GET("/courses", courses.GetCourses)
+		courseGroup.GET("/courses/:id", courses.GetCourse)
+		courseGroup.PUT("/courses/:id", courses.UpdateCourse)
+		courseGroup.DELETE("/courses/:id", courses.DeleteCourse)
+	}
+
+	// 课程负责教师
+	ownerGroup := can(models.PermCourseManage)
+	{
+		ownerGroup.GET("/courses/:id/owners", courses.ListOwners)
+		ownerGroup.POST("/courses/:id/owners", courses.AddOwner)
+		ownerGroup.DELETE("/courses/:id/owners/:userId", courses.RemoveOwner)
+	}
+
+	// 回收站
+	trashGroup := can(models.PermTrashManage)
+	{
+		trashGroup.GET("/trash/:type", trash.ListTrash)
+		trashGroup.POST("/trash/:type/:id/restore", trash.RestoreTrash)
+	}
+
+	// 数据库快照
+	backupGroup := can(models.PermBackupManage)
+	{
+		backupGroup.GET("/backups", backups.ListBackups)
+		backupGroup.POST("/backups", backups.CreateBackup)
+		backupGroup.GET("/backups/:name", backups.DownloadBackup)
+	}
+
+	// 角色管理
+	roleGroup := can(models.PermRoleManage)
+	{
+		roleGroup.GET("/permissions", roles.ListPermissions)
+		roleGroup.GET("/roles", roles.ListRoles)
+		roleGroup.POST("/roles", roles.CreateRole)
+		roleGroup.PUT("/roles/:name", roles.UpdateRole)
+		roleGroup.PUT("/roles/:name/two-factor", roles.SetTwoFactor)
+		roleGroup.DELETE("/roles/:name", roles.DeleteRole)
+		roleGroup.GET("/role-changes", roles.ListRoleChanges)
+	}
+
+	// 审计日志
+	auditGroup := can(models.PermAuditView)
+	{
+		auditGroup.GET("/audit", audit.ListAudit)
+		auditGroup.GET("/audit/export", audit.ExportAudit)
+	}
 }
@@ -1,14 +1,67 @@
 package routes
 
 import (
+	"xuan-ke-tong/app"
 	"xuan-ke-tong/controllers"
 	"xuan-ke-tong/middleware"
+	"xuan-ke-tong/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRoutes(router *gin.Engine) {
-	router.POST("/api/v1/auth/register", controllers.Register)
-	router.POST("/api/v1/auth/login", controllers.Login)
-	router.GET("/api/v1/auth/me", middleware.AuthMiddleware(), controllers.GetCurrentUser)
+func AuthRoutes(router *gin.Engine, a *app.Application) {
+	auth := controllers.NewAuthController(a.Repos, a.Tokens, a.Authz, a.Mailer, a.Config.PublicURL, a.Config.Auth)
+	requireAuth := middleware.AuthMiddleware(a.Tokens, a.Repos.Sessions, a.Repos.AccessTokens)
+	// 个人访问令牌只能读取资料，账户安全相关的接口只接受登录会话
+	canReadProfile := middleware.RequireScope(models.ScopeReadProfile)
+	requireSession := middleware.RequireSession()
+
+	router.GET("/api/v1/auth/registration", auth.RegistrationPolicy)
+	router.POST("/api/v1/auth/register", auth.Register)
+	router.POST("/api/v1/auth/login", auth.Login)
+	router.POST("/api/v1/auth/refresh", auth.Refresh)
+	router.POST("/api/v1/auth/logout", requireAuth, requireSession, auth.Logout)
+	router.GET("/api/v1/auth/me", requireAuth, canReadProfile, auth.GetCurrentUser)
+	router.GET("/.well-known/jwks.json", auth.JWKS)
+
+	// 尚无管理员时用一次性引导令牌创建首位管理员
+	bootstrap := controllers.NewBootstrapController(a.Repos, a.Tokens, a.Bootstrap)
+	router.GET("/api/v1/auth/bootstrap", bootstrap.BootstrapStatus)
+	router.POST("/api/v1/auth/bootstrap", bootstrap.Bootstrap)
+
+	// 自助修改资料、密码和邮箱
+	profile := controllers.NewProfileController(a.Repos, a.Mailer, a.Config.PublicURL, a.Config.Auth, a.Authz)
+	router.GET("/api/v1/auth/me/permissions", requireAuth, canReadProfile, profile.GetPermissions)
+	router.PUT("/api/v1/auth/me", requireAuth, requireSession, profile.UpdateProfile)
+	router.POST("/api/v1/auth/me/password", requireAuth, requireSession, profile.ChangePassword)
+	router.POST("/api/v1/auth/me/email", requireAuth, requireSession, profile.ChangeEmail)
+
+	password := controllers.NewPasswordController(a.Repos, a.Mailer, a.Config.PublicURL, a.Config.Auth)
+	router.POST("/api/v1/auth/password/forgot", password.ForgotPassword)
+	router.POST("/api/v1/auth/password/reset", password.ResetPassword)
+
+	email := controllers.NewEmailController(a.Repos, a.Mailer, a.Config.PublicURL, a.Config.Auth)
+	router.POST("/api/v1/auth/email/verify", email.VerifyEmail)
+	router.POST("/api/v1/auth/email/resend", requireAuth, requireSession, email.ResendVerification)
+
+	// 登录设备管理
+	router.GET("/api/v1/auth/sessions", requireAuth, requireSession, auth.ListSessions)
+	router.DELETE("/api/v1/auth/sessions", requireAuth, requireSession, auth.RevokeOtherSessions)
+	router.DELETE("/api/v1/auth/sessions/:id", requireAuth, requireSession, auth.RevokeSession)
+
+	// 两步验证
+	twoFactor := controllers.NewTwoFactorController(a.Repos, a.Tokens, a.Authz, a.Config.Auth)
+	router.POST("/api/v1/auth/login/2fa", twoFactor.VerifyLogin)
+	router.GET("/api/v1/auth/2fa", requireAuth, requireSession, twoFactor.GetStatus)
+	router.POST("/api/v1/auth/2fa/setup", requireAuth, requireSession, twoFactor.Setup)
+	router.POST("/api/v1/auth/2fa/enable", requireAuth, requireSession, twoFactor.Enable)
+	router.POST("/api/v1/auth/2fa/disable", requireAuth, requireSession, twoFactor.Disable)
+	router.POST("/api/v1/auth/2fa/recovery-codes", requireAuth, requireSession, twoFactor.RegenerateRecoveryCodes)
+
+	// 个人访问令牌
+	tokens := controllers.NewAccessTokenController(a.Repos, a.Authz)
+	router.GET("/api/v1/auth/tokens/scopes", requireAuth, requireSession, tokens.ListScopes)
+	router.GET("/api/v1/auth/tokens", requireAuth, requireSession, tokens.ListTokens)
+	router.POST("/api/v1/auth/tokens", requireAuth, requireSession, tokens.CreateToken)
+	router.DELETE("/api/v1/auth/tokens/:id", requireAuth, requireSession, tokens.RevokeToken)
 }
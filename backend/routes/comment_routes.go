@@ -1,12 +1,22 @@
 package routes
 
 import (
+	"xuan-ke-tong/app"
 	"xuan-ke-tong/controllers"
+	"xuan-ke-tong/middleware"
+	"xuan-ke-tong/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CommentRoutes(router *gin.Engine) {
-	router.POST("/api/v1/comments", controllers.CreateComment)
-	router.GET("/api/v1/courses/:id/comments", controllers.GetCommentsByCourse)
+func CommentRoutes(router *gin.Engine, a *app.Application) {
+	comments := controllers.NewCommentController(a.Repos.Comments)
+
+	// 发表评论 - 需要认证且邮箱已验证
+	router.POST("/api/v1/comments",
+		middleware.AuthMiddleware(a.Tokens, a.Repos.Sessions, a.Repos.AccessTokens),
+		middleware.RequireScope(models.ScopeWriteReviews),
+		middleware.RequireVerifiedEmailMiddleware(a.Repos.Users),
+		comments.CreateComment)
+	router.GET("/api/v1/courses/:id/comments", comments.GetCommentsByCourse)
 }
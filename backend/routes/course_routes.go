@@ -1,18 +1,21 @@
 package routes
 
 import (
+	"xuan-ke-tong/app"
 	"xuan-ke-tong/controllers"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CourseRoutes(router *gin.Engine) {
+func CourseRoutes(router *gin.Engine, a *app.Application) {
+	courses := controllers.NewCourseController(a.Repos, a.Authz)
+
 	// 公共路由
-	router.POST("/api/v1/courses", controllers.CreateCourse)
-	router.GET("/api/v1/courses", controllers.GetCourses)
-	router.GET("/api/v1/courses/:id", controllers.GetCourse)
+	router.GET("/api/v1/courses", courses.GetCourses)
+	router.GET("/api/v1/courses/:id", courses.GetCourse)
 
-	// 保持原有路由以兼容现有代码
-	router.PUT("/api/v1/courses/:id", controllers.UpdateCourse)
-	router.DELETE("/api/v1/courses/:id", controllers.DeleteCourse)
+	// 原有的修改接口已停用，只返回停用说明，课程由 /api/v1/admin/courses 管理
+	router.POST("/api/v1/courses", controllers.LegacyCourseMutation)
+	router.PUT("/api/v1/courses/:id", controllers.LegacyCourseMutation)
+	router.DELETE("/api/v1/courses/:id", controllers.LegacyCourseMutation)
 }
@@ -1,16 +1,23 @@
 package routes
 
 import (
+	"xuan-ke-tong/app"
 	"xuan-ke-tong/controllers"
 	"xuan-ke-tong/middleware"
+	"xuan-ke-tong/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func EvaluationRequestRoutes(router *gin.Engine) {
+func EvaluationRequestRoutes(router *gin.Engine, a *app.Application) {
+	requests := controllers.NewEvaluationRequestController(a.Repos.EvaluationRequests, a.Repos.Courses)
+
 	// 获取求评价列表 - 公开访问
-	router.GET("/api/v1/evaluation-requests", controllers.GetEvaluationRequests)
+	router.GET("/api/v1/evaluation-requests", requests.GetEvaluationRequests)
 
 	// 创建求评价请求 - 需要认证
-	router.POST("/api/v1/evaluation-requests", middleware.AuthMiddleware(), controllers.CreateEvaluationRequest)
+	router.POST("/api/v1/evaluation-requests",
+		middleware.AuthMiddleware(a.Tokens, a.Repos.Sessions, a.Repos.AccessTokens),
+		middleware.RequireScope(models.ScopeWriteReviews),
+		requests.CreateEvaluationRequest)
 }
@@ -1,16 +1,29 @@
-package routes
-
-import (
-	"xuan-ke-tong/controllers"
-
-	"github.com/gin-gonic/gin"
-)
-
-func OAuth2Routes(r *gin.Engine) {
-	oauth2 := r.Group("/api/v1/auth/oauth2")
-	{
-		oauth2.GET("/state", controllers.GenerateOAuth2State)
-		oauth2.GET("/callback", controllers.OAuth2Callback)
-	}
-}
-
+package routes
+
+import (
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/controllers"
+	"xuan-ke-tong/middleware"
+	"xuan-ke-tong/oauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func OAuth2Routes(r *gin.Engine, a *app.Application) {
+	ctrl := controllers.NewOAuth2Controller(a.Config, a.Repos, a.Tokens, a.Authz, oauth.NewRegistry(a.Config), a.OAuthStates)
+	requireAuth := middleware.AuthMiddleware(a.Tokens, a.Repos.Sessions, a.Repos.AccessTokens)
+	requireSession := middleware.RequireSession()
+
+	oauth2 := r.Group("/api/v1/auth/oauth2")
+	{
+		oauth2.GET("/providers", ctrl.ListProviders)
+		oauth2.GET("/state", ctrl.GenerateOAuth2State)
+		oauth2.GET("/callback", ctrl.OAuth2Callback)
+		// 发起绑定也在该路径下，以便写入浏览器绑定 Cookie
+		oauth2.POST("/link", requireAuth, requireSession, ctrl.LinkIdentity)
+	}
+
+	// 当前用户绑定的外部账号
+	r.GET("/api/v1/auth/me/identities", requireAuth, requireSession, ctrl.ListIdentities)
+	r.DELETE("/api/v1/auth/me/identities/:id", requireAuth, requireSession, ctrl.UnlinkIdentity)
+}
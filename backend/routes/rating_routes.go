@@ -1,14 +1,21 @@
 package routes
 
 import (
+	"xuan-ke-tong/app"
 	"xuan-ke-tong/controllers"
 	"xuan-ke-tong/middleware"
+	"xuan-ke-tong/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RatingRoutes(router *gin.Engine) {
-	router.POST("/api/v1/ratings", middleware.AuthMiddleware(), controllers.CreateRating)
-	router.POST("/api/v1/courses/:id/ratings", middleware.AuthMiddleware(), controllers.CreateRating)
-	router.GET("/api/v1/courses/:id/ratings", controllers.GetRatingsByCourse)
+func RatingRoutes(router *gin.Engine, a *app.Application) {
+	ratings := controllers.NewRatingController(a.Repos.Ratings)
+	requireAuth := middleware.AuthMiddleware(a.Tokens, a.Repos.Sessions, a.Repos.AccessTokens)
+	canReview := middleware.RequireScope(models.ScopeWriteReviews)
+	requireVerified := middleware.RequireVerifiedEmailMiddleware(a.Repos.Users)
+
+	router.POST("/api/v1/ratings", requireAuth, canReview, requireVerified, ratings.CreateRating)
+	router.POST("/api/v1/courses/:id/ratings", requireAuth, canReview, requireVerified, ratings.CreateRating)
+	router.GET("/api/v1/courses/:id/ratings", ratings.GetRatingsByCourse)
 }
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/middleware"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// NewRouter 创建注册了全部接口的 gin 引擎
+func NewRouter(a *app.Application) *gin.Engine {
+	r := gin.Default()
+	// 默认不信任任何代理，否则客户端可以伪造 X-Forwarded-For 绕过按 IP 的登录锁定。
+	// 配置已校验过格式，这里不会出错
+	if err := r.SetTrustedProxies(a.Config.Server.TrustedProxies); err != nil {
+		panic(err)
+	}
+
+	corsConfig := cors.DefaultConfig()
+	if a.Config.CORS.AllowAllOrigins() {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = a.Config.CORS.AllowedOrigins
+		// 前端与后端跨域部署时，OAuth2 授权需要携带浏览器绑定 Cookie
+		corsConfig.AllowCredentials = true
+	}
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", middleware.RequestIDHeader}
+	corsConfig.ExposeHeaders = []string{middleware.RequestIDHeader}
+	r.Use(middleware.RequestID(), cors.New(corsConfig))
+
+	AuthRoutes(r, a)
+	CourseRoutes(r, a)
+	RatingRoutes(r, a)
+	CommentRoutes(r, a)
+	SearchRoutes(r, a)
+	AdminRoutes(r, a)
+	UserRoutes(r, a)
+	EvaluationRequestRoutes(r, a)
+	OAuth2Routes(r, a)
+
+	r.GET("/ping", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"message": "pong",
+		})
+	})
+
+	return r
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"xuan-ke-tong/app"
+	"xuan-ke-tong/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SearchRoutes(router *gin.Engine, a *app.Application) {
+	search := controllers.NewSearchController(a.Repos)
+
+	router.GET("/api/v1/search", search.Search)
+}
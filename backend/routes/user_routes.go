@@ -1,11 +1,14 @@
 package routes
 
 import (
+	"xuan-ke-tong/app"
 	"xuan-ke-tong/controllers"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(router *gin.Engine) {
-	router.GET("/api/v1/users/:id", controllers.GetUser)
+func UserRoutes(router *gin.Engine, a *app.Application) {
+	users := controllers.NewUserController(a.Repos.Users, a.Repos.Ratings, a.Repos.Comments)
+
+	router.GET("/api/v1/users/:id", users.GetUser)
 }
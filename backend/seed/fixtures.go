@@ -0,0 +1,267 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FixturesDir 各环境种子数据文件所在目录，文件名为 <环境>.yaml
+const FixturesDir = "fixtures"
+
+// DefaultEnv 未指定环境时使用的种子数据
+const DefaultEnv = "demo"
+
+// Fixtures 一份种子数据文件的内容，支持 YAML 和 JSON。
+// 评分、评论和求评价通过 ref 引用用户和课程，ref 缺省时分别取用户名和课程名
+type Fixtures struct {
+	Users              []UserFixture              `yaml:"users"`
+	Courses            []CourseFixture            `yaml:"courses"`
+	Ratings            []RatingFixture            `yaml:"ratings"`
+	Comments           []CommentFixture           `yaml:"comments"`
+	EvaluationRequests []EvaluationRequestFixture `yaml:"evaluationRequests"`
+	Generate           *GenerateSpec              `yaml:"generate"`
+}
+
+type UserFixture struct {
+	Ref      string `yaml:"ref"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Email    string `yaml:"email"`
+	Nickname string `yaml:"nickname"`
+	Avatar   string `yaml:"avatar"`
+	Role     string `yaml:"role"`
+}
+
+type CourseFixture struct {
+	Ref         string `yaml:"ref"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Teacher     string `yaml:"teacher"`
+	Credits     int    `yaml:"credits"`
+	Grade       string `yaml:"grade"`
+	Semester    string `yaml:"semester"`
+	Subject     string `yaml:"subject"`
+	ImageURL    string `yaml:"imageURL"`
+}
+
+type RatingFixture struct {
+	User       string  `yaml:"user"`
+	Course     string  `yaml:"course"`
+	Score      float64 `yaml:"score"`
+	Difficulty float64 `yaml:"difficulty"`
+	Usefulness float64 `yaml:"usefulness"`
+	Teaching   float64 `yaml:"teaching"`
+	Content    string  `yaml:"content"`
+}
+
+type CommentFixture struct {
+	User    string `yaml:"user"`
+	Course  string `yaml:"course"`
+	Content string `yaml:"content"`
+}
+
+type EvaluationRequestFixture struct {
+	User   string `yaml:"user"`
+	Course string `yaml:"course"`
+	Status string `yaml:"status"`
+}
+
+// GenerateSpec 批量生成压测数据。相同的 Seed 总是生成相同的数据
+type GenerateSpec struct {
+	Seed               int64  `yaml:"seed"`
+	Users              int    `yaml:"users"`
+	Courses            int    `yaml:"courses"`
+	RatingsPerCourse   int    `yaml:"ratingsPerCourse"`
+	CommentsPerCourse  int    `yaml:"commentsPerCourse"`
+	EvaluationRequests int    `yaml:"evaluationRequests"`
+	Password           string `yaml:"password"`
+}
+
+// EnvFile 返回环境对应的种子数据文件路径
+func EnvFile(env string) string {
+	return filepath.Join(FixturesDir, env+".yaml")
+}
+
+// Envs 列出 fixtures 目录下可用的环境
+func Envs() []string {
+	matches, _ := filepath.Glob(filepath.Join(FixturesDir, "*.yaml"))
+	envs := make([]string, 0, len(matches))
+	for _, m := range matches {
+		envs = append(envs, strings.TrimSuffix(filepath.Base(m), ".yaml"))
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+// Load 读取种子数据文件并展开 generate 段。JSON 是 YAML 的子集，两种格式共用同一个解析器
+func Load(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取种子数据失败: %v", err)
+	}
+
+	var fx Fixtures
+	if err := yaml.Unmarshal(data, &fx); err != nil {
+		return nil, fmt.Errorf("解析种子数据 %s 失败: %v", path, err)
+	}
+	if fx.Generate != nil {
+		fx.Generate.expand(&fx)
+	}
+	if err := fx.validate(); err != nil {
+		return nil, fmt.Errorf("种子数据 %s 有误: %v", path, err)
+	}
+	return &fx, nil
+}
+
+func (u UserFixture) ref() string {
+	if u.Ref != "" {
+		return u.Ref
+	}
+	return u.Username
+}
+
+func (c CourseFixture) ref() string {
+	if c.Ref != "" {
+		return c.Ref
+	}
+	return c.Name
+}
+
+// validate 检查必填字段、重复的 ref 以及引用是否都能解析
+func (fx *Fixtures) validate() error {
+	users := make(map[string]bool)
+	for i, u := range fx.Users {
+		if u.Username == "" || u.Email == "" || u.Password == "" {
+			return fmt.Errorf("users[%d]: username、email、password 均为必填", i)
+		}
+		if users[u.ref()] {
+			return fmt.Errorf("users[%d]: ref %q 重复", i, u.ref())
+		}
+		users[u.ref()] = true
+	}
+
+	courses := make(map[string]bool)
+	for i, c := range fx.Courses {
+		if c.Name == "" {
+			return fmt.Errorf("courses[%d]: name 为必填", i)
+		}
+		if courses[c.ref()] {
+			return fmt.Errorf("courses[%d]: ref %q 重复", i, c.ref())
+		}
+		courses[c.ref()] = true
+	}
+
+	check := func(section string, i int, user, course string) error {
+		if !users[user] {
+			return fmt.Errorf("%s[%d]: 未定义的用户 %q", section, i, user)
+		}
+		if !courses[course] {
+			return fmt.Errorf("%s[%d]: 未定义的课程 %q", section, i, course)
+		}
+		return nil
+	}
+	for i, r := range fx.Ratings {
+		if err := check("ratings", i, r.User, r.Course); err != nil {
+			return err
+		}
+	}
+	for i, c := range fx.Comments {
+		if err := check("comments", i, c.User, c.Course); err != nil {
+			return err
+		}
+	}
+	for i, e := range fx.EvaluationRequests {
+		if err := check("evaluationRequests", i, e.User, e.Course); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	generatedGrades    = []string{"大一", "大二", "大三", "大四"}
+	generatedSemesters = []string{"第一学期", "第二学期"}
+	generatedSubjects  = []string{"数学", "物理", "计算机", "英语", "化学", "经济"}
+	generatedComments  = []string{"内容充实，收获很大", "作业偏多但很有帮助", "老师讲得很清楚", "考试难度适中", "推荐选修"}
+)
+
+// expand 按规格追加生成的用户、课程、评分、评论和求评价
+func (g *GenerateSpec) expand(fx *Fixtures) {
+	rnd := rand.New(rand.NewSource(g.Seed))
+	password := g.Password
+	if password == "" {
+		password = "123456"
+	}
+
+	userRefs := make([]string, 0, g.Users)
+	for i := 1; i <= g.Users; i++ {
+		username := fmt.Sprintf("load_user_%04d", i)
+		fx.Users = append(fx.Users, UserFixture{
+			Username: username,
+			Password: password,
+			Email:    username + "@example.com",
+			Nickname: fmt.Sprintf("压测用户%d", i),
+		})
+		userRefs = append(userRefs, username)
+	}
+
+	courseRefs := make([]string, 0, g.Courses)
+	for i := 1; i <= g.Courses; i++ {
+		subject := generatedSubjects[rnd.Intn(len(generatedSubjects))]
+		name := fmt.Sprintf("%s压测课程%03d", subject, i)
+		fx.Courses = append(fx.Courses, CourseFixture{
+			Name:        name,
+			Description: fmt.Sprintf("自动生成的%s课程", subject),
+			Teacher:     fmt.Sprintf("教师%03d", rnd.Intn(100)+1),
+			Credits:     rnd.Intn(4) + 1,
+			Grade:       generatedGrades[rnd.Intn(len(generatedGrades))],
+			Semester:    generatedSemesters[rnd.Intn(len(generatedSemesters))],
+			Subject:     subject,
+		})
+		courseRefs = append(courseRefs, name)
+	}
+
+	if len(userRefs) == 0 || len(courseRefs) == 0 {
+		return
+	}
+
+	for _, course := range courseRefs {
+		// 每个用户对同一课程只评一次分
+		for _, i := range rnd.Perm(len(userRefs))[:min(g.RatingsPerCourse, len(userRefs))] {
+			fx.Ratings = append(fx.Ratings, RatingFixture{
+				User:       userRefs[i],
+				Course:     course,
+				Score:      float64(rnd.Intn(9)+2) / 2,
+				Difficulty: float64(rnd.Intn(5) + 1),
+				Usefulness: float64(rnd.Intn(5) + 1),
+				Teaching:   float64(rnd.Intn(5) + 1),
+			})
+		}
+		for j := 0; j < g.CommentsPerCourse; j++ {
+			fx.Comments = append(fx.Comments, CommentFixture{
+				User:    userRefs[rnd.Intn(len(userRefs))],
+				Course:  course,
+				Content: fmt.Sprintf("%s #%d", generatedComments[rnd.Intn(len(generatedComments))], j+1),
+			})
+		}
+	}
+
+	seen := make(map[[2]int]bool)
+	for len(seen) < min(g.EvaluationRequests, len(userRefs)*len(courseRefs)) {
+		key := [2]int{rnd.Intn(len(userRefs)), rnd.Intn(len(courseRefs))}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		fx.EvaluationRequests = append(fx.EvaluationRequests, EvaluationRequestFixture{
+			User:   userRefs[key[0]],
+			Course: courseRefs[key[1]],
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"xuan-ke-tong/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Options 控制导入行为
+type Options struct {
+	DryRun bool // 只计算差异，不写入数据库
+	Reset  bool // 导入前清空用户、课程、评分、评论和求评价
+}
+
+// 变更类型
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change 导入过程中的一条变更
+type Change struct {
+	Action string
+	Kind   string
+	Key    string
+	Detail string
+}
+
+// Report 一次导入的变更清单
+type Report struct {
+	Changes   []Change
+	Unchanged int
+	Deleted   int64 // Reset 时清空的行数
+}
+
+// Count 返回指定类型变更的数量
+func (r *Report) Count(action string) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+var errDryRun = errors.New("dry run")
+
+// Apply 在一个事务中把数据库调整为种子数据描述的状态：
+// 按用户名、课程名等自然键匹配已有记录，缺失的新建，字段不一致的更新。
+// DryRun 时在同一事务中计算差异后回滚
+func Apply(db *gorm.DB, fx *Fixtures, opts Options) (*Report, error) {
+	// 未经 Load 构造的种子数据同样要检查引用，避免写入 ID 为 0 的评分
+	if err := fx.validate(); err != nil {
+		return nil, fmt.Errorf("种子数据有误: %v", err)
+	}
+	report := &Report{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		a := &applier{
+			tx:      tx,
+			report:  report,
+			users:   make(map[string]uint),
+			courses: make(map[string]uint),
+			hashes:  make(map[string]string),
+		}
+		if err := a.apply(fx, opts.Reset); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	return report, err
+}
+
+type applier struct {
+	tx      *gorm.DB
+	report  *Report
+	users   map[string]uint // ref -> 用户 ID
+	courses map[string]uint // ref -> 课程 ID
+	hashes  map[string]string
+}
+
+func (a *applier) apply(fx *Fixtures, reset bool) error {
+	if reset {
+		if err := a.reset(); err != nil {
+			return err
+		}
+	}
+	for _, u := range fx.Users {
+		if err := a.user(u); err != nil {
+			return fmt.Errorf("用户 %s: %v", u.Username, err)
+		}
+	}
+	for _, c := range fx.Courses {
+		if err := a.course(c); err != nil {
+			return fmt.Errorf("课程 %s: %v", c.Name, err)
+		}
+	}
+	for _, r := range fx.Ratings {
+		if err := a.rating(r); err != nil {
+			return fmt.Errorf("评分 %s/%s: %v", r.User, r.Course, err)
+		}
+	}
+	for _, c := range fx.Comments {
+		if err := a.comment(c); err != nil {
+			return fmt.Errorf("评论 %s/%s: %v", c.User, c.Course, err)
+		}
+	}
+	for _, e := range fx.EvaluationRequests {
+		if err := a.evaluationRequest(e); err != nil {
+			return fmt.Errorf("求评价 %s/%s: %v", e.User, e.Course, err)
+		}
+	}
+	return nil
+}
+
+// reset 按依赖顺序清空种子数据涉及的表
+func (a *applier) reset() error {
+	tables := []struct {
+		kind  string
+		model interface{}
+	}{
+		{"session", &models.Session{}},
+		{"user_token", &models.UserToken{}},
+		{"access_token", &models.AccessToken{}},
+		{"recovery_code", &models.RecoveryCode{}},
+		{"external_identity", &models.ExternalIdentity{}},
+		{"course_owner", &models.CourseOwner{}},
+		{"evaluation_request", &models.EvaluationRequest{}},
+		{"comment", &models.Comment{}},
+		{"rating", &models.Rating{}},
+		{"course", &models.Course{}},
+		{"user", &models.User{}},
+	}
+	for _, t := range tables {
+		result := a.tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(t.model)
+		if result.Error != nil {
+			return fmt.Errorf("清空 %s 失败: %v", t.kind, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			a.report.Deleted += result.RowsAffected
+			a.record(ActionDelete, t.kind, "*", fmt.Sprintf("%d 条", result.RowsAffected))
+		}
+	}
+	return nil
+}
+
+func (a *applier) user(u UserFixture) error {
+	role := u.Role
+	if role == "" {
+		role = "user"
+	}
+
+	var existing models.User
+	err := a.tx.Unscoped().Where("username = ?", u.Username).Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		hash, err := a.hash(u.Password)
+		if err != nil {
+			return err
+		}
+		// 种子数据中的邮箱视为已验证
+		now := time.Now()
+		user := models.User{
+			Username:        u.Username,
+			Password:        hash,
+			Email:           u.Email,
+			Nickname:        u.Nickname,
+			Avatar:          u.Avatar,
+			Role:            role,
+			EmailVerifiedAt: &now,
+		}
+		if err := a.tx.Create(&user).Error; err != nil {
+			return err
+		}
+		a.users[u.ref()] = user.ID
+		a.record(ActionCreate, "user", u.Username, "")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	a.users[u.ref()] = existing.ID
+
+	var d diff
+	d.restore(existing.DeletedAt)
+	d.add("email", existing.Email, u.Email)
+	d.add("nickname", existing.Nickname, u.Nickname)
+	d.add("avatar", existing.Avatar, u.Avatar)
+	d.add("role", existing.Role, role)
+	if !a.passwordMatches(existing.Password, u.Password) {
+		hash, err := a.hash(u.Password)
+		if err != nil {
+			return err
+		}
+		d.set("password", "password", hash)
+	}
+	return a.update(&existing, "user", u.Username, d)
+}
+
+func (a *applier) course(c CourseFixture) error {
+	var existing models.Course
+	err := a.tx.Unscoped().Where("name = ?", c.Name).Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		course := models.Course{
+			Name:        c.Name,
+			Description: c.Description,
+			Teacher:     c.Teacher,
+			Credits:     c.Credits,
+			Grade:       c.Grade,
+			Semester:    c.Semester,
+			Subject:     c.Subject,
+			ImageURL:    c.ImageURL,
+		}
+		if err := a.tx.Create(&course).Error; err != nil {
+			return err
+		}
+		a.courses[c.ref()] = course.ID
+		a.record(ActionCreate, "course", c.Name, "")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	a.courses[c.ref()] = existing.ID
+
+	var d diff
+	d.restore(existing.DeletedAt)
+	d.add("description", existing.Description, c.Description)
+	d.add("teacher", existing.Teacher, c.Teacher)
+	d.add("credits", existing.Credits, c.Credits)
+	d.add("grade", existing.Grade, c.Grade)
+	d.add("semester", existing.Semester, c.Semester)
+	d.add("subject", existing.Subject, c.Subject)
+	d.add("image_url", existing.ImageURL, c.ImageURL)
+	return a.update(&existing, "course", c.Name, d)
+}
+
+func (a *applier) rating(r RatingFixture) error {
+	want := models.Rating{
+		UserID:     a.users[r.User],
+		CourseID:   a.courses[r.Course],
+		Score:      r.Score,
+		Difficulty: r.Difficulty,
+		Usefulness: r.Usefulness,
+		Teaching:   r.Teaching,
+		Content:    r.Content,
+	}
+	// 与模型钩子保持一致地规范各项评分，避免重复导入时产生虚假差异
+	want.BeforeCreate(a.tx)
+
+	key := r.User + "/" + r.Course
+	var existing models.Rating
+	err := a.tx.Unscoped().Where("user_id = ? AND course_id = ?", want.UserID, want.CourseID).Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := a.tx.Create(&want).Error; err != nil {
+			return err
+		}
+		a.record(ActionCreate, "rating", key, fmt.Sprintf("score=%.1f", want.Score))
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var d diff
+	d.restore(existing.DeletedAt)
+	d.add("score", existing.Score, want.Score)
+	d.add("difficulty", existing.Difficulty, want.Difficulty)
+	d.add("usefulness", existing.Usefulness, want.Usefulness)
+	d.add("teaching", existing.Teaching, want.Teaching)
+	d.add("content", existing.Content, want.Content)
+	return a.update(&existing, "rating", key, d)
+}
+
+func (a *applier) comment(c CommentFixture) error {
+	comment := models.Comment{
+		UserID:   a.users[c.User],
+		CourseID: a.courses[c.Course],
+		Content:  c.Content,
+	}
+
+	key := c.User + "/" + c.Course
+	// 评论没有自然键，用户、课程和内容完全相同即视为同一条
+	var existing models.Comment
+	err := a.tx.Unscoped().
+		Where("user_id = ? AND course_id = ? AND content = ?", comment.UserID, comment.CourseID, comment.Content).
+		Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := a.tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		a.record(ActionCreate, "comment", key, truncate(c.Content, 20))
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var d diff
+	d.restore(existing.DeletedAt)
+	return a.update(&existing, "comment", key, d)
+}
+
+func (a *applier) evaluationRequest(e EvaluationRequestFixture) error {
+	status := e.Status
+	if status == "" {
+		status = "pending"
+	}
+
+	key := e.User + "/" + e.Course
+	var existing models.EvaluationRequest
+	err := a.tx.Where("user_id = ? AND course_id = ?", a.users[e.User], a.courses[e.Course]).Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		request := models.EvaluationRequest{
+			UserID:   a.users[e.User],
+			CourseID: a.courses[e.Course],
+			Status:   status,
+		}
+		if err := a.tx.Omit("User", "Course").Create(&request).Error; err != nil {
+			return err
+		}
+		a.record(ActionCreate, "evaluation_request", key, status)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var d diff
+	d.add("status", existing.Status, status)
+	return a.update(&existing, "evaluation_request", key, d)
+}
+
+// update 写入差异字段，没有差异时只计数
+func (a *applier) update(model interface{}, kind, key string, d diff) error {
+	if len(d.fields) == 0 {
+		a.report.Unchanged++
+		return nil
+	}
+	if err := a.tx.Unscoped().Model(model).Updates(d.updates).Error; err != nil {
+		return err
+	}
+	a.record(ActionUpdate, kind, key, strings.Join(d.fields, ", "))
+	return nil
+}
+
+func (a *applier) record(action, kind, key, detail string) {
+	a.report.Changes = append(a.report.Changes, Change{Action: action, Kind: kind, Key: key, Detail: detail})
+}
+
+// hash 对相同的明文只计算一次 bcrypt，批量生成的用户共用同一密码
+func (a *applier) hash(password string) (string, error) {
+	if h, ok := a.hashes[password]; ok {
+		return h, nil
+	}
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	a.hashes[password] = string(h)
+	return string(h), nil
+}
+
+func (a *applier) passwordMatches(hash, password string) bool {
+	if h, ok := a.hashes[password]; ok && h == hash {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	a.hashes[password] = hash
+	return true
+}
+
+// diff 记录已有记录与种子数据不一致的字段
+type diff struct {
+	fields  []string
+	updates map[string]interface{}
+}
+
+func (d *diff) add(column string, old, new interface{}) {
+	if old != new {
+		d.set(column, fmt.Sprintf("%s: %v → %v", column, old, new), new)
+	}
+}
+
+// restore 记录仍在回收站中时将其恢复
+func (d *diff) restore(deletedAt gorm.DeletedAt) {
+	if deletedAt.Valid {
+		d.set("deleted_at", "从回收站恢复", nil)
+	}
+}
+
+func (d *diff) set(column, label string, value interface{}) {
+	if d.updates == nil {
+		d.updates = make(map[string]interface{})
+	}
+	d.fields = append(d.fields, label)
+	d.updates[column] = value
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
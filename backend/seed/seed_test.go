@@ -0,0 +1,127 @@
+package seed_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"xuan-ke-tong/models"
+	"xuan-ke-tong/seed"
+	"xuan-ke-tong/testdb"
+
+	"gorm.io/gorm"
+)
+
+// loadTest 读取 fixtures/test.yaml，测试在包目录下运行
+func loadTest(t *testing.T) *seed.Fixtures {
+	t.Helper()
+	fx, err := seed.Load(filepath.Join("..", seed.EnvFile("test")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fx
+}
+
+func count(t *testing.T, db *gorm.DB, model interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(model).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestApplyIsIdempotent(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		fx := loadTest(t)
+
+		report, err := seed.Apply(db, fx, seed.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := len(fx.Users) + len(fx.Courses) + len(fx.Ratings) + len(fx.Comments) + len(fx.EvaluationRequests)
+		if got := report.Count(seed.ActionCreate); got != want || len(report.Changes) != want {
+			t.Fatalf("首次导入新建 %d 条、共 %d 条变更，期望新建 %d 条", got, len(report.Changes), want)
+		}
+
+		report, err = seed.Apply(db, fx, seed.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Changes) != 0 || report.Unchanged != want {
+			t.Fatalf("重复导入产生变更 %v，未变 %d 条，期望 0 条变更", report.Changes, report.Unchanged)
+		}
+		if n := count(t, db, &models.Rating{}); n != int64(len(fx.Ratings)) {
+			t.Fatalf("重复导入后有 %d 条评分，期望 %d 条", n, len(fx.Ratings))
+		}
+	})
+}
+
+func TestApplyDryRunLeavesDatabaseUntouched(t *testing.T) {
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		fx := loadTest(t)
+
+		report, err := seed.Apply(db, fx, seed.Options{DryRun: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Count(seed.ActionCreate) == 0 {
+			t.Fatal("试运行没有报告任何新建")
+		}
+		for _, model := range []interface{}{&models.User{}, &models.Course{}, &models.Rating{}, &models.Comment{}, &models.EvaluationRequest{}} {
+			if n := count(t, db, model); n != 0 {
+				t.Fatalf("试运行后 %T 有 %d 行", model, n)
+			}
+		}
+
+		// 已有数据时试运行的 Reset 同样回滚
+		if _, err := seed.Apply(db, fx, seed.Options{}); err != nil {
+			t.Fatal(err)
+		}
+		report, err = seed.Apply(db, fx, seed.Options{DryRun: true, Reset: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Deleted == 0 {
+			t.Fatal("试运行的 Reset 没有报告清空的行")
+		}
+		if n := count(t, db, &models.User{}); n != int64(len(fx.Users)) {
+			t.Fatalf("试运行 Reset 后有 %d 个用户，期望 %d 个", n, len(fx.Users))
+		}
+	})
+}
+
+func TestUnknownUserRef(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.yaml")
+	data := `
+users:
+  - { username: alice, password: secret123, email: alice@example.com }
+courses:
+  - { name: 线性代数 }
+ratings:
+  - { user: carol, course: 线性代数, score: 5 }
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := seed.Load(path)
+	if err == nil || !strings.Contains(err.Error(), `ratings[0]: 未定义的用户 "carol"`) {
+		t.Fatalf("Load 返回 %v，期望指出未定义的用户 carol", err)
+	}
+
+	// 未经 Load 构造的种子数据在写入前同样被拒绝
+	testdb.EachMigrated(t, func(t *testing.T, db *gorm.DB) {
+		fx := &seed.Fixtures{
+			Users:   []seed.UserFixture{{Username: "alice", Password: "secret123", Email: "alice@example.com"}},
+			Courses: []seed.CourseFixture{{Name: "线性代数"}},
+			Ratings: []seed.RatingFixture{{User: "carol", Course: "线性代数", Score: 5}},
+		}
+		_, err := seed.Apply(db, fx, seed.Options{})
+		if err == nil || !strings.Contains(err.Error(), `未定义的用户 "carol"`) {
+			t.Fatalf("Apply 返回 %v，期望指出未定义的用户 carol", err)
+		}
+		if n := count(t, db, &models.User{}); n != 0 {
+			t.Fatalf("引用无效时写入了 %d 个用户", n)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net/http"
+	"xuan-ke-tong/config"
+)
+
+// Run 启动 HTTP(S) 服务并阻塞，直到 ctx 结束或监听出错。
+// ctx 结束后停止接受新连接，并在 ShutdownTimeout 内等待进行中的请求完成
+func Run(ctx context.Context, cfg config.ServerConfig, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	var certs *certReloader
+	if cfg.TLS.Enabled() {
+		var err error
+		certs, err = newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return err
+		}
+		go certs.watch(ctx)
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if certs != nil {
+			log.Printf("HTTPS 服务监听 %s", cfg.Addr)
+			// 证书由 GetCertificate 提供，这里不再指定文件
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("HTTP 服务监听 %s", cfg.Addr)
+			err = srv.ListenAndServe()
+		}
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("收到退出信号，最多等待 %s 完成进行中的请求", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Println("HTTP 服务已停止")
+	return nil
+}
@@ -4,74 +4,16 @@ package main
 
 import (
 	"fmt"
-	"xuan-ke-tong/config"
+	"xuan-ke-tong/migrations"
 	"xuan-ke-tong/models"
-)
-
-func setupTestData() error {
-	// 尝试手动创建表结构
-	err := config.DB.Exec(`
-		CREATE TABLE IF NOT EXISTS courses (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			description TEXT,
-			grade TEXT,
-			semester TEXT,
-			subject TEXT,
-			teacher TEXT,
-			credits INTEGER,
-			image_url TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`).Error
-	if err != nil {
-		return fmt.Errorf("failed to create courses table: %v", err)
-	}
 
-	err = config.DB.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL,
-			email TEXT NOT NULL UNIQUE,
-			nickname TEXT,
-			avatar TEXT,
-			role TEXT DEFAULT 'user',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`).Error
-	if err != nil {
-		return fmt.Errorf("failed to create users table: %v", err)
-	}
-
-	err = config.DB.Exec(`
-		CREATE TABLE IF NOT EXISTS ratings (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER,
-			course_id INTEGER,
-			score REAL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`).Error
-	if err != nil {
-		return fmt.Errorf("failed to create ratings table: %v", err)
-	}
+	"gorm.io/gorm"
+)
 
-	err = config.DB.Exec(`
-		CREATE TABLE IF NOT EXISTS comments (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER,
-			course_id INTEGER,
-			content TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`).Error
-	if err != nil {
-		return fmt.Errorf("failed to create comments table: %v", err)
+func setupTestData(db *gorm.DB) error {
+	// 表结构统一由迁移维护，避免与正式结构产生偏差
+	if _, err := migrations.Up(db, 0); err != nil {
+		return fmt.Errorf("failed to migrate database: %v", err)
 	}
 
 	// 添加测试课程
@@ -110,7 +52,7 @@ func setupTestData() error {
 
 	// 插入课程
 	for _, course := range courses {
-		if err := config.DB.Create(&course).Error; err != nil {
+		if err := db.Create(&course).Error; err != nil {
 			fmt.Printf("创建课程失败: %v\n", err)
 			continue
 		}
@@ -0,0 +1,73 @@
+//go:build dialects
+
+package testdb
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"xuan-ke-tong/config"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func init() {
+	dialects = append(dialects,
+		dialect{config.DriverPostgres, external("XKT_TEST_POSTGRES_DSN", openPostgres)},
+		dialect{config.DriverMySQL, external("XKT_TEST_MYSQL_DSN", openMySQL)},
+	)
+}
+
+// external 从环境变量读取服务器的连接串，未设置时跳过该数据库
+func external(env string, open func(t *testing.T, dsn string) *gorm.DB) func(t *testing.T) *gorm.DB {
+	return func(t *testing.T) *gorm.DB {
+		dsn := os.Getenv(env)
+		if dsn == "" {
+			t.Skipf("未设置 %s", env)
+		}
+		return open(t, dsn)
+	}
+}
+
+// openPostgres 建立测试专用的 schema，并通过 search_path 让连接池中的每个连接都使用它
+func openPostgres(t *testing.T, dsn string) *gorm.DB {
+	admin := connect(t, config.DatabaseConfig{Driver: config.DriverPostgres, DSN: dsn})
+	schema := uniqueName()
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("创建 schema 失败: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatalf("XKT_TEST_POSTGRES_DSN 无效: %v", err)
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	return connect(t, config.DatabaseConfig{Driver: config.DriverPostgres, DSN: dsn})
+}
+
+// openMySQL 建立测试专用的库
+func openMySQL(t *testing.T, dsn string) *gorm.DB {
+	admin := connect(t, config.DatabaseConfig{Driver: config.DriverMySQL, DSN: dsn})
+	name := uniqueName()
+	if err := admin.Exec("CREATE DATABASE " + name).Error; err != nil {
+		t.Fatalf("创建库失败: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP DATABASE " + name) })
+
+	cfg, err := mysql.ParseDSN(strings.TrimPrefix(dsn, "mysql://"))
+	if err != nil {
+		t.Fatalf("XKT_TEST_MYSQL_DSN 无效: %v", err)
+	}
+	cfg.DBName = name
+	return connect(t, config.DatabaseConfig{Driver: config.DriverMySQL, DSN: cfg.FormatDSN()})
+}
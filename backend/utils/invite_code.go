@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+// 邀请码字符集，去掉了容易混淆的 0、O、1、I
+const inviteAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// NewInviteCode 生成形如 ABCDE-FGHJK 的邀请码，返回邀请码和用于存储的摘要
+func NewInviteCode() (code, hash string, err error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	var sb strings.Builder
+	for i, b := range buf {
+		if i == 5 {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(inviteAlphabet[int(b)%len(inviteAlphabet)])
+	}
+	code = sb.String()
+	return code, HashInviteCode(code), nil
+}
+
+// HashInviteCode 计算邀请码的摘要，忽略大小写、空格和连字符
+func HashInviteCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return HashOpaqueToken(code)
+}
@@ -1,51 +1,105 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"time"
+	"xuan-ke-tong/config"
 	"xuan-ke-tong/models"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key-change-this-in-production")
-
 type Claims struct {
-	UserID   uint   `json:"userId"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID    uint   `json:"userId"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	SessionID uint   `json:"sid"`
+	Role      string `json:"role"`          // 签发时的角色，权限检查据此进行，无需查询数据库
+	TwoFactor bool   `json:"tfa,omitempty"` // 会话是否已通过两步验证
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(user models.User) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// TokenManager 负责签发和校验登录令牌
+type TokenManager struct {
+	keys       *Keyring
+	ttl        time.Duration
+	refreshTTL time.Duration
+	issuer     string
+}
+
+func NewTokenManager(cfg config.JWTConfig) (*TokenManager, error) {
+	keys, err := NewKeyring(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := keys.signer(time.Now()); err != nil {
+		return nil, err
+	}
+	return &TokenManager{
+		keys:       keys,
+		ttl:        cfg.TTL,
+		refreshTTL: cfg.RefreshTTL,
+		issuer:     cfg.Issuer,
+	}, nil
+}
+
+// JWKS 供其他服务校验令牌的公钥集合
+func (m *TokenManager) JWKS() JWKS {
+	return m.keys.JWKS()
+}
+
+// TTL 访问令牌的有效期
+func (m *TokenManager) TTL() time.Duration {
+	return m.ttl
+}
+
+// RefreshTTL 刷新令牌的有效期
+func (m *TokenManager) RefreshTTL() time.Duration {
+	return m.refreshTTL
+}
+
+// GenerateToken 为会话签发访问令牌
+func (m *TokenManager) GenerateToken(user models.User, session models.Session) (string, error) {
+	now := time.Now()
+	key, err := m.keys.signer(now)
+	if err != nil {
+		return "", err
+	}
 
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Email:    user.Email,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		SessionID: session.ID,
+		Role:      user.Role,
+		TwoFactor: session.TwoFactorAt != nil,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "xuan-ke-tong",
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    m.issuer,
 			Subject:   fmt.Sprintf("%d", user.ID),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
-
-	return tokenString, err
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
+// ValidateToken 按 kid 选择密钥校验令牌，签名算法必须与密钥一致
+func (m *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-
+	token, err := jwt.ParseWithClaims(tokenString, claims, m.keys.keyFunc,
+		jwt.WithValidMethods(m.keys.algorithms()),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -54,5 +108,27 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("invalid token")
 	}
 
+	// 引入会话之前签发的令牌无法吊销，不再接受
+	if claims.SessionID == 0 {
+		return nil, fmt.Errorf("token has no session")
+	}
+
 	return claims, nil
 }
+
+// NewOpaqueToken 生成随机的不透明令牌（刷新令牌、邮件链接中的令牌等），
+// 返回令牌本身和用于存储的摘要
+func NewOpaqueToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken 计算不透明令牌的摘要，数据库中只保存摘要
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+	"xuan-ke-tong/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 未配置 jwt.keys 时由 jwt.secret 生成的 HS256 密钥 ID
+const defaultKeyID = "default"
+
+// signingKey 密钥环中的一个密钥
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   interface{} // 签名用：[]byte、ed25519.PrivateKey 或 *rsa.PrivateKey；为空时仅用于校验
+	public    interface{} // 校验用：[]byte、ed25519.PublicKey 或 *rsa.PublicKey
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// canSign 密钥在 now 时是否可以签名
+func (k *signingKey) canSign(now time.Time) bool {
+	return k.private != nil && !now.Before(k.notBefore) && k.accepts(now)
+}
+
+// accepts 由该密钥签名的令牌在 now 时是否仍被接受
+func (k *signingKey) accepts(now time.Time) bool {
+	return k.notAfter.IsZero() || now.Before(k.notAfter)
+}
+
+// Keyring 按 kid 管理令牌签名密钥，支持多个密钥的有效期重叠以平滑轮换
+type Keyring struct {
+	keys []*signingKey // 按 notBefore 从新到旧排列
+}
+
+// NewKeyring 读取配置中的密钥；未配置 keys 时使用 jwt.secret 作为唯一的 HS256 密钥
+func NewKeyring(cfg config.JWTConfig) (*Keyring, error) {
+	keys := cfg.Keys
+	if len(keys) == 0 {
+		keys = []config.JWTKeyConfig{{ID: defaultKeyID, Algorithm: config.JWTAlgHS256, Secret: cfg.Secret}}
+	}
+
+	ring := &Keyring{}
+	for _, kc := range keys {
+		key, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("加载签名密钥 %s 失败: %v", kc.ID, err)
+		}
+		ring.keys = append(ring.keys, key)
+	}
+	sort.SliceStable(ring.keys, func(i, j int) bool {
+		return ring.keys[i].notBefore.After(ring.keys[j].notBefore)
+	})
+	return ring, nil
+}
+
+func loadKey(kc config.JWTKeyConfig) (*signingKey, error) {
+	key := &signingKey{id: kc.ID, notBefore: kc.NotBefore, notAfter: kc.NotAfter}
+
+	if kc.Algorithm == config.JWTAlgHS256 {
+		key.method = jwt.SigningMethodHS256
+		key.private, key.public = []byte(kc.Secret), []byte(kc.Secret)
+		return key, nil
+	}
+
+	var err error
+	switch kc.Algorithm {
+	case config.JWTAlgEdDSA:
+		key.method = jwt.SigningMethodEdDSA
+		if kc.PrivateKeyFile != "" {
+			var private interface{}
+			if private, err = readPEM(kc.PrivateKeyFile, jwt.ParseEdPrivateKeyFromPEM); err == nil {
+				key.private = private
+				key.public = private.(ed25519.PrivateKey).Public()
+			}
+		} else {
+			key.public, err = readPEM(kc.PublicKeyFile, jwt.ParseEdPublicKeyFromPEM)
+		}
+	case config.JWTAlgRS256:
+		key.method = jwt.SigningMethodRS256
+		if kc.PrivateKeyFile != "" {
+			var private *rsa.PrivateKey
+			if private, err = readPEM(kc.PrivateKeyFile, jwt.ParseRSAPrivateKeyFromPEM); err == nil {
+				key.private, key.public = private, &private.PublicKey
+			}
+		} else {
+			key.public, err = readPEM(kc.PublicKeyFile, jwt.ParseRSAPublicKeyFromPEM)
+		}
+	default:
+		err = fmt.Errorf("不支持的算法 %s", kc.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func readPEM[T any](path string, parse func([]byte) (T, error)) (T, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return parse(data)
+}
+
+// signer 返回 now 时负责签名的密钥：已生效的密钥中 notBefore 最晚的一个
+func (r *Keyring) signer(now time.Time) (*signingKey, error) {
+	for _, key := range r.keys {
+		if key.canSign(now) {
+			return key, nil
+		}
+	}
+	return nil, errors.New("没有可用于签名的密钥")
+}
+
+// keyFunc 按令牌头部的 kid 查找校验密钥，并要求算法与密钥一致
+func (r *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	now := time.Now()
+	for _, key := range r.keys {
+		if key.id != kid {
+			continue
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("密钥 %s 不接受 %s 算法", kid, token.Method.Alg())
+		}
+		if !key.accepts(now) {
+			return nil, fmt.Errorf("密钥 %s 已停用", kid)
+		}
+		return key.public, nil
+	}
+	return nil, fmt.Errorf("未知的密钥 %q", kid)
+}
+
+// algorithms 密钥环中出现的全部算法，用于拒绝其他算法（包括 none）签名的令牌
+func (r *Keyring) algorithms() []string {
+	var algs []string
+	seen := make(map[string]bool)
+	for _, key := range r.keys {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// JWK JSON Web Key 中的公钥
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 返回仍被接受的非对称密钥的公钥，HS256 共享密钥不会公开
+func (r *Keyring) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	now := time.Now()
+	for _, key := range r.keys {
+		if !key.accepts(now) {
+			continue
+		}
+		jwk := JWK{Kid: key.id, Alg: key.method.Alg(), Use: "sig"}
+		switch pub := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"xuan-ke-tong/config"
+	"xuan-ke-tong/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testIssuer = "xuan-ke-tong-test"
+
+// writeEd25519Key 生成 Ed25519 私钥写入 PEM 文件，返回文件路径和公钥
+func writeEd25519Key(t *testing.T) (string, ed25519.PublicKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ed25519.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, public
+}
+
+func newTestManager(t *testing.T, keys ...config.JWTKeyConfig) *TokenManager {
+	t.Helper()
+	m, err := NewTokenManager(config.JWTConfig{Keys: keys, TTL: time.Minute, Issuer: testIssuer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// sign 以密钥环中的 kid 密钥签名一个会话 ID 为 sid 的令牌
+func sign(t *testing.T, m *TokenManager, kid string, sid uint) string {
+	t.Helper()
+	for _, key := range m.keys.keys {
+		if key.id != kid {
+			continue
+		}
+		now := time.Now()
+		token := jwt.NewWithClaims(key.method, &Claims{
+			UserID:    1,
+			SessionID: sid,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+				IssuedAt:  jwt.NewNumericDate(now),
+				Issuer:    testIssuer,
+			},
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key.private)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	t.Fatalf("密钥环中没有 %s", kid)
+	return ""
+}
+
+func TestKeyFuncRejectsAlgorithmMismatch(t *testing.T) {
+	path, public := writeEd25519Key(t)
+	m := newTestManager(t,
+		config.JWTKeyConfig{ID: "hs", Algorithm: config.JWTAlgHS256, Secret: "shared-secret-0123456789abcdef"},
+		config.JWTKeyConfig{ID: "ed", Algorithm: config.JWTAlgEdDSA, PrivateKeyFile: path},
+	)
+
+	token := &jwt.Token{Method: jwt.SigningMethodHS256, Header: map[string]interface{}{"kid": "ed"}}
+	if _, err := m.keys.keyFunc(token); err == nil {
+		t.Fatal("HS256 令牌指向 EdDSA 密钥时应被拒绝")
+	}
+
+	// 以公开的 Ed25519 公钥作为 HS256 密钥伪造令牌
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID:    1,
+		SessionID: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			Issuer:    testIssuer,
+		},
+	})
+	forged.Header["kid"] = "ed"
+	signed, err := forged.SignedString([]byte(public))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(signed); err == nil {
+		t.Fatal("以公钥作 HS256 密钥伪造的令牌应被拒绝")
+	}
+
+	// 密钥环中没有的算法（包括 none）直接拒绝
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, &Claims{SessionID: 1})
+	none.Header["kid"] = "hs"
+	unsigned, err := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(unsigned); err == nil {
+		t.Fatal("alg=none 的令牌应被拒绝")
+	}
+
+	if _, err := m.ValidateToken(sign(t, m, "ed", 1)); err != nil {
+		t.Fatalf("EdDSA 密钥签名的令牌应通过校验: %v", err)
+	}
+}
+
+func TestKeyFuncRejectsUnknownKid(t *testing.T) {
+	m := newTestManager(t, config.JWTKeyConfig{ID: "hs", Algorithm: config.JWTAlgHS256, Secret: "shared-secret-0123456789abcdef"})
+
+	for _, header := range []map[string]interface{}{{"kid": "missing"}, {}} {
+		token := &jwt.Token{Method: jwt.SigningMethodHS256, Header: header}
+		if _, err := m.keys.keyFunc(token); err == nil || !strings.Contains(err.Error(), "未知的密钥") {
+			t.Fatalf("kid %v 应被拒绝，得到 %v", header["kid"], err)
+		}
+	}
+}
+
+func TestKeyFuncRetiredKey(t *testing.T) {
+	now := time.Now()
+	m := newTestManager(t,
+		config.JWTKeyConfig{ID: "retired", Algorithm: config.JWTAlgHS256, Secret: "retired-secret-0123456789abcdef",
+			NotBefore: now.Add(-48 * time.Hour), NotAfter: now.Add(-time.Hour)},
+		config.JWTKeyConfig{ID: "previous", Algorithm: config.JWTAlgHS256, Secret: "previous-secret-0123456789abcde",
+			NotBefore: now.Add(-24 * time.Hour), NotAfter: now.Add(time.Hour)},
+		config.JWTKeyConfig{ID: "current", Algorithm: config.JWTAlgHS256, Secret: "current-secret-0123456789abcdef",
+			NotBefore: now.Add(-time.Minute)},
+	)
+
+	if _, err := m.ValidateToken(sign(t, m, "retired", 1)); err == nil || !strings.Contains(err.Error(), "已停用") {
+		t.Fatalf("已停用密钥签名的令牌应被拒绝，得到 %v", err)
+	}
+	// 轮换期间旧密钥签名的令牌在 notAfter 之前仍被接受
+	if _, err := m.ValidateToken(sign(t, m, "previous", 1)); err != nil {
+		t.Fatalf("未到 notAfter 的旧密钥应被接受: %v", err)
+	}
+
+	// 新令牌由最新生效的密钥签名
+	signed, err := m.GenerateToken(models.User{ID: 1}, models.Session{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(signed, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := token.Header["kid"]; kid != "current" {
+		t.Fatalf("签名密钥为 %v，期望 current", kid)
+	}
+}
+
+func TestValidateTokenRequiresSession(t *testing.T) {
+	m := newTestManager(t, config.JWTKeyConfig{ID: "hs", Algorithm: config.JWTAlgHS256, Secret: "shared-secret-0123456789abcdef"})
+
+	if _, err := m.ValidateToken(sign(t, m, "hs", 0)); err == nil {
+		t.Fatal("没有会话 ID 的令牌应被拒绝")
+	}
+
+	signed, err := m.GenerateToken(models.User{ID: 7, Username: "alice"}, models.Session{ID: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := m.ValidateToken(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != 7 || claims.SessionID != 3 {
+		t.Fatalf("令牌内容为 %+v", claims)
+	}
+}
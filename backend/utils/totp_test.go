@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的 SHA-1 密钥
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// 附录 B 给出 8 位验证码，取其后 6 位
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range cases {
+		if got := totpCode([]byte("12345678901234567890"), unix/totpPeriod); got != want {
+			t.Errorf("T=%d 的验证码为 %s，期望 %s", unix, got, want)
+		}
+	}
+}
+
+func TestVerifyTOTPWindow(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	current := now.Unix() / totpPeriod
+	key := []byte("12345678901234567890")
+
+	for offset := int64(-3); offset <= 3; offset++ {
+		step, ok := VerifyTOTP(rfcSecret, totpCode(key, current+offset), now)
+		inWindow := offset >= -totpSkew && offset <= totpSkew
+		if ok != inWindow {
+			t.Errorf("偏移 %d 步的验证码通过校验为 %v，期望 %v", offset, ok, inWindow)
+		}
+		if ok && step != current+offset {
+			t.Errorf("偏移 %d 步返回时间步 %d，期望 %d", offset, step, current+offset)
+		}
+	}
+
+	code := totpCode(key, current)
+	if _, ok := VerifyTOTP(rfcSecret, code[:3]+" "+code[3:], now); !ok {
+		t.Error("应忽略验证码中的空格")
+	}
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := VerifyTOTP(rfcSecret, bad, now); ok {
+			t.Errorf("验证码 %q 不应通过校验", bad)
+		}
+	}
+	if _, ok := VerifyTOTP("not base32!", code, now); ok {
+		t.Error("无效的密钥不应通过校验")
+	}
+}
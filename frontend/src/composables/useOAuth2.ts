@@ -1,6 +1,7 @@
 import { ref, computed } from 'vue'
 import { useRouter } from 'vue-router'
 import { OAuth2Service } from '@/services/oauth2Api'
+import { useAuthStore, isTwoFactorChallenge } from '@/stores/auth'
 import { OAuth2Utils } from '@/utils/oauth2'
 import type { OAuth2Error } from '@/types/oauth2'
 
@@ -43,6 +44,13 @@ export function useOAuth2() {
 
     try {
       const response = await OAuth2Service.handleOAuth2Callback(code, state)
+
+      // 已启用两步验证，到登录页输入动态验证码
+      if (isTwoFactorChallenge(response)) {
+        useAuthStore().setTwoFactorChallenge(response)
+        router.push('/auth')
+        return
+      }
       
       // 这里可以触发登录成功的事件或调用其他服务
       console.log('OAuth2登录成功:', response)
@@ -24,20 +24,54 @@ api.interceptors.request.use(
   }
 )
 
-// 响应拦截器
-api.interceptors.response.use(
-  (response) => {
-    return response
-  },
-  (error) => {
-    if (error.response?.status === 401) {
-      // 处理未授权情况
-      localStorage.removeItem('token')
-      window.location.href = '/auth'
+// 用刷新令牌换取新的访问令牌，并发的 401 共用同一次刷新
+let refreshing: Promise<string | null> | null = null
+
+export const refreshAccessToken = (): Promise<string | null> => {
+  const refreshToken = localStorage.getItem('refreshToken')
+  if (!refreshToken) {
+    return Promise.resolve(null)
+  }
+  if (!refreshing) {
+    refreshing = axios
+      .post(`${API_BASE_URL}/auth/refresh`, { refreshToken })
+      .then((response) => {
+        const { token, refreshToken: nextRefreshToken } = response.data
+        localStorage.setItem('token', token)
+        localStorage.setItem('refreshToken', nextRefreshToken)
+        axios.defaults.headers.common['Authorization'] = `Bearer ${token}`
+        api.defaults.headers.common['Authorization'] = `Bearer ${token}`
+        return token as string
+      })
+      .catch(() => null)
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
+// 响应拦截器：访问令牌过期时先尝试刷新并重试一次，失败再跳转登录
+const retryWithRefresh = async (error: any) => {
+  const original = error.config
+  const isAuthRequest = /\/auth\/(login|register|refresh)$/.test(original?.url ?? '')
+  if (error.response?.status === 401 && original && !original._retried && !isAuthRequest) {
+    original._retried = true
+    const token = await refreshAccessToken()
+    if (token) {
+      original.headers.Authorization = `Bearer ${token}`
+      return axios.request(original)
     }
-    return Promise.reject(error)
+    // 处理未授权情况
+    localStorage.removeItem('token')
+    localStorage.removeItem('refreshToken')
+    window.location.href = '/auth'
   }
-)
+  return Promise.reject(error)
+}
+
+api.interceptors.response.use((response) => response, retryWithRefresh)
+axios.interceptors.response.use((response) => response, retryWithRefresh)
 
 export interface Course {
   id: number
@@ -4,6 +4,7 @@ import type {
   OAuth2StateResponse, 
   OAuth2CallbackResponse
 } from '@/types/oauth2'
+import type { TwoFactorChallenge } from '@/stores/auth'
 import { OAuth2Utils } from '@/utils/oauth2'
 
 const BACKEND_BASE_URL = import.meta.env.VITE_BACKEND_BASE_URL
@@ -19,20 +20,24 @@ export const OAUTH2_CONFIG: OAuth2Config = {
 // OAuth2服务类
 export class OAuth2Service {
   /**
-   * 获取OAuth2 state参数
+   * 获取OAuth2 state和PKCE参数
    */
-  static async getOAuth2State(): Promise<string> {
+  static async getOAuth2State(): Promise<OAuth2StateResponse> {
     try {
+      // 后端在响应中写入浏览器绑定 Cookie，跨域时需要 withCredentials 才会保存
       const response = await axios.get<OAuth2StateResponse>(
-        `${BACKEND_BASE_URL}/auth/oauth2/state`
+        `${BACKEND_BASE_URL}/auth/oauth2/state`,
+        { withCredentials: true }
       )
       
-      const state = response.data.state
-      if (!OAuth2Utils.validateState(state)) {
+      if (!OAuth2Utils.validateState(response.data.state)) {
         throw new Error('无效的state参数')
       }
+      if (!response.data.authURL && !response.data.codeChallenge) {
+        throw new Error('缺少PKCE参数')
+      }
       
-      return state
+      return response.data
     } catch (error) {
       console.error('获取OAuth2 state失败:', error)
       const oauth2Error = OAuth2Utils.parseError(error)
@@ -41,24 +46,31 @@ export class OAuth2Service {
   }
 
   /**
-   * 构建OAuth2授权URL
+   * 构建OAuth2授权URL，后端已返回authURL时直接使用
    */
-  static buildOAuth2URL(state: string): string {
+  static buildOAuth2URL(auth: OAuth2StateResponse): string {
+    if (auth.authURL) {
+      return auth.authURL
+    }
+
     const params = new URLSearchParams({
       appid: OAUTH2_CONFIG.APP_ID,
       redirect_uri: OAUTH2_CONFIG.REDIRECT_URI,
       response_type: 'code',
       scope: OAUTH2_CONFIG.SCOPE,
-      state: state
+      state: auth.state,
+      // 后端换取令牌时提交对应的 code_verifier
+      code_challenge: auth.codeChallenge,
+      code_challenge_method: auth.codeChallengeMethod
     })
     
     return `${OAUTH2_CONFIG.BASE_URL}/new/connect/?${params.toString()}`
   }
 
   /**
-   * 处理OAuth2回调
+   * 处理OAuth2回调；已启用两步验证的用户返回两步验证挑战
    */
-  static async handleOAuth2Callback(code: string, state: string): Promise<OAuth2CallbackResponse> {
+  static async handleOAuth2Callback(code: string, state: string): Promise<OAuth2CallbackResponse | TwoFactorChallenge> {
     try {
       // 验证参数
       if (!code || !state) {
@@ -69,10 +81,12 @@ export class OAuth2Service {
         throw new Error('无效的state参数')
       }
       
-      const response = await axios.get<OAuth2CallbackResponse>(
+      const response = await axios.get<OAuth2CallbackResponse | TwoFactorChallenge>(
         `${BACKEND_BASE_URL}/auth/oauth2/callback`,
         {
-          params: { code, state }
+          params: { code, state },
+          // 回调必须带上发起授权时写入的绑定 Cookie
+          withCredentials: true
         }
       )
       return response.data
@@ -88,11 +102,11 @@ export class OAuth2Service {
    */
   static async startOAuth2Login(): Promise<void> {
     try {
-      // 1. 获取state
-      const state = await this.getOAuth2State()
+      // 1. 获取state和PKCE参数
+      const auth = await this.getOAuth2State()
       
       // 2. 构建授权URL
-      const authURL = this.buildOAuth2URL(state)
+      const authURL = this.buildOAuth2URL(auth)
       
       // 3. 跳转到授权页面
       window.location.href = authURL
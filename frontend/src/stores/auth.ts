@@ -16,28 +16,46 @@ export interface User {
 
 export interface AuthResponse {
   token: string
+  refreshToken: string
+  expiresIn: number
   user: User
 }
 
+// 已启用两步验证的用户通过密码或第三方登录后返回，凭 challengeToken 和动态验证码完成登录
+export interface TwoFactorChallenge {
+  twoFactorRequired: true
+  challengeToken: string
+  expiresIn: number
+}
+
+export const isTwoFactorChallenge = (data: unknown): data is TwoFactorChallenge =>
+  !!data && (data as TwoFactorChallenge).twoFactorRequired === true
+
 export const useAuthStore = defineStore('auth', () => {
   const user = ref<User | null>(null)
   const token = ref<string | null>(localStorage.getItem('token'))
   const loading = ref(false)
   const error = ref<string | null>(null)
+  // 等待提交动态验证码的登录挑战
+  const twoFactorChallenge = ref<TwoFactorChallenge | null>(null)
 
   // Computed
   const isAuthenticated = computed(() => !!token.value && !!user.value)
   const isAdmin = computed(() => user.value?.role === 'admin')
 
   // Set token
-  const setToken = (newToken: string | null) => {
+  const setToken = (newToken: string | null, refreshToken?: string) => {
     token.value = newToken
+    if (refreshToken) {
+      localStorage.setItem('refreshToken', refreshToken)
+    }
     if (newToken) {
       localStorage.setItem('token', newToken)
       axios.defaults.headers.common['Authorization'] = `Bearer ${newToken}`
       api.defaults.headers.common['Authorization'] = `Bearer ${newToken}`
     } else {
       localStorage.removeItem('token')
+      localStorage.removeItem('refreshToken')
       delete axios.defaults.headers.common['Authorization']
       delete api.defaults.headers.common['Authorization']
     }
@@ -62,8 +80,8 @@ export const useAuthStore = defineStore('auth', () => {
     try {
       const response = await axios.post<AuthResponse>(`${import.meta.env.VITE_BACKEND_BASE_URL}/auth/register`, userData)
       
-      const { token: newToken, user: userInfo } = response.data
-      setToken(newToken)
+      const { token: newToken, refreshToken, user: userInfo } = response.data
+      setToken(newToken, refreshToken)
       user.value = userInfo
       
       return { success: true, data: response.data }
@@ -85,10 +103,16 @@ export const useAuthStore = defineStore('auth', () => {
     error.value = null
     
     try {
-      const response = await axios.post<AuthResponse>(`${import.meta.env.VITE_BACKEND_BASE_URL}/auth/login`, credentials)
+      const response = await axios.post<AuthResponse | TwoFactorChallenge>(`${import.meta.env.VITE_BACKEND_BASE_URL}/auth/login`, credentials)
       
-      const { token: newToken, user: userInfo } = response.data
-      setToken(newToken)
+      // 密码正确但需要动态验证码，此时还没有登录
+      if (isTwoFactorChallenge(response.data)) {
+        twoFactorChallenge.value = response.data
+        return { success: false, twoFactorRequired: true }
+      }
+
+      const { token: newToken, refreshToken, user: userInfo } = response.data
+      setToken(newToken, refreshToken)
       user.value = userInfo
       
       return { success: true, data: response.data }
@@ -101,8 +125,50 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
-  // Logout
-  const logout = () => {
+  // Verify two-factor: submit the authenticator code (or a recovery code) for the pending challenge
+  const verifyTwoFactor = async (code: string, useRecoveryCode = false) => {
+    if (!twoFactorChallenge.value) {
+      return { success: false, error: '登录已过期，请重新登录' }
+    }
+
+    loading.value = true
+    error.value = null
+
+    try {
+      const response = await axios.post<AuthResponse>(`${import.meta.env.VITE_BACKEND_BASE_URL}/auth/login/2fa`, {
+        challengeToken: twoFactorChallenge.value.challengeToken,
+        ...(useRecoveryCode ? { recoveryCode: code } : { code })
+      })
+
+      const { token: newToken, refreshToken, user: userInfo } = response.data
+      setToken(newToken, refreshToken)
+      user.value = userInfo
+      twoFactorChallenge.value = null
+
+      return { success: true, data: response.data }
+    } catch (err: any) {
+      const errorMessage = err.response?.data?.error || 'Verification failed'
+      error.value = errorMessage
+      return { success: false, error: errorMessage }
+    } finally {
+      loading.value = false
+    }
+  }
+
+  // Start a two-factor challenge returned by another login method (e.g. the OAuth2 callback)
+  const setTwoFactorChallenge = (challenge: TwoFactorChallenge | null) => {
+    twoFactorChallenge.value = challenge
+  }
+
+  // Logout: revoke the session on the server, then clear local tokens
+  const logout = async () => {
+    if (token.value) {
+      try {
+        await axios.post(`${import.meta.env.VITE_BACKEND_BASE_URL}/auth/logout`)
+      } catch {
+        // The session may already be expired or revoked
+      }
+    }
     setToken(null)
     user.value = null
     error.value = null
@@ -131,8 +197,8 @@ export const useAuthStore = defineStore('auth', () => {
   }
 
   // OAuth2 Login
-  const oauth2Login = async (token: string, userInfo: User) => {
-    setToken(token)
+  const oauth2Login = async (token: string, userInfo: User, refreshToken?: string) => {
+    setToken(token, refreshToken)
     user.value = userInfo
     error.value = null
   }
@@ -147,10 +213,13 @@ export const useAuthStore = defineStore('auth', () => {
     token,
     loading,
     error,
+    twoFactorChallenge,
     isAuthenticated,
     isAdmin,
     register,
     login,
+    verifyTwoFactor,
+    setTwoFactorChallenge,
     logout,
     getCurrentUser,
     oauth2Login,
@@ -39,12 +39,21 @@ export interface OAuth2Config {
 
 // OAuth2 State响应接口
 export interface OAuth2StateResponse {
+  provider: string
   state: string
+  // PKCE 参数，需拼接到授权地址的 code_challenge / code_challenge_method
+  codeChallenge: string
+  codeChallengeMethod: string
+  expiresIn: number
+  // 后端能拼出授权地址时返回，前端直接跳转
+  authURL?: string
 }
 
 // OAuth2回调响应接口
 export interface OAuth2CallbackResponse {
   token: string
+  refreshToken: string
+  expiresIn: number
   user: {
     id: number
     username: string